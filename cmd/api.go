@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/doko89/cliboard/internal/api"
+	"github.com/spf13/cobra"
+)
+
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Run the JSON API and manage its tokens",
+}
+
+var apiListen string
+
+var apiServeCmd = &cobra.Command{
+	Use:         "serve",
+	Short:       "Serve the JSON API",
+	Annotations: readOnly,
+	Long: `Serve the JSON API.
+
+The API listens on a unix socket by default. Use --listen unix:<path> for
+another socket or --listen 127.0.0.1:<port> for a loopback TCP port; other
+addresses are refused. GET /v1/openapi.json describes every endpoint.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if apiListen == "" {
+			apiListen = api.DefaultListen()
+		}
+		return api.Serve(apiListen)
+	},
+}
+
+var apiTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens",
+}
+
+var apiTokenScope string
+
+var apiTokenCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create an API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		secret, err := api.CreateToken(args[0], api.Scope(apiTokenScope))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("API token %s created with %s scope. It is shown only once:\n%s\n", args[0], apiTokenScope, secret)
+		return nil
+	},
+}
+
+var apiTokenListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List API tokens",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tokens, err := api.Tokens()
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No API tokens")
+			return nil
+		}
+		fmt.Println("API tokens:")
+		for _, t := range tokens {
+			fmt.Printf("- %s (%s, created %s)\n", t.Name, t.Scope, t.Created.Format("2006-01-02"))
+		}
+		return nil
+	},
+}
+
+var apiTokenRevokeCmd = &cobra.Command{
+	Use:   "revoke [name]",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := api.RevokeToken(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("API token %s revoked\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	apiServeCmd.Flags().StringVar(&apiListen, "listen", "", "unix:<path> or loopback host:port to listen on (default unix socket in the run directory)")
+	apiTokenCreateCmd.Flags().StringVar(&apiTokenScope, "scope", string(api.ScopeRead), "token scope: read or admin")
+
+	apiTokenCmd.AddCommand(apiTokenCreateCmd)
+	apiTokenCmd.AddCommand(apiTokenListCmd)
+	apiTokenCmd.AddCommand(apiTokenRevokeCmd)
+
+	apiCmd.AddCommand(apiServeCmd)
+	apiCmd.AddCommand(apiTokenCmd)
+	rootCmd.AddCommand(apiCmd)
+}
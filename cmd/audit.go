@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/audit"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// readOnly annotates commands that never change anything. They, and their
+// subcommands, are not recorded in the audit log.
+var readOnly = map[string]string{"cliboard.readonly": "true"}
+
+// auditOp is the audited operation of the running command, if any
+var auditOp *audit.Operation
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log of changes",
+}
+
+var (
+	auditSince string
+	auditSite  string
+	auditUser  string
+	auditJSON  bool
+)
+
+var auditListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List audited operations",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := audit.Filter{Site: auditSite, User: auditUser}
+		if auditSince != "" {
+			since, err := parseSince(auditSince, time.Now())
+			if err != nil {
+				return err
+			}
+			filter.Since = since
+		}
+
+		entries, err := audit.List(filter)
+		if err != nil {
+			return err
+		}
+
+		if auditJSON {
+			enc := json.NewEncoder(os.Stdout)
+			for _, e := range entries {
+				enc.Encode(e)
+			}
+			return nil
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("No audited operations")
+			return nil
+		}
+		for _, e := range entries {
+			user := e.User
+			if e.SudoUser != "" {
+				user = e.SudoUser + " (" + e.User + ")"
+			}
+			result := e.Result
+			if e.Error != "" {
+				result += ": " + e.Error
+			}
+			fmt.Printf("%s  %-5s  %-16s  %s  %s  %.1fs\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Source, user,
+				strings.TrimSpace(e.Command+" "+strings.Join(e.Args, " ")), result, e.Duration)
+			for _, f := range e.Files {
+				fmt.Printf("    %s\n", f)
+			}
+		}
+		return nil
+	},
+}
+
+// audited reports whether a command is recorded in the audit log
+func audited(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return false
+	}
+
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations["cliboard.readonly"] == "true" {
+			return false
+		}
+	}
+
+	// Commands with a --fix flag only change things when it is given
+	if fix := cmd.Flags().Lookup("fix"); fix != nil {
+		return fix.Changed
+	}
+	return true
+}
+
+// beginAudit starts auditing the running command
+func beginAudit(cmd *cobra.Command, args []string) {
+	command := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+
+	// Keep the flags given to the command itself along with its arguments
+	recorded := append([]string{}, args...)
+	cmd.LocalFlags().Visit(func(f *pflag.Flag) {
+		recorded = append(recorded, "--"+f.Name+"="+f.Value.String())
+	})
+
+	auditOp = audit.Begin(audit.SourceCLI, "", command, recorded)
+}
+
+// endAudit records the outcome of the running command, warning instead of
+// failing
+func endAudit(err error) {
+	if auditOp == nil {
+		return
+	}
+	if aerr := auditOp.End(err); aerr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", aerr)
+	}
+}
+
+// parseSince parses --since as a duration such as 24h or 7d, a date or an
+// RFC 3339 time
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, use a duration like 24h or 7d, a date like 2024-01-31 or an RFC 3339 time", s)
+}
+
+func init() {
+	auditListCmd.Flags().StringVar(&auditSince, "since", "", "only show operations since a duration ago (24h, 7d), a date or an RFC 3339 time")
+	auditListCmd.Flags().StringVar(&auditSite, "site", "", "only show operations on this site")
+	auditListCmd.Flags().StringVar(&auditUser, "user", "", "only show operations by this user, including through sudo")
+	auditListCmd.Flags().BoolVar(&auditJSON, "json", false, "print entries as JSON lines")
+
+	auditCmd.AddCommand(auditListCmd)
+	rootCmd.AddCommand(auditCmd)
+}
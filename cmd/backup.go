@@ -1,27 +1,28 @@
 package cmd
 
 import (
-	"github.com/doko89/cliboard/internal/backup"
 	"github.com/spf13/cobra"
 )
 
 var enableBackupCmd = &cobra.Command{
-	Use:   "enable-backup [domain]",
-	Short: "Enable automatic site backup",
-	Args:  cobra.ExactArgs(1),
+	Use:               "enable-backup [domain]",
+	Short:             "Enable automatic site backup",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeNonBackupSites),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		return backup.EnableSite(domain)
+		return client.EnableBackup(cmd.Context(), domain)
 	},
 }
 
 var disableBackupCmd = &cobra.Command{
-	Use:   "disable-backup [domain]",
-	Short: "Disable automatic site backup",
-	Args:  cobra.ExactArgs(1),
+	Use:               "disable-backup [domain]",
+	Short:             "Disable automatic site backup",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeBackupSites),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		return backup.DisableSite(domain)
+		return client.DisableBackup(cmd.Context(), domain)
 	},
 }
 
@@ -30,7 +31,7 @@ var enableDbBackupCmd = &cobra.Command{
 	Short: "Enable automatic database backup",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return backup.EnableDatabase()
+		return client.EnableDatabaseBackup(cmd.Context())
 	},
 }
 
@@ -39,6 +40,6 @@ var disableDbBackupCmd = &cobra.Command{
 	Short: "Disable automatic database backup",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return backup.DisableDatabase()
+		return client.DisableDatabaseBackup(cmd.Context())
 	},
 }
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var caddyCmd = &cobra.Command{
+	Use:   "caddy",
+	Short: "Manage the Caddy binary",
+}
+
+var (
+	caddyBuildWith    []string
+	caddyBuildWithout []string
+)
+
+var caddyBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a custom Caddy binary with plugins using xcaddy",
+	Long: `Build a custom Caddy binary with xcaddy and swap it in.
+
+Plugins from previous builds are kept, so running "cliboard caddy build"
+without flags rebuilds the recorded plugin set, e.g. after a Caddy upgrade.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := client.BuildCaddy(cmd.Context(), caddyBuildWith, caddyBuildWithout)
+		return err
+	},
+}
+
+var caddyPluginsCmd = &cobra.Command{
+	Use:         "plugins",
+	Short:       "List plugins built into Caddy by cliboard",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins, err := client.CaddyPlugins(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if len(plugins) == 0 {
+			fmt.Println("No custom Caddy plugins")
+			return nil
+		}
+		fmt.Println("Custom Caddy plugins:")
+		for _, p := range plugins {
+			fmt.Printf("- %s\n", p)
+		}
+		return nil
+	},
+}
+
+func init() {
+	caddyBuildCmd.Flags().StringArrayVar(&caddyBuildWith, "with", nil, "plugin package to build in, e.g. github.com/mholt/caddy-ratelimit")
+	caddyBuildCmd.Flags().StringArrayVar(&caddyBuildWithout, "without", nil, "previously built plugin package to leave out")
+
+	caddyCmd.AddCommand(caddyBuildCmd)
+	caddyCmd.AddCommand(caddyPluginsCmd)
+	rootCmd.AddCommand(caddyCmd)
+}
@@ -2,14 +2,23 @@ package cmd
 
 import (
 	"os"
+	"strings"
 
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/doko89/cliboard/internal/hosts"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
 	"github.com/spf13/cobra"
 )
 
 // completionCmd represents the completion command
 var completionCmd = &cobra.Command{
-	Use:   "completion [bash|zsh|fish|powershell]",
-	Short: "Generate shell completion scripts",
+	Use:         "completion [bash|zsh|fish|powershell]",
+	Short:       "Generate shell completion scripts",
+	Annotations: readOnly,
 	Long: `To load completions:
 
 Bash:
@@ -59,6 +68,158 @@ PowerShell:
 	},
 }
 
+// Dynamic completion. Completion runs as cobra's __complete command, for which
+// the root's PersistentPreRunE records no history and no audit entry, and the
+// flags of the command being completed are only parsed afterwards. Each
+// function therefore loads the configuration itself, once --config, --root
+// and --set on the command line are parsed.
+
+// completionFunc returns the candidates for the argument at position
+type completionFunc func(args []string) []string
+
+// complete builds a ValidArgsFunction from one function per argument
+func complete(positions ...completionFunc) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= len(positions) || positions[len(args)] == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if err := config.Load(config.Options{File: configFile, Root: rootDir, Set: settings}); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveError
+		}
+
+		var matches []string
+		for _, candidate := range positions[len(args)](args) {
+			if strings.HasPrefix(candidate, toComplete) {
+				matches = append(matches, candidate)
+			}
+		}
+		return matches, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeFlag builds a flag completion function
+func completeFlag(candidates completionFunc) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return complete(candidates)(cmd, nil, toComplete)
+	}
+}
+
+// sitesWhere returns a completion of the domains of the sites matching keep
+func sitesWhere(keep func(site.Info) bool) completionFunc {
+	return func(args []string) []string {
+		sites, _ := site.List()
+		var domains []string
+		for _, s := range sites {
+			if keep == nil || keep(s) {
+				domains = append(domains, s.Domain)
+			}
+		}
+		return domains
+	}
+}
+
+var (
+	completeSites          = sitesWhere(nil)
+	completePHPSites       = sitesWhere(func(s site.Info) bool { return s.PHPVersion != "" })
+	completeBackupSites    = sitesWhere(func(s site.Info) bool { return s.Backup })
+	completeNonBackupSites = sitesWhere(func(s site.Info) bool { return !s.Backup })
+)
+
+// completeSiteModules returns the modules of modules.d that are enabled, or
+// not enabled, for the site in the first argument
+func completeSiteModules(enabled bool) completionFunc {
+	return func(args []string) []string {
+		info, err := site.Get(args[0])
+		if err != nil {
+			return nil
+		}
+		available, _ := module.Available()
+
+		var names []string
+		for _, name := range available {
+			if contains(info.Modules, name) == enabled {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+}
+
+func completeTemplates(args []string) []string {
+	var names []string
+	for _, t := range site.Templates() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func completeModules(args []string) []string {
+	names, _ := module.Available()
+	return names
+}
+
+func completePHPVersions(args []string) []string { return php.SupportedVersions }
+
+func completeInstalledPHP(args []string) []string   { return php.InstalledVersions() }
+func completeInstallablePHP(args []string) []string { return php.InstallableVersions() }
+
+func completeInstalledExtensions(args []string) []string {
+	modules, _ := php.InstalledModules(args[0])
+	return modules
+}
+
+func completeAvailableExtensions(args []string) []string {
+	packages, _ := php.AvailableModules(args[0])
+	var names []string
+	for _, p := range packages {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func completeHosts(args []string) []string {
+	list, _ := hosts.Hosts()
+	var names []string
+	for _, h := range list {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+// completeHostSelectors completes --hosts with all, the tags and the names
+func completeHostSelectors(args []string) []string {
+	list, _ := hosts.Hosts()
+	selectors := []string{"all"}
+	for _, h := range list {
+		for _, tag := range h.Tags {
+			if !contains(selectors, "tag:"+tag) {
+				selectors = append(selectors, "tag:"+tag)
+			}
+		}
+	}
+	return append(selectors, completeHosts(args)...)
+}
+
+func completeChannels(args []string) []string {
+	c, err := notify.LoadConfig()
+	if err != nil {
+		return nil
+	}
+	return c.ChannelNames()
+}
+
+func completeEvents(args []string) []string { return notify.Events }
+func completeHooks(args []string) []string  { return hook.Names() }
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(completionCmd)
 }
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect CLIBoard configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:         "show",
+	Short:       "Show the settings in effect",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if config.Root != "" {
+			fmt.Printf("root: %s\n", config.Root)
+		}
+		for _, kv := range config.Current().Values() {
+			fmt.Printf("%s: %s\n", kv[0], kv[1])
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
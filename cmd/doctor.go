@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorFix  bool
+	doctorJSON bool
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the installation for problems",
+	Long: `Check Caddy, site configurations, PHP-FPM, imported modules, backup
+cron jobs and file permissions. Exits with an error if any error-level
+problem remains, so it can be used from monitoring.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report := doctor.Run(doctorFix)
+
+		if doctorJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			printDoctorReport(report)
+		}
+
+		if problems := report.Problems(); problems > 0 {
+			return fmt.Errorf("doctor found %d problem(s)", problems)
+		}
+		return nil
+	},
+}
+
+func printDoctorReport(report doctor.Report) {
+	if len(report.Findings) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+
+	for _, f := range report.Findings {
+		status := ""
+		switch {
+		case f.Fixed:
+			status = " (fixed)"
+		case f.FixError != "":
+			status = fmt.Sprintf(" (fix failed: %s)", f.FixError)
+		case f.Fixable:
+			status = " (fixable with --fix)"
+		}
+
+		fmt.Printf("[%s] %s: %s%s\n", strings.ToUpper(f.Severity.String()), f.Check, f.Message, status)
+		if f.Path != "" {
+			fmt.Printf("    %s\n", f.Path)
+		}
+	}
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "apply safe automatic repairs")
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "print the report as JSON")
+	rootCmd.AddCommand(doctorCmd)
+}
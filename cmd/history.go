@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/history"
+	"github.com/doko89/cliboard/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show and roll back Caddy configuration changes",
+}
+
+var (
+	historyLimit int
+	historySite  string
+)
+
+var historyListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List recorded configuration changes",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := ""
+		if historySite != "" {
+			path = config.GetSiteConfigPath(historySite)
+		}
+
+		entries, err := history.List(historyLimit, path)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("No configuration changes recorded")
+			return nil
+		}
+
+		for _, e := range entries {
+			fmt.Printf("%s  %s  %-10s  %s\n", e.ID, e.Time.Format("2006-01-02 15:04:05"), e.User, e.Command)
+			for _, f := range e.Files {
+				fmt.Printf("    %s\n", f)
+			}
+		}
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:         "show [id]",
+	Short:       "Show a recorded configuration change",
+	Annotations: readOnly,
+	Args:        cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := history.Show(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+var historyDiffCmd = &cobra.Command{
+	Use:         "diff [id] [id]",
+	Short:       "Diff a recorded change against the current configuration or another change",
+	Annotations: readOnly,
+	Args:        cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to := ""
+		if len(args) == 2 {
+			to = args[1]
+		}
+
+		output, err := history.Diff(args[0], to)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+var historyRollbackCmd = &cobra.Command{
+	Use:   "rollback [id]",
+	Short: "Restore the configuration as it was after a recorded change",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+
+		path := ""
+		target := "the Caddy configuration"
+		if historySite != "" {
+			path = config.GetSiteConfigPath(historySite)
+			target = fmt.Sprintf("the configuration of site %s", historySite)
+		}
+
+		if !utils.AskForConfirmation(fmt.Sprintf("Roll back %s to %s?", target, id)) {
+			fmt.Println("Rollback cancelled")
+			return nil
+		}
+
+		if err := history.Rollback(id, path); err != nil {
+			return err
+		}
+
+		if err := caddy.Reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "Rolled back files, but Caddy rejected them; roll back further or fix them by hand\n")
+			return fmt.Errorf("failed to reload Caddy: %v", err)
+		}
+
+		fmt.Printf("Rolled back %s to %s\n", target, id)
+		return nil
+	},
+}
+
+// recordHistory records configuration changes, warning instead of failing
+func recordHistory(command string) {
+	if !history.Available() {
+		return
+	}
+	if err := history.Record(command); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record configuration history: %v\n", err)
+	}
+}
+
+func init() {
+	historyListCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "number of changes to show (0 for all)")
+	historyListCmd.Flags().StringVar(&historySite, "site", "", "only show changes to this site")
+	historyRollbackCmd.Flags().StringVar(&historySite, "site", "", "only roll back this site")
+
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyShowCmd)
+	historyCmd.AddCommand(historyDiffCmd)
+	historyCmd.AddCommand(historyRollbackCmd)
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/spf13/cobra"
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "List and run lifecycle hooks",
+	Long: `List and run lifecycle hooks. Executables in hooks/<hook>.d/ in the
+configuration directory run for every site, those in
+hooks/sites/<domain>/<hook>.d/ for one site. Each gets the event as JSON on
+stdin and CLIBOARD_HOOK, CLIBOARD_SITE, CLIBOARD_BIN and the settings in its
+environment, like plugins.
+
+A pre- hook exiting with a non-zero status cancels the operation. Output of
+every hook is appended to hooks.log in the log directory.
+
+Hooks: ` + strings.Join(hook.Names(), ", "),
+}
+
+var (
+	hookSite string
+	hookData []string
+)
+
+var hooksListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List the installed hooks",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		found := false
+		for _, name := range hook.Names() {
+			for _, path := range hook.List(name, hookSite) {
+				fmt.Printf("%-20s %s\n", name, path)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Printf("No hooks installed in %s\n", hook.Dir())
+		}
+		return nil
+	},
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:               "run [hook]",
+	Short:             "Run the hooks of an event, e.g. pre-deploy from deploy tooling",
+	Annotations:       readOnly,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeHooks),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data := map[string]string{}
+		for _, kv := range hookData {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("invalid data %q, expected key=value", kv)
+			}
+			data[key] = value
+		}
+		return hook.Run(args[0], hookSite, data)
+	},
+}
+
+func init() {
+	hooksListCmd.Flags().StringVar(&hookSite, "site", "", "include the hooks of this site")
+	hooksRunCmd.Flags().StringVar(&hookSite, "site", "", "site the event is about")
+	hooksRunCmd.Flags().StringArrayVar(&hookData, "data", nil, "detail passed to the hooks as key=value")
+
+	hooksCmd.AddCommand(hooksListCmd)
+	hooksCmd.AddCommand(hooksRunCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/hosts"
+	"github.com/spf13/cobra"
+)
+
+var hostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Manage the inventory of remote servers",
+	Long: `Manage the inventory of remote servers that --host and --hosts run
+commands on over SSH. cliboard must be installed on each server.`,
+}
+
+var (
+	hostPort     int
+	hostIdentity string
+	hostOptions  []string
+	hostTags     []string
+	hostSudo     bool
+	hostBinary   string
+	hostsJSON    bool
+)
+
+var hostsAddCmd = &cobra.Command{
+	Use:   "add [name] [[user@]address]",
+	Short: "Add a server to the inventory",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		h := hosts.Host{
+			Name:     args[0],
+			Address:  args[1],
+			Port:     hostPort,
+			Identity: hostIdentity,
+			Options:  hostOptions,
+			Tags:     hostTags,
+			Sudo:     hostSudo,
+			Binary:   hostBinary,
+		}
+		if err := hosts.AddHost(h); err != nil {
+			return err
+		}
+		fmt.Printf("Host %s added\n", h.Name)
+		return nil
+	},
+}
+
+var hostsListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List the servers in the inventory",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := hosts.Hosts()
+		if err != nil {
+			return err
+		}
+
+		if hostsJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if list == nil {
+				list = []hosts.Host{}
+			}
+			return encoder.Encode(list)
+		}
+
+		if len(list) == 0 {
+			fmt.Println("No hosts in the inventory")
+			return nil
+		}
+		for _, h := range list {
+			address := h.Address
+			if h.Port != 0 {
+				address += fmt.Sprintf(" port %d", h.Port)
+			}
+			tags := ""
+			if len(h.Tags) > 0 {
+				tags = " [" + strings.Join(h.Tags, ", ") + "]"
+			}
+			fmt.Printf("- %s: %s%s\n", h.Name, address, tags)
+		}
+		return nil
+	},
+}
+
+var hostsRemoveCmd = &cobra.Command{
+	Use:               "remove [name]",
+	Short:             "Remove a server from the inventory",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeHosts),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := hosts.RemoveHost(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Host %s removed\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	hostsAddCmd.Flags().IntVarP(&hostPort, "port", "p", 0, "SSH port (default from ssh)")
+	hostsAddCmd.Flags().StringVarP(&hostIdentity, "identity", "i", "", "SSH private key")
+	hostsAddCmd.Flags().StringArrayVarP(&hostOptions, "ssh-option", "o", nil, "extra ssh option, e.g. -o StrictHostKeyChecking=accept-new")
+	hostsAddCmd.Flags().StringSliceVar(&hostTags, "tag", nil, "tag for selecting the host with --hosts tag:<tag>")
+	hostsAddCmd.Flags().BoolVar(&hostSudo, "sudo", false, "run cliboard through sudo -n on the host")
+	hostsAddCmd.Flags().StringVar(&hostBinary, "binary", "", "path of cliboard on the host (default cliboard)")
+	hostsListCmd.Flags().BoolVar(&hostsJSON, "json", false, "print hosts as JSON")
+
+	hostsCmd.AddCommand(hostsAddCmd)
+	hostsCmd.AddCommand(hostsListCmd)
+	hostsCmd.AddCommand(hostsRemoveCmd)
+	rootCmd.AddCommand(hostsCmd)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/doko89/cliboard/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Export metrics for Prometheus",
+}
+
+var (
+	metricsListen   string
+	metricsUpstream string
+)
+
+var metricsServeCmd = &cobra.Command{
+	Use:         "serve",
+	Short:       "Serve /metrics for Prometheus",
+	Annotations: readOnly,
+	Long: `Serve /metrics for Prometheus on a loopback address.
+
+To scrape from another server, add the metrics module to a site. It proxies
+/metrics to this address for loopback and private addresses, the same as
+the local-access module, and refuses everyone else.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return metrics.Serve(metricsListen)
+	},
+}
+
+var metricsPrintCmd = &cobra.Command{
+	Use:         "print",
+	Short:       "Print the metrics once, e.g. for the node_exporter textfile collector",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return metrics.NewHandler().Write(os.Stdout)
+	},
+}
+
+var metricsModuleCmd = &cobra.Command{
+	Use:   "install-module",
+	Short: "Write the metrics Caddy module for the address metrics serve listens on",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := metrics.InstallModule(metricsUpstream); err != nil {
+			return err
+		}
+		fmt.Printf("Module metrics proxies /metrics to %s\n", metricsUpstream)
+		return nil
+	},
+}
+
+func init() {
+	metricsServeCmd.Flags().StringVar(&metricsListen, "listen", metrics.DefaultListen, "loopback address to listen on")
+	metricsModuleCmd.Flags().StringVar(&metricsUpstream, "upstream", metrics.DefaultListen, "address metrics serve listens on")
+
+	metricsCmd.AddCommand(metricsServeCmd)
+	metricsCmd.AddCommand(metricsPrintCmd)
+	metricsCmd.AddCommand(metricsModuleCmd)
+	rootCmd.AddCommand(metricsCmd)
+}
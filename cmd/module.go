@@ -1,47 +1,292 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/doko89/cliboard/internal/config"
 	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/utils"
 	"github.com/spf13/cobra"
 )
 
 var addModuleCmd = &cobra.Command{
-	Use:   "add-module [domain] [module]",
-	Short: "Add a Caddy module to a site",
-	Args:  cobra.ExactArgs(2),
+	Use:               "add-module [domain] [module]",
+	Short:             "Add a Caddy module to a site",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: complete(completeSites, completeSiteModules(false)),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
 		moduleName := args[1]
-		return module.Add(domain, moduleName)
+		_, err := client.EnableModule(cmd.Context(), domain, moduleName)
+		return err
 	},
 }
 
 var removeModuleCmd = &cobra.Command{
-	Use:   "remove-module [domain] [module]",
-	Short: "Remove a Caddy module from a site",
-	Args:  cobra.ExactArgs(2),
+	Use:               "remove-module [domain] [module]",
+	Short:             "Remove a Caddy module from a site",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: complete(completeSites, completeSiteModules(true)),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
 		moduleName := args[1]
-		return module.Remove(domain, moduleName)
+		_, err := client.DisableModule(cmd.Context(), domain, moduleName)
+		return err
 	},
 }
 
 var listModulesCmd = &cobra.Command{
-	Use:   "list-modules [domain]",
-	Short: "List active modules for a site",
-	Args:  cobra.ExactArgs(1),
+	Use:               "list-modules [domain]",
+	Short:             "List active modules for a site",
+	Annotations:       readOnly,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeSites),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		return module.List(domain)
+		site, err := client.Site(cmd.Context(), domain)
+		if err != nil {
+			return err
+		}
+
+		if len(site.Modules) == 0 {
+			fmt.Printf("No active modules for site %s\n", domain)
+			return nil
+		}
+		fmt.Printf("Active modules for site %s:\n", domain)
+		for _, module := range site.Modules {
+			fmt.Printf("- %s\n", module)
+		}
+		return nil
 	},
 }
 
 var listAvailableModulesCmd = &cobra.Command{
-	Use:   "list-available-modules",
-	Short: "List all available Caddy modules",
-	Args:  cobra.NoArgs,
+	Use:         "list-available-modules",
+	Short:       "List all available Caddy modules",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		modules, err := client.Modules(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(modules) == 0 {
+			fmt.Println("No available modules found")
+			return nil
+		}
+		fmt.Println("Available Caddy modules:")
+		for _, module := range modules {
+			if len(module.MissingPlugins) > 0 {
+				fmt.Printf("- %s (needs Caddy plugins, see add-module)\n", module.Name)
+				continue
+			}
+			fmt.Printf("- %s\n", module.Name)
+		}
+		return nil
+	},
+}
+
+var moduleCmd = &cobra.Command{
+	Use:   "module",
+	Short: "Create, edit, delete and show Caddy modules",
+	Long: `Create, edit, delete and show the Caddy modules in modules.d.
+
+A module is a single snippet block named after the module, for example
+"(name) { ... }", optionally preceded by comments such as
+"# cliboard:requires <caddy module id> [<plugin package>]". Modules are
+checked against that shape and validated with Caddy before they are saved,
+so a rejected module leaves the previous one in place.
+
+Without --from-file or --editor, create and edit open $VISUAL or $EDITOR
+when run from a terminal and read the module from stdin otherwise.`,
+}
+
+var (
+	moduleFromFile string
+	moduleEditor   bool
+	moduleJSON     bool
+)
+
+var moduleCreateCmd = &cobra.Command{
+	Use:               "create [name]",
+	Short:             "Create a Caddy module",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := module.ValidName(name); err != nil {
+			return err
+		}
+		if utils.FileExists(config.GetModulePath(name)) {
+			return fmt.Errorf("module %s already exists", name)
+		}
+
+		skeleton := []byte(fmt.Sprintf("(%s) {\n    \n}\n", name))
+		return writeModule(name, skeleton, func(content []byte) error {
+			return client.CreateModule(cmd.Context(), name, content)
+		})
+	},
+}
+
+var moduleEditCmd = &cobra.Command{
+	Use:               "edit [name]",
+	Short:             "Edit a Caddy module",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeModules),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		m, err := client.Module(cmd.Context(), name)
+		if err != nil {
+			return err
+		}
+		return writeModule(name, []byte(m.Content), func(content []byte) error {
+			return client.UpdateModule(cmd.Context(), name, content)
+		})
+	},
+}
+
+var moduleDeleteCmd = &cobra.Command{
+	Use:               "delete [name]",
+	Short:             "Delete a Caddy module that no site uses",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeModules),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return client.DeleteModule(cmd.Context(), args[0])
+	},
+}
+
+var moduleShowCmd = &cobra.Command{
+	Use:               "show [name]",
+	Short:             "Show a Caddy module",
+	Annotations:       readOnly,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeModules),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return module.ListAvailable()
+		m, err := client.Module(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		if moduleJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(m)
+		}
+		fmt.Print(m.Content)
+		return nil
 	},
 }
+
+// writeModule saves a module read from --from-file or stdin, or written in
+// an editor starting from initial
+func writeModule(name string, initial []byte, save func([]byte) error) error {
+	switch {
+	case moduleFromFile != "":
+		content, err := readModuleFile(moduleFromFile)
+		if err != nil {
+			return err
+		}
+		return save(content)
+	case !moduleEditor && !utils.Interactive():
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read module from stdin: %v", err)
+		}
+		return save(content)
+	}
+	return editModule(name, initial, save)
+}
+
+func readModuleFile(path string) ([]byte, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read module from stdin: %v", err)
+		}
+		return content, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return content, nil
+}
+
+// editModule opens the module in the user's editor and saves it, offering to
+// edit it again when it is rejected so no work is lost
+func editModule(name string, initial []byte, save func([]byte) error) error {
+	tmp, err := os.CreateTemp("", "cliboard-module-"+name+"-*.caddy")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	path := tmp.Name()
+	tmp.Close()
+	defer os.Remove(path)
+
+	if err := os.WriteFile(path, initial, 0600); err != nil {
+		return fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	for {
+		if err := runEditor(path); err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read temporary file: %v", err)
+		}
+		if bytes.Equal(content, initial) {
+			fmt.Printf("Module %s left unchanged, nothing saved\n", name)
+			return nil
+		}
+
+		err = save(content)
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !utils.AskForConfirmation("Edit the module again?") {
+			return err
+		}
+	}
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, falling back to vi. The
+// variable may hold arguments, as in "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	c := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %v", editor, err)
+	}
+	return nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{moduleCreateCmd, moduleEditCmd} {
+		c.Flags().StringVar(&moduleFromFile, "from-file", "", "read the module from this file (- for stdin)")
+		c.Flags().BoolVar(&moduleEditor, "editor", false, "write the module in $VISUAL or $EDITOR")
+		c.MarkFlagsMutuallyExclusive("from-file", "editor")
+	}
+	moduleShowCmd.Flags().BoolVar(&moduleJSON, "json", false, "print the module and the sites and modules using it as JSON")
+
+	moduleCmd.AddCommand(moduleCreateCmd)
+	moduleCmd.AddCommand(moduleEditCmd)
+	moduleCmd.AddCommand(moduleDeleteCmd)
+	moduleCmd.AddCommand(moduleShowCmd)
+	rootCmd.AddCommand(moduleCmd)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/doko89/cliboard/internal/monitor"
+	"github.com/spf13/cobra"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Monitor the sites over HTTP(S)",
+	Long: `Monitor the sites over HTTP(S). Every site is requested from the local
+server with its own Host header and TLS server name, and its latency and
+up/down state are recorded. Sites going down or coming back are sent as
+site_down to the notification channels.
+
+Paths, expected status codes and content matches are configured in
+monitor.yaml in the configuration directory.`,
+}
+
+var (
+	monitorJSON     bool
+	monitorQuiet    bool
+	monitorSchedule string
+)
+
+var monitorCheckCmd = &cobra.Command{
+	Use:         "check",
+	Short:       "Probe every site once",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses, err := monitor.Check()
+		if statuses != nil && !monitorQuiet {
+			if perr := printStatuses(statuses); perr != nil {
+				return perr
+			}
+		}
+		return err
+	},
+}
+
+var monitorRunCmd = &cobra.Command{
+	Use:         "run",
+	Short:       "Probe the sites continuously, for running as a service",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return monitor.Run(func(statuses []monitor.Status, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "monitor: %v\n", err)
+			}
+			for _, s := range statuses {
+				if len(s.Changes) > 0 && s.Changes[len(s.Changes)-1].Time.Equal(s.Checked) {
+					fmt.Printf("%s is %s: %s\n", s.Domain, s.State, describeStatus(s))
+				}
+			}
+		})
+	},
+}
+
+var monitorStatusCmd = &cobra.Command{
+	Use:         "status",
+	Short:       "Show the state recorded by the last check",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses, err := monitor.Statuses()
+		if err != nil {
+			return err
+		}
+		return printStatuses(statuses)
+	},
+}
+
+var monitorEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Check the sites periodically from cron",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := monitor.Schedule(monitorSchedule); err != nil {
+			return err
+		}
+		fmt.Printf("Site monitoring scheduled (%s)\n", monitorSchedule)
+		return nil
+	},
+}
+
+var monitorDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop checking the sites from cron",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := monitor.Unschedule(); err != nil {
+			return err
+		}
+		fmt.Println("Site monitoring unscheduled")
+		return nil
+	},
+}
+
+func printStatuses(statuses []monitor.Status) error {
+	if monitorJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	}
+
+	if len(statuses) == 0 {
+		fmt.Println("No sites monitored yet")
+		return nil
+	}
+	for _, s := range statuses {
+		fmt.Printf("%-30s %-7s since %-16s %5dms avg %5dms  %s\n", s.Domain, s.State,
+			s.Since.Local().Format("2006-01-02 15:04"), s.LatencyMS, s.AverageMS(), describeStatus(s))
+	}
+	return nil
+}
+
+// describeStatus summarizes the last probe of a site
+func describeStatus(s monitor.Status) string {
+	switch {
+	case s.Error != "":
+		return s.Error
+	case s.Code != 0:
+		return fmt.Sprintf("status %d", s.Code)
+	}
+	return "checked " + s.Checked.Local().Format(time.RFC3339)
+}
+
+func init() {
+	monitorCheckCmd.Flags().BoolVar(&monitorJSON, "json", false, "print the results as JSON")
+	monitorCheckCmd.Flags().BoolVarP(&monitorQuiet, "quiet", "q", false, "only print errors")
+	monitorStatusCmd.Flags().BoolVar(&monitorJSON, "json", false, "print the state as JSON")
+	monitorEnableCmd.Flags().StringVar(&monitorSchedule, "schedule", monitor.DefaultSchedule, "cron schedule of the checks")
+
+	monitorCmd.AddCommand(monitorCheckCmd)
+	monitorCmd.AddCommand(monitorRunCmd)
+	monitorCmd.AddCommand(monitorStatusCmd)
+	monitorCmd.AddCommand(monitorEnableCmd)
+	monitorCmd.AddCommand(monitorDisableCmd)
+	rootCmd.AddCommand(monitorCmd)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/health"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Send alerts to webhooks, Slack, email, Telegram or ntfy",
+	Long: `Send alerts about failed backups, full disks, expiring certificates and
+stopped services. Channels and the events routed to them are configured in
+notify.yaml in the configuration directory.`,
+}
+
+var (
+	notifySubject  string
+	notifyMessage  string
+	notifyJSON     bool
+	notifyQuiet    bool
+	notifySchedule string
+)
+
+var notifyChannelsCmd = &cobra.Command{
+	Use:         "channels",
+	Short:       "List the configured channels and the events routed to them",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := notify.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		names := c.ChannelNames()
+		if len(names) == 0 {
+			fmt.Println("No notification channels configured")
+			return nil
+		}
+		for _, name := range names {
+			var events []string
+			for _, r := range c.Routes {
+				for _, ch := range r.Channels {
+					if ch == name {
+						events = append(events, r.Events...)
+					}
+				}
+			}
+			if len(events) == 0 {
+				events = []string{"no events"}
+			}
+			fmt.Printf("- %s (%s): %s\n", name, c.Channels[name].Type, strings.Join(events, ", "))
+		}
+		return nil
+	},
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:               "test [channel]",
+	Short:             "Send a test message to a channel",
+	Annotations:       readOnly,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeChannels),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := notify.Test(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Test message sent to %s\n", args[0])
+		return nil
+	},
+}
+
+var notifySendCmd = &cobra.Command{
+	Use:   "send [event]",
+	Short: "Send an event to the channels it is routed to",
+	Long: `Send an event to the channels it is routed to. Cron jobs installed by
+cliboard use it to report failures. Events: ` + strings.Join(notify.Events, ", "),
+	Annotations:       readOnly,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeEvents),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !notify.Known(args[0]) {
+			return fmt.Errorf("unknown event %s, expected one of %s", args[0], strings.Join(notify.Events, ", "))
+		}
+
+		message := notifyMessage
+		if message == "" {
+			message = strings.ReplaceAll(args[0], "_", " ")
+		}
+		return notify.Send(notify.Event{Name: args[0], Subject: notifySubject, Message: message})
+	},
+}
+
+var notifyCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check backups, disks, certificates and PHP-FPM and send alerts",
+	Long: `Check backups, disk usage, certificates and PHP-FPM services. New problems
+are sent to the channels they are routed to, problems that persist are sent
+again after the repeat interval, and problems that cleared are sent as
+resolved.`,
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		events, err := health.Run()
+
+		if notifyJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if events == nil {
+				events = []notify.Event{}
+			}
+			if err := encoder.Encode(events); err != nil {
+				return err
+			}
+		} else if !notifyQuiet {
+			if len(events) == 0 {
+				fmt.Println("No problems found")
+			}
+			for _, e := range events {
+				fmt.Printf("- %s %s: %s\n", e.Name, e.Subject, e.Message)
+			}
+		}
+		return err
+	},
+}
+
+var notifyEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Run the checks periodically from cron",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := health.Schedule(notifySchedule); err != nil {
+			return err
+		}
+		fmt.Printf("Health checks scheduled (%s)\n", notifySchedule)
+		return nil
+	},
+}
+
+var notifyDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop running the checks from cron",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := health.Unschedule(); err != nil {
+			return err
+		}
+		fmt.Println("Health checks unscheduled")
+		return nil
+	},
+}
+
+func init() {
+	notifySendCmd.Flags().StringVar(&notifySubject, "subject", "", "what the event is about, e.g. a domain")
+	notifySendCmd.Flags().StringVar(&notifyMessage, "message", "", "description of the event")
+	notifyCheckCmd.Flags().BoolVar(&notifyJSON, "json", false, "print the problems found as JSON")
+	notifyCheckCmd.Flags().BoolVarP(&notifyQuiet, "quiet", "q", false, "only print errors")
+	notifyEnableCmd.Flags().StringVar(&notifySchedule, "schedule", health.DefaultSchedule, "cron schedule of the checks")
+
+	notifyCmd.AddCommand(notifyChannelsCmd)
+	notifyCmd.AddCommand(notifyTestCmd)
+	notifyCmd.AddCommand(notifySendCmd)
+	notifyCmd.AddCommand(notifyCheckCmd)
+	notifyCmd.AddCommand(notifyEnableCmd)
+	notifyCmd.AddCommand(notifyDisableCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/doko89/cliboard/internal/panel"
+	"github.com/doko89/cliboard/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var panelCmd = &cobra.Command{
+	Use:   "panel",
+	Short: "Run and manage the web control panel",
+}
+
+var panelListen string
+
+var panelServeCmd = &cobra.Command{
+	Use:         "serve",
+	Short:       "Serve the web control panel",
+	Annotations: readOnly,
+	Long: `Serve the web control panel.
+
+The panel listens on a loopback address and is reached through the Caddy
+site created by "cliboard panel setup", which provides HTTPS.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return panel.Serve(panelListen)
+	},
+}
+
+var panelSetupCmd = &cobra.Command{
+	Use:   "setup [domain]",
+	Short: "Create the Caddy site that serves the panel",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return panel.Setup(args[0], panelListen)
+	},
+}
+
+var panelTeardownCmd = &cobra.Command{
+	Use:   "teardown",
+	Short: "Remove the Caddy site that serves the panel",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return panel.Teardown()
+	},
+}
+
+var panelUserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage panel users",
+}
+
+var panelUserAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a panel user",
+	Long: `Add a panel user.
+
+The password is prompted for, or read from the first line of stdin when it
+is not a terminal. The printed otpauth URI enrolls the user's TOTP secret in
+an authenticator app; logging in requires its codes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password, err := utils.ReadPassword("Password: ")
+		if err != nil {
+			return err
+		}
+		uri, err := panel.AddUser(args[0], password)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Panel user %s added\n", args[0])
+		fmt.Printf("Add this to your authenticator app:\n%s\n", uri)
+		return nil
+	},
+}
+
+var panelUserRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a panel user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := panel.RemoveUser(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Panel user %s removed\n", args[0])
+		return nil
+	},
+}
+
+var panelUserListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List panel users",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		users, err := panel.Users()
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			fmt.Println("No panel users")
+			return nil
+		}
+		fmt.Println("Panel users:")
+		for _, u := range users {
+			fmt.Printf("- %s\n", u.Name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	panelCmd.PersistentFlags().StringVar(&panelListen, "listen", panel.DefaultListen, "address the panel listens on")
+
+	panelUserCmd.AddCommand(panelUserAddCmd)
+	panelUserCmd.AddCommand(panelUserRemoveCmd)
+	panelUserCmd.AddCommand(panelUserListCmd)
+
+	panelCmd.AddCommand(panelServeCmd)
+	panelCmd.AddCommand(panelSetupCmd)
+	panelCmd.AddCommand(panelTeardownCmd)
+	panelCmd.AddCommand(panelUserCmd)
+	rootCmd.AddCommand(panelCmd)
+}
@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"github.com/doko89/cliboard/internal/php"
+	"fmt"
+
 	"github.com/spf13/cobra"
 )
 
@@ -11,61 +12,84 @@ var phpCmd = &cobra.Command{
 }
 
 var enablePhpCmd = &cobra.Command{
-	Use:   "enable-php [domain] [version]",
-	Short: "Enable PHP for a site with specified version",
-	Args:  cobra.ExactArgs(2),
+	Use:               "enable-php [domain] [version]",
+	Short:             "Enable PHP for a site with specified version",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: complete(completeSites, completeInstalledPHP),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
 		version := args[1]
-		return php.Enable(domain, version)
+		_, err := client.EnablePHP(cmd.Context(), domain, version)
+		return err
 	},
 }
 
 var disablePhpCmd = &cobra.Command{
-	Use:   "disable-php [domain]",
-	Short: "Disable PHP for a site",
-	Args:  cobra.ExactArgs(1),
+	Use:               "disable-php [domain]",
+	Short:             "Disable PHP for a site",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completePHPSites),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		return php.Disable(domain)
+		_, err := client.DisablePHP(cmd.Context(), domain)
+		return err
 	},
 }
 
 var updatePhpCmd = &cobra.Command{
-	Use:   "update-php [domain]",
-	Short: "Update PHP configuration for a site",
-	Args:  cobra.ExactArgs(1),
+	Use:               "update-php [domain]",
+	Short:             "Update PHP configuration for a site",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completePHPSites),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		return php.Update(domain)
+		_, err := client.UpdatePHP(cmd.Context(), domain)
+		return err
 	},
 }
 
 var phpInstallCmd = &cobra.Command{
-	Use:   "install [version]",
-	Short: "Install a specific PHP version",
-	Args:  cobra.ExactArgs(1),
+	Use:               "install [version]",
+	Short:             "Install a specific PHP version",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeInstallablePHP),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		version := args[0]
-		return php.Install(version)
+		_, err := client.InstallPHP(cmd.Context(), version)
+		return err
 	},
 }
 
 var phpUninstallCmd = &cobra.Command{
-	Use:   "uninstall [version]",
-	Short: "Uninstall a specific PHP version",
-	Args:  cobra.ExactArgs(1),
+	Use:               "uninstall [version]",
+	Short:             "Uninstall a specific PHP version",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeInstalledPHP),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		version := args[0]
-		return php.Uninstall(version)
+		return client.UninstallPHP(cmd.Context(), version)
 	},
 }
 
 var phpListInstalledCmd = &cobra.Command{
-	Use:   "list-installed",
-	Short: "List installed PHP versions",
+	Use:         "list-installed",
+	Short:       "List installed PHP versions",
+	Annotations: readOnly,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return php.ListInstalled()
+		versions, err := client.PHPVersions(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(versions) == 0 {
+			fmt.Println("No PHP versions installed")
+			return nil
+		}
+		fmt.Println("Installed PHP versions:")
+		for _, v := range versions {
+			fmt.Printf("- %s\n", v.Version)
+		}
+		return nil
 	},
 }
 
@@ -75,34 +99,55 @@ var phpModuleCmd = &cobra.Command{
 }
 
 var phpModuleListAvailableCmd = &cobra.Command{
-	Use:   "list-available [version]",
-	Short: "List available modules for a PHP version",
-	Args:  cobra.ExactArgs(1),
+	Use:               "list-available [version]",
+	Short:             "List available modules for a PHP version",
+	Annotations:       readOnly,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeInstalledPHP),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		version := args[0]
-		return php.ListAvailableModules(version)
+		extensions, err := client.PHPExtensions(cmd.Context(), version)
+		if err != nil {
+			return err
+		}
+
+		if len(extensions) == 0 {
+			fmt.Printf("No available modules found for PHP %s\n", version)
+			return nil
+		}
+		fmt.Printf("Available modules for PHP %s:\n", version)
+		for _, ext := range extensions {
+			if ext.Description != "" {
+				fmt.Printf("- %s: %s\n", ext.Name, ext.Description)
+			} else {
+				fmt.Printf("- %s\n", ext.Name)
+			}
+		}
+		return nil
 	},
 }
 
 var phpModuleAddCmd = &cobra.Command{
-	Use:   "add [version] [module]",
-	Short: "Add a module to a PHP version",
-	Args:  cobra.ExactArgs(2),
+	Use:               "add [version] [module]",
+	Short:             "Add a module to a PHP version",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: complete(completeInstalledPHP, completeAvailableExtensions),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		version := args[0]
 		module := args[1]
-		return php.AddModule(version, module)
+		return client.AddPHPExtension(cmd.Context(), version, module)
 	},
 }
 
 var phpModuleRemoveCmd = &cobra.Command{
-	Use:   "remove [version] [module]",
-	Short: "Remove a module from a PHP version",
-	Args:  cobra.ExactArgs(2),
+	Use:               "remove [version] [module]",
+	Short:             "Remove a module from a PHP version",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: complete(completeInstalledPHP, completeInstalledExtensions),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		version := args[0]
 		module := args[1]
-		return php.RemoveModule(version, module)
+		return client.RemovePHPExtension(cmd.Context(), version, module)
 	},
 }
 
@@ -110,12 +155,12 @@ func init() {
 	rootCmd.AddCommand(enablePhpCmd)
 	rootCmd.AddCommand(disablePhpCmd)
 	rootCmd.AddCommand(updatePhpCmd)
-	
+
 	phpCmd.AddCommand(phpInstallCmd)
 	phpCmd.AddCommand(phpUninstallCmd)
 	phpCmd.AddCommand(phpListInstalledCmd)
 	phpCmd.AddCommand(phpModuleCmd)
-	
+
 	phpModuleCmd.AddCommand(phpModuleListAvailableCmd)
 	phpModuleCmd.AddCommand(phpModuleAddCmd)
 	phpModuleCmd.AddCommand(phpModuleRemoveCmd)
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/plugin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Manage plugins providing custom subcommands",
+	Long: `Manage plugins providing custom subcommands. An executable named
+cliboard-<name> in the plugins directory or on PATH runs as cliboard <name>,
+with the configuration described in its environment:
+
+  CLIBOARD_BIN          the cliboard binary running the plugin
+  CLIBOARD_PLUGIN       the plugin name
+  CLIBOARD_ROOT         the --root directory, if any
+  CLIBOARD_SITES_ROOT   every setting, as listed by config show, under its
+  CLIBOARD_CONFIG_DIR   environment variable; paths are without CLIBOARD_ROOT
+  ...
+  CLIBOARD_SITE         the site named by the first argument, if any
+  CLIBOARD_SITE_JSON    that site as list-sites --json describes it
+  CLIBOARD_API_SOCKET   the API socket, when the API is running`,
+}
+
+var (
+	pluginName string
+	pluginJSON bool
+)
+
+var pluginListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List the plugins found",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins := plugin.List()
+		if pluginJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(plugins)
+		}
+
+		if len(plugins) == 0 {
+			fmt.Printf("No plugins found in %s or on PATH\n", plugin.Dir())
+			return nil
+		}
+		for _, p := range plugins {
+			note := ""
+			if builtin(p.Name) {
+				note = " (shadowed by the built-in command)"
+			}
+			fmt.Printf("- %s: %s%s\n", p.Name, p.Path, note)
+		}
+		return nil
+	},
+}
+
+var pluginInstallCmd = &cobra.Command{
+	Use:   "install [file]",
+	Short: "Install an executable as a plugin",
+	Long: `Install an executable as a plugin by copying it to the plugins directory.
+The plugin is named after the file without its cliboard- prefix unless
+--name is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := plugin.Install(args[0], pluginName)
+		if err != nil {
+			return err
+		}
+		if builtin(p.Name) {
+			fmt.Printf("Warning: the built-in command %s takes precedence over the plugin\n", p.Name)
+		}
+		fmt.Printf("Plugin %s installed, run it with cliboard %s\n", p.Name, p.Name)
+		return nil
+	},
+}
+
+var pluginRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a plugin from the plugins directory",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := plugin.Remove(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Plugin %s removed\n", args[0])
+		return nil
+	},
+}
+
+// builtin reports whether name is a command of cliboard itself
+func builtin(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			if _, ok := c.Annotations[pluginAnnotation]; !ok {
+				return true
+			}
+		}
+	}
+	return name == "help"
+}
+
+// pluginAnnotation marks the commands that run plugins
+const pluginAnnotation = "cliboard.plugin"
+
+// addPluginCommands registers a command for every plugin that does not
+// clash with a built-in command, so plugins show up in help and completion
+func addPluginCommands() {
+	preloadConfig()
+
+	for _, p := range plugin.List() {
+		if builtin(p.Name) {
+			continue
+		}
+		p := p
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                p.Name,
+			Short:              "Plugin " + p.Path,
+			GroupID:            pluginGroup.ID,
+			Annotations:        map[string]string{pluginAnnotation: p.Path},
+			DisableFlagParsing: true,
+			SilenceUsage:       true,
+			SilenceErrors:      true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runPlugin(p, pluginArgs(args))
+			},
+		})
+	}
+}
+
+var pluginGroup = &cobra.Group{ID: "plugins", Title: "Plugins:"}
+
+// preloadConfig loads the configuration from --config, --root and --set
+// before the command line is parsed, to find the plugins directory. Flags of
+// plugins are not parsed at all, so these are kept for their run as well.
+func preloadConfig() {
+	flags := pflag.NewFlagSet("cliboard", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.SetInterspersed(false)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&configFile, "config", "", "")
+	flags.StringVar(&rootDir, "root", "", "")
+	flags.StringArrayVar(&settings, "set", nil, "")
+	flags.BoolP("help", "h", false, "")
+	flags.Parse(os.Args[1:])
+
+	config.Load(config.Options{File: configFile, Root: rootDir, Set: settings})
+}
+
+// pluginArgs drops the global flags given before the plugin name, which
+// cobra passes on as flag parsing is disabled for plugins
+func pluginArgs(args []string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		flag := rootCmd.PersistentFlags().Lookup(name)
+		if flag == nil {
+			break
+		}
+		args = args[1:]
+		if !hasValue && flag.Value.Type() != "bool" && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	return args
+}
+
+// runPlugin runs a plugin, passing on its exit status
+func runPlugin(p plugin.Plugin, args []string) error {
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+
+	err = p.Command(args, self).Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		if exit.ExitCode() < 0 {
+			return fmt.Errorf("plugin %s: %v", p.Name, exit)
+		}
+		return &exitError{code: exit.ExitCode(), err: fmt.Errorf("plugin %s exited with status %d", p.Name, exit.ExitCode())}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run plugin %s: %v", p.Name, err)
+	}
+	return nil
+}
+
+// exitError is an error with the exit status cliboard should exit with
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+// ExitCode returns the exit status for an error returned by Execute
+func ExitCode(err error) int {
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	return 1
+}
+
+func init() {
+	pluginInstallCmd.Flags().StringVar(&pluginName, "name", "", "plugin name (default from the file name)")
+	pluginListCmd.Flags().BoolVar(&pluginJSON, "json", false, "print plugins as JSON")
+
+	pluginCmd.AddCommand(pluginListCmd)
+	pluginCmd.AddCommand(pluginInstallCmd)
+	pluginCmd.AddCommand(pluginRemoveCmd)
+	rootCmd.AddCommand(pluginCmd)
+	rootCmd.AddGroup(pluginGroup)
+}
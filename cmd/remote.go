@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/hosts"
+	"github.com/doko89/cliboard/pkg/cliboard"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// Remote execution flags
+var (
+	remoteHost     string
+	remoteHosts    string
+	remoteParallel int
+)
+
+// localFlags are handled locally and not passed on to the hosts, which
+// use their own configuration
+var localFlags = map[string]bool{"host": true, "hosts": true, "parallel": true, "config": true, "root": true, "set": true}
+
+// remote reports whether the command should run on other hosts
+func remote() bool {
+	return remoteHost != "" || remoteHosts != ""
+}
+
+// runRemotely replaces the command's own run function with one that runs
+// the same command on the selected hosts
+func runRemotely(cmd *cobra.Command) error {
+	if remoteHost != "" && remoteHosts != "" {
+		return fmt.Errorf("use either --host or --hosts")
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == hostsCmd || c == completionCmd {
+			return fmt.Errorf("%s cannot run on other hosts", cmd.CommandPath())
+		}
+	}
+
+	cmd.SilenceUsage = true
+	cmd.Run = nil
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		remoteArgs := commandLine(cmd, args)
+		if remoteHost != "" {
+			return runOnHost(cmd, remoteArgs)
+		}
+		if cmd == listSitesCmd && !listSitesJSON {
+			return listFleetSites(cmd, remoteArgs)
+		}
+		return runOnHosts(cmd, remoteArgs)
+	}
+	return nil
+}
+
+// commandLine rebuilds the arguments of the command for the remote cliboard
+func commandLine(cmd *cobra.Command, args []string) []string {
+	line := strings.Fields(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if localFlags[f.Name] {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range slice.GetSlice() {
+				line = append(line, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		line = append(line, "--"+f.Name+"="+f.Value.String())
+	})
+
+	// Arguments may start with a dash
+	if len(args) > 0 {
+		line = append(line, "--")
+		line = append(line, args...)
+	}
+	return line
+}
+
+// runOnHost runs the command on one host with the local terminal attached
+func runOnHost(cmd *cobra.Command, args []string) error {
+	selected, err := hosts.Select(remoteHost)
+	if err != nil {
+		return err
+	}
+	if len(selected) != 1 {
+		return fmt.Errorf("--host selects a single host, use --hosts for %s", remoteHost)
+	}
+	h := selected[0]
+
+	c := h.Command(cmd.Context(), args, term.IsTerminal(int(os.Stdin.Fd())))
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() != 255 {
+			return fmt.Errorf("%s failed on host %s", cmd.CommandPath(), h.Name)
+		}
+		return fmt.Errorf("failed to connect to host %s: %v", h.Name, err)
+	}
+	return nil
+}
+
+// runOnHosts runs the command on every selected host in parallel and prints
+// the output of each host once it has finished
+func runOnHosts(cmd *cobra.Command, args []string) error {
+	selected, err := hosts.Select(remoteHosts)
+	if err != nil {
+		return err
+	}
+
+	results := hosts.RunAll(cmd.Context(), selected, args, remoteParallel)
+
+	failed := 0
+	for _, r := range results {
+		status := "ok"
+		if r.Error != "" {
+			status = "unreachable"
+		} else if r.ExitCode != 0 {
+			status = fmt.Sprintf("exit %d", r.ExitCode)
+		}
+		if !r.OK() {
+			failed++
+		}
+
+		fmt.Printf("== %s (%s) ==\n", r.Host, status)
+		if r.Error != "" {
+			fmt.Println(r.Error)
+		}
+		fmt.Print(r.Stdout)
+		fmt.Fprint(os.Stderr, r.Stderr)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%s failed on %d of %d hosts", cmd.CommandPath(), failed, len(results))
+	}
+	return nil
+}
+
+// listFleetSites lists the sites of every selected host in one table
+func listFleetSites(cmd *cobra.Command, args []string) error {
+	selected, err := hosts.Select(remoteHosts)
+	if err != nil {
+		return err
+	}
+
+	results := hosts.RunAll(cmd.Context(), selected, append(args, "--json"), remoteParallel)
+
+	failed, total := 0, 0
+	for _, r := range results {
+		var sites []cliboard.Site
+		if !r.OK() {
+			failed++
+			msg := r.Error
+			if msg == "" {
+				msg = strings.TrimSpace(r.Stderr)
+			}
+			fmt.Fprintf(os.Stderr, "%s: %s\n", r.Host, msg)
+			continue
+		}
+		if err := json.Unmarshal([]byte(r.Stdout), &sites); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: unexpected output from list-sites: %v\n", r.Host, err)
+			continue
+		}
+		printSites(sites, r.Host)
+		total += len(sites)
+	}
+
+	fmt.Printf("%d site(s) on %d host(s)\n", total, len(results)-failed)
+	if failed > 0 {
+		return fmt.Errorf("list-sites failed on %d of %d hosts", failed, len(results))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&remoteHost, "host", "", "run the command on this host from the inventory over SSH")
+	rootCmd.PersistentFlags().StringVar(&remoteHosts, "hosts", "", "run the command on these hosts in parallel: all, tag:<tag> or a comma separated list")
+	rootCmd.PersistentFlags().IntVar(&remoteParallel, "parallel", 10, "number of hosts to run on at once with --hosts")
+
+	rootCmd.RegisterFlagCompletionFunc("host", completeFlag(completeHosts))
+	rootCmd.RegisterFlagCompletionFunc("hosts", completeFlag(completeHostSelectors))
+}
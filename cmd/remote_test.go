@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want []string
+	}{
+		{[]string{"list-sites"}, []string{"list-sites"}},
+		{[]string{"site", "create", "example.com", "--php", "8.2"}, []string{"site", "create", "--php=8.2", "--", "example.com"}},
+		{[]string{"site", "create", "--host", "web1", "--root=/tmp/x", "-m", "a b", "-m", "$x;y", "--", "-odd"}, []string{"site", "create", "--module=a b", "--module=$x;y", "--", "-odd"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		root := testCommands(func(cmd *cobra.Command, args []string) { got = commandLine(cmd, args) })
+		root.SetArgs(tt.argv)
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%q: %v", tt.argv, err)
+		}
+		if strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") {
+			t.Errorf("%q: got %q, want %q", tt.argv, got, tt.want)
+		}
+	}
+}
+
+// testCommands returns a root with local flags and list-sites and site
+// create commands that call run
+func testCommands(run func(*cobra.Command, []string)) *cobra.Command {
+	root := &cobra.Command{Use: "cliboard"}
+	root.PersistentFlags().String("host", "", "")
+	root.PersistentFlags().String("root", "", "")
+
+	root.AddCommand(&cobra.Command{Use: "list-sites", Run: run})
+	site := &cobra.Command{Use: "site"}
+	create := &cobra.Command{Use: "create", Run: run}
+	create.Flags().String("php", "", "")
+	create.Flags().StringSliceP("module", "m", nil, "")
+	site.AddCommand(create)
+	root.AddCommand(site)
+	return root
+}
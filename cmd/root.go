@@ -1,29 +1,92 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/pkg/cliboard"
 	"github.com/spf13/cobra"
 )
 
+// Global flags
+var (
+	configFile string
+	rootDir    string
+	settings   []string
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "cliboard",
 	Short: "CLIBoard - Web Control Panel for VPS Management",
 	Long: `CLIBoard is a CLI-based web control panel that helps manage servers/VPS 
 using Caddy as the web server. It supports site creation, PHP management, 
 Caddy modules, automatic backups, and more.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		c, err := cliboard.New(cliboard.Options{ConfigFile: configFile, Root: rootDir, Set: settings, OnEvent: printEvent})
+		if err != nil {
+			return err
+		}
+		client = c
+
+		// The hosts record their own history and audit log
+		if remote() {
+			return runRemotely(cmd)
+		}
+
+		// Read-only commands and shell completion change nothing, so they
+		// skip the history lock and git altogether
+		if !audited(cmd) {
+			return nil
+		}
+		recordChanges = true
+
+		// Record hand edits made since the last run separately
+		recordHistory("external changes")
+		beginAudit(cmd, args)
+		return nil
+	},
+}
+
+// recordChanges is set when the running command may change the
+// configuration, which is then recorded in the history when it ends
+var recordChanges bool
+
+// client runs the commands, created once the configuration is loaded
+var client *cliboard.Client
+
+// printEvent shows the progress and outcome of operations
+func printEvent(e cliboard.Event) {
+	fmt.Println(e.Message)
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	addPluginCommands()
+
+	_, err := rootCmd.ExecuteC()
+	if recordChanges {
+		command := "cliboard " + strings.Join(os.Args[1:], " ")
+		if err != nil {
+			command += " (failed)"
+		}
+		recordHistory(command)
+	}
+	endAudit(err)
+	return err
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default $CLIBOARD_CONFIG or "+config.DefaultConfigFile+")")
+	rootCmd.PersistentFlags().StringVar(&rootDir, "root", "", "relocate all filesystem paths under this directory (default $CLIBOARD_ROOT)")
+	rootCmd.PersistentFlags().StringArrayVar(&settings, "set", nil, "override a setting, e.g. --set paths.sites_root=/srv/sites")
+
 	// Add commands
 	rootCmd.AddCommand(createSiteCmd)
 	rootCmd.AddCommand(deleteSiteCmd)
+	rootCmd.AddCommand(suspendSiteCmd)
+	rootCmd.AddCommand(resumeSiteCmd)
+	rootCmd.AddCommand(listSitesCmd)
 	rootCmd.AddCommand(addModuleCmd)
 	rootCmd.AddCommand(removeModuleCmd)
 	rootCmd.AddCommand(listModulesCmd)
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfUpdateVersion  string
+	selfUpdateChannel  string
+	selfUpdateRollback bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update cliboard to the latest release",
+	Long: `Update cliboard to the latest release of a channel, or to the version
+given with --version.
+
+Releases are downloaded from update.url. The checksums of a release must be
+signed with the ed25519 key in update.public_key, and the binary must match
+its checksum and run before it replaces the current one. The replaced binary
+is kept next to it as cliboard.previous, and --rollback puts it back.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exe, err := update.Executable()
+		if err != nil {
+			return err
+		}
+
+		if selfUpdateRollback {
+			if err := update.Rollback(exe); err != nil {
+				return err
+			}
+			fmt.Printf("Restored the previous binary, v%s is kept as %s\n", Version, update.Previous(exe))
+			return nil
+		}
+
+		version := selfUpdateVersion
+		if version == "" {
+			release, err := update.Latest(updateChannel())
+			if err != nil {
+				return err
+			}
+			if !update.Newer(release.Version, Version) {
+				fmt.Printf("CLIBoard v%s is up to date\n", Version)
+				return nil
+			}
+			version = release.Version
+		}
+
+		fmt.Printf("Updating CLIBoard from v%s to v%s\n", Version, version)
+		if err := update.Install(version, exe); err != nil {
+			return err
+		}
+		fmt.Printf("Updated to v%s, the previous binary is kept as %s\n", version, update.Previous(exe))
+		return nil
+	},
+}
+
+// updateChannel is the channel from --channel or the configuration
+func updateChannel() string {
+	if selfUpdateChannel != "" {
+		return selfUpdateChannel
+	}
+	return config.UpdateChannel
+}
+
+func init() {
+	selfUpdateCmd.Flags().StringVar(&selfUpdateVersion, "version", "", "install this version instead of the latest")
+	selfUpdateCmd.Flags().StringVar(&selfUpdateChannel, "channel", "", "release channel: stable or beta (default update.channel)")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateRollback, "rollback", false, "put back the binary replaced by the last update")
+	selfUpdateCmd.MarkFlagsMutuallyExclusive("version", "rollback")
+	selfUpdateCmd.MarkFlagsMutuallyExclusive("channel", "rollback")
+	selfUpdateCmd.RegisterFlagCompletionFunc("channel", cobra.FixedCompletions(update.Channels, cobra.ShellCompDirectiveNoFileComp))
+
+	rootCmd.AddCommand(selfUpdateCmd)
+}
@@ -1,35 +1,207 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 
-	"github.com/doko89/cliboard/internal/config"
 	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+	"github.com/doko89/cliboard/pkg/cliboard"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	createSiteTemplate string
+	createSiteWebroot  string
+	createSitePHP      string
+	createSiteModules  []string
+	createSiteBackup   bool
 )
 
 var createSiteCmd = &cobra.Command{
 	Use:   "create-site [domain]",
 	Short: "Create a new site",
-	Args:  cobra.ExactArgs(1),
+	Long: `Create a new site.
+
+Run without flags on a terminal, create-site asks for the domain, template,
+webroot, PHP version, modules, backups, a database and basic auth step by
+step, shows a summary and creates the site with all of it at once. If a step
+fails, the site is removed again.
+
+With flags, or without a terminal, the site is created from them without
+asking.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: cobra.NoFileCompletions,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		domain := args[0]
-		return site.Create(domain)
+		domain := ""
+		if len(args) == 1 {
+			domain = args[0]
+		}
+
+		if !createSiteFlagsSet(cmd) && utils.Interactive() {
+			s, err := siteWizard(cmd.Context(), domain)
+			if err != nil {
+				return err
+			}
+			if s == nil {
+				fmt.Println("Site creation cancelled")
+				return nil
+			}
+			_, err = client.CreateSiteWith(cmd.Context(), *s)
+			return err
+		}
+
+		if domain == "" {
+			return fmt.Errorf("create-site needs a domain when it is not run on a terminal")
+		}
+		if !createSiteFlagsSet(cmd) {
+			_, err := client.CreateSite(cmd.Context(), domain)
+			return err
+		}
+
+		s := cliboard.SiteSettings{
+			Domain:   domain,
+			Template: createSiteTemplate,
+			Webroot:  createSiteWebroot,
+			PHP:      createSitePHP,
+			Modules:  createSiteModules,
+			Backup:   createSiteBackup,
+		}
+		if !cmd.Flags().Changed("module") {
+			// Keep the modules of the template
+			tmpl, err := site.GetTemplate(s.Template)
+			if err != nil {
+				return err
+			}
+			s.Modules = tmpl.Modules
+		}
+		_, err := client.CreateSiteWith(cmd.Context(), s)
+		return err
 	},
 }
 
+// createSiteFlagsSet reports whether any of the flags of create-site itself
+// was given, which turns the wizard off
+func createSiteFlagsSet(cmd *cobra.Command) bool {
+	set := false
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			set = true
+		}
+	})
+	return set
+}
+
 var deleteSiteCmd = &cobra.Command{
-	Use:   "delete-site [domain]",
-	Short: "Delete an existing site",
-	Args:  cobra.ExactArgs(1),
+	Use:               "delete-site [domain]",
+	Short:             "Delete an existing site",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeSites),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
-		return site.Delete(domain)
+		if _, err := client.Site(cmd.Context(), domain); err != nil {
+			return err
+		}
+
+		// Ask for confirmation
+		confirmed := utils.AskForConfirmation(fmt.Sprintf("Are you sure you want to delete site %s?", domain))
+		if !confirmed {
+			fmt.Println("Site deletion cancelled")
+			return nil
+		}
+
+		return client.DeleteSite(cmd.Context(), domain)
 	},
 }
 
+var suspendSiteCmd = &cobra.Command{
+	Use:   "suspend-site [domain]",
+	Short: "Answer every request to a site with 503 Service Unavailable",
+	Long: `Answer every request to a site with 503 Service Unavailable.
+
+The site keeps its files and configuration, and is served again by
+"cliboard resume-site". Its configuration is kept in the suspended
+directory of the Caddy configuration meanwhile, and changes to it are
+refused until the site is resumed.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeSites),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := client.SuspendSite(cmd.Context(), args[0])
+		return err
+	},
+}
+
+var resumeSiteCmd = &cobra.Command{
+	Use:               "resume-site [domain]",
+	Short:             "Serve a suspended site again",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: complete(completeSites),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := client.ResumeSite(cmd.Context(), args[0])
+		return err
+	},
+}
+
+var listSitesJSON bool
+
+var listSitesCmd = &cobra.Command{
+	Use:         "list-sites",
+	Short:       "List all sites",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sites, err := client.Sites(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if listSitesJSON {
+			if sites == nil {
+				sites = []cliboard.Site{}
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(sites)
+		}
+
+		if len(sites) == 0 {
+			fmt.Println("No sites found")
+			return nil
+		}
+		printSites(sites, "")
+		return nil
+	},
+}
+
+// printSites prints sites as a table, with a host column when host is set
+func printSites(sites []cliboard.Site, host string) {
+	for _, s := range sites {
+		php := s.PHPVersion
+		if php == "" {
+			php = "-"
+		}
+		backup := "no"
+		if s.Backup {
+			backup = "yes"
+		}
+		modules := strings.Join(s.Modules, ",")
+		if modules == "" {
+			modules = "-"
+		}
+		suspended := ""
+		if s.Suspended {
+			suspended = " (suspended)"
+		}
+		if host != "" {
+			fmt.Printf("%-20s ", host)
+		}
+		fmt.Printf("%-30s php %-5s backup %-3s modules %s%s\n", s.Domain, php, backup, modules, suspended)
+	}
+}
+
 var webrootCmd = &cobra.Command{
 	Use:   "webroot",
 	Short: "Manage site webroot",
@@ -39,13 +211,29 @@ var webrootUpdateCmd = &cobra.Command{
 	Use:   "update [domain] [path]",
 	Short: "Update site webroot path",
 	Args:  cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 1 {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		}
+		return complete(completeSites)(cmd, args, toComplete)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		domain := args[0]
 		path := args[1]
-		return site.UpdateWebroot(domain, path)
+		_, err := client.UpdateWebroot(cmd.Context(), domain, path)
+		return err
 	},
 }
 
 func init() {
+	createSiteCmd.Flags().StringVar(&createSiteTemplate, "template", site.DefaultTemplate, "template to start from")
+	createSiteCmd.Flags().StringVar(&createSiteWebroot, "webroot", "/", "webroot, relative to the site directory")
+	createSiteCmd.Flags().StringVar(&createSitePHP, "php", "", "PHP version to enable, installed first if needed")
+	createSiteCmd.Flags().StringSliceVar(&createSiteModules, "module", nil, "module to enable, instead of the template's (repeatable)")
+	createSiteCmd.Flags().BoolVar(&createSiteBackup, "backup", false, "enable automatic backups")
+	createSiteCmd.RegisterFlagCompletionFunc("template", completeFlag(completeTemplates))
+	createSiteCmd.RegisterFlagCompletionFunc("php", completeFlag(completePHPVersions))
+	createSiteCmd.RegisterFlagCompletionFunc("module", completeFlag(completeModules))
+	listSitesCmd.Flags().BoolVar(&listSitesJSON, "json", false, "print sites as JSON")
 	webrootCmd.AddCommand(webrootUpdateCmd)
 }
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/doko89/cliboard/internal/state"
+	"github.com/doko89/cliboard/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stateFile  string
+	statePrune bool
+	stateYes   bool
+	stateJSON  bool
+	stateOut   string
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what apply would change",
+	Long: `Compare a state file describing all sites with the server and list the
+changes cliboard apply would make. Nothing is changed.`,
+	Annotations:  readOnly,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := loadPlan()
+		if err != nil {
+			return err
+		}
+
+		if stateJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(plan)
+		}
+		printPlan(plan)
+		return nil
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Reconcile the server with a state file",
+	Long: `Make the changes cliboard plan lists, then validate the Caddy
+configuration and reload Caddy once. If a change fails or the configuration
+is invalid, the changes made so far are undone and Caddy keeps running the
+old configuration. Sites missing from the file are left alone unless --prune
+is given; they are only deleted once everything else has been applied.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := loadPlan()
+		if err != nil {
+			return err
+		}
+
+		printPlan(plan)
+		if plan.Empty() {
+			return nil
+		}
+
+		if !stateYes && !utils.AskForConfirmation("Apply these changes?") {
+			fmt.Println("Apply cancelled")
+			return nil
+		}
+
+		err = plan.Apply(func(c state.Change) {
+			if c.Site != "" {
+				fmt.Printf("%s: %s\n", c.Site, c.Description)
+			} else {
+				fmt.Println(c.Description)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Applied %d change(s)\n", len(plan.Changes))
+		return nil
+	},
+}
+
+var exportStateCmd = &cobra.Command{
+	Use:   "export-state",
+	Short: "Write the current sites as a state file",
+	Long: `Describe every site on the server in the format read by cliboard plan
+and cliboard apply, as YAML or with --json as JSON.`,
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := state.Export()
+		if err != nil {
+			return err
+		}
+
+		if stateOut == "" {
+			return f.Write(os.Stdout, stateJSON)
+		}
+
+		out, err := os.OpenFile(stateOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		if err := f.Write(out, stateJSON); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %d site(s) to %s\n", len(f.Sites), stateOut)
+		return nil
+	},
+}
+
+var schemaCmd = &cobra.Command{
+	Use:         "schema",
+	Short:       "Print the JSON Schema of the state file",
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(state.Schema())
+		return err
+	},
+}
+
+func loadPlan() (*state.Plan, error) {
+	f, err := state.Load(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	return state.NewPlan(f, statePrune)
+}
+
+func printPlan(plan *state.Plan) {
+	symbols := map[state.Kind]string{state.Add: "+", state.Update: "~", state.Remove: "-"}
+
+	site := ""
+	for _, c := range plan.Changes {
+		if c.Site != site {
+			site = c.Site
+			fmt.Println(site)
+		}
+		indent := ""
+		if c.Site != "" {
+			indent = "  "
+		}
+		fmt.Printf("%s%s %s\n", indent, symbols[c.Kind], c.Description)
+	}
+
+	for _, domain := range plan.Unmanaged {
+		fmt.Printf("%s is not in the file and is kept, use --prune to delete it\n", domain)
+	}
+	for _, note := range plan.Notes {
+		fmt.Printf("Note: %s\n", note)
+	}
+
+	if plan.Empty() {
+		fmt.Println("No changes, the server matches the file")
+	} else {
+		fmt.Printf("%d change(s)\n", len(plan.Changes))
+	}
+}
+
+func init() {
+	for _, c := range []*cobra.Command{planCmd, applyCmd} {
+		c.Flags().StringVarP(&stateFile, "file", "f", "", "state file in YAML or JSON, - for stdin")
+		c.Flags().BoolVar(&statePrune, "prune", false, "delete sites that are not in the file, with their files")
+		c.MarkFlagRequired("file")
+	}
+	planCmd.Flags().BoolVar(&stateJSON, "json", false, "print the plan as JSON")
+	applyCmd.Flags().BoolVarP(&stateYes, "yes", "y", false, "apply without asking for confirmation")
+	exportStateCmd.Flags().BoolVar(&stateJSON, "json", false, "write JSON instead of YAML")
+	exportStateCmd.Flags().StringVarP(&stateOut, "output", "o", "", "write to a file instead of stdout")
+
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(exportStateCmd)
+	rootCmd.AddCommand(schemaCmd)
+}
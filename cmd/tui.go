@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/doko89/cliboard/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse and manage the sites in a full-screen terminal interface",
+	Long: `Browse and manage the sites in a full-screen terminal interface.
+
+The sites are listed with their monitor status, PHP version, age of the
+latest backup and modules. Keys toggle modules, switch PHP, take a backup,
+follow the access log and suspend or resume the selected site; press ? for
+the full list. Removing a module, changing PHP and suspending a site ask for
+confirmation first.
+
+Each change is recorded in the audit log and the configuration history, as
+it is from the command line. With --host the interface runs on the remote
+server over SSH.`,
+	// The operations run from the interface are audited one by one
+	Annotations: readOnly,
+	Args:        cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return tui.Run()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/doko89/cliboard/internal/update"
 	"github.com/spf13/cobra"
 )
 
@@ -13,17 +14,37 @@ var (
 	CommitSHA = "unknown"
 )
 
+var versionCheck bool
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print the version of CLIBoard",
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:         "version",
+	Short:       "Print the version of CLIBoard",
+	Annotations: readOnly,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Printf("CLIBoard v%s\n", Version)
 		fmt.Printf("Build Date: %s\n", BuildDate)
 		fmt.Printf("Commit: %s\n", CommitSHA)
+
+		if !versionCheck {
+			return nil
+		}
+		channel := updateChannel()
+		release, err := update.Latest(channel)
+		if err != nil {
+			return err
+		}
+		if update.Newer(release.Version, Version) {
+			fmt.Printf("Update available: v%s on the %s channel, run cliboard self-update\n", release.Version, channel)
+		} else {
+			fmt.Printf("Up to date, the latest %s release is v%s\n", channel, release.Version)
+		}
+		return nil
 	},
 }
 
 func init() {
+	versionCmd.Flags().BoolVar(&versionCheck, "check", false, "check whether a newer release is available")
+	versionCmd.Flags().StringVar(&selfUpdateChannel, "channel", "", "release channel to check: stable or beta (default update.channel)")
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/database"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+	"github.com/doko89/cliboard/pkg/cliboard"
+)
+
+// siteWizard asks for the settings of a new site step by step. domain, if
+// set, is offered as the default. It returns nil when the user cancels.
+func siteWizard(ctx context.Context, domain string) (*cliboard.SiteSettings, error) {
+	s := &cliboard.SiteSettings{}
+
+	// Domain, checked against DNS
+	for {
+		var err error
+		s.Domain, err = utils.AskForInput("Domain", domain, func(answer string) error {
+			if !site.ValidDomain(answer) {
+				return fmt.Errorf("%q is not a valid domain", answer)
+			}
+			if _, err := client.Site(ctx, answer); err == nil {
+				return fmt.Errorf("site %s already exists", answer)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		warning := checkDNS(s.Domain)
+		if warning == "" {
+			break
+		}
+		fmt.Printf("Warning: %s\n", warning)
+		if utils.AskForConfirmation("Use this domain anyway?") {
+			break
+		}
+		domain = ""
+	}
+
+	// Template
+	templates := site.Templates()
+	var options []string
+	current := 0
+	for i, t := range templates {
+		options = append(options, t.Name+" - "+t.Description)
+		if t.Name == site.DefaultTemplate {
+			current = i
+		}
+	}
+	choice, err := utils.AskForSelection("Template:", options, current)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := templates[choice]
+	s.Template = tmpl.Name
+
+	// Webroot
+	s.Webroot, err = utils.AskForInput("Webroot, relative to the site directory", "/", func(answer string) error {
+		for _, part := range strings.Split(answer, "/") {
+			if part == ".." {
+				return fmt.Errorf("the webroot must be inside the site directory")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// PHP, from the installed versions or one to install
+	installed := php.InstalledVersions()
+	options = []string{"none"}
+	options = append(options, installed...)
+	for _, version := range php.InstallableVersions() {
+		options = append(options, version+" (install now)")
+	}
+	current = 0
+	if tmpl.Name == "php" {
+		// The newest installed version, or else the newest there is
+		current = len(installed)
+		if current == 0 {
+			current = len(options) - 1
+		}
+	}
+	choice, err = utils.AskForSelection("PHP version:", options, current)
+	if err != nil {
+		return nil, err
+	}
+	if choice > 0 {
+		s.PHP = strings.Fields(options[choice])[0]
+	}
+
+	// Modules, starting from those of the template
+	available, err := module.Available()
+	if err != nil {
+		return nil, err
+	}
+	if len(available) > 0 {
+		var defaults []int
+		for i, name := range available {
+			if contains(tmpl.Modules, name) {
+				defaults = append(defaults, i)
+			}
+		}
+		choices, err := utils.AskForSelections("Modules to enable:", available, defaults)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range choices {
+			if !contains(s.Modules, available[i]) {
+				s.Modules = append(s.Modules, available[i])
+			}
+		}
+	}
+
+	// Backup schedule
+	choice, err = utils.AskForSelection("Automatic backups:", []string{
+		"none",
+		fmt.Sprintf("daily (%s) and weekly (%s)", config.SiteDailySchedule, config.SiteWeeklySchedule),
+	}, 1)
+	if err != nil {
+		return nil, err
+	}
+	s.Backup = choice == 1
+
+	// Database
+	if database.Installed() {
+		s.Database = utils.AskForConfirmation(fmt.Sprintf("Create a MariaDB/MySQL database %s for the site?", database.NameFor(s.Domain)))
+	} else {
+		fmt.Println("MariaDB/MySQL is not installed, skipping the database")
+	}
+
+	// Basic auth
+	if utils.AskForConfirmation("Protect the site with a user name and password?") {
+		s.AuthUser, err = utils.AskForInput("User name", "", func(answer string) error {
+			if answer == "" || strings.ContainsAny(answer, " \t\"{}#") {
+				return fmt.Errorf("enter a user name without spaces, quotes, braces or #")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for s.AuthPassword == "" {
+			password, err := utils.ReadPassword("Password: ")
+			if err != nil {
+				return nil, err
+			}
+			repeated, err := utils.ReadPassword("Repeat the password: ")
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case password == "":
+				fmt.Println("  The password must not be empty")
+			case password != repeated:
+				fmt.Println("  The passwords do not match")
+			default:
+				s.AuthPassword = password
+			}
+		}
+	}
+
+	fmt.Println()
+	printNewSite(s)
+	fmt.Println()
+	if !utils.AskForConfirmation("Create the site?") {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// checkDNS warns when a domain does not resolve to this server. Servers
+// behind NAT do not have their public address on an interface, so this is
+// only a warning.
+func checkDNS(domain string) string {
+	addrs, err := net.LookupHost(domain)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Sprintf("%s does not resolve, Caddy cannot get a certificate for it until it points to this server", domain)
+	}
+
+	local := map[string]bool{}
+	if ifaddrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range ifaddrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				local[ipnet.IP.String()] = true
+			}
+		}
+	}
+	for _, addr := range addrs {
+		if local[addr] {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s resolves to %s, which is not an address of this server", domain, strings.Join(addrs, ", "))
+}
+
+// printNewSite summarizes what create-site is about to do
+func printNewSite(s *cliboard.SiteSettings) {
+	value := func(v string) string {
+		if v == "" {
+			return "none"
+		}
+		return v
+	}
+
+	phpVersion := s.PHP
+	if phpVersion != "" {
+		if !php.Installed(phpVersion) {
+			phpVersion += " (installed first)"
+		}
+	}
+	backups := ""
+	if s.Backup {
+		backups = fmt.Sprintf("daily (%s) and weekly (%s)", config.SiteDailySchedule, config.SiteWeeklySchedule)
+	}
+	db := ""
+	if s.Database {
+		db = database.NameFor(s.Domain)
+	}
+	auth := ""
+	if s.AuthUser != "" {
+		auth = "user " + s.AuthUser
+	}
+
+	fmt.Printf("Domain:      %s\n", s.Domain)
+	fmt.Printf("Template:    %s\n", value(s.Template))
+	fmt.Printf("Webroot:     %s\n", value(s.Webroot))
+	fmt.Printf("PHP:         %s\n", value(phpVersion))
+	fmt.Printf("Modules:     %s\n", value(strings.Join(s.Modules, ", ")))
+	fmt.Printf("Backups:     %s\n", value(backups))
+	fmt.Printf("Database:    %s\n", value(db))
+	fmt.Printf("Basic auth:  %s\n", value(auth))
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobStatus is the state of an asynchronous job
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// maxFinishedJobs bounds how many finished jobs are kept for polling
+const maxFinishedJobs = 100
+
+// Job is a long running operation started through the API
+type Job struct {
+	ID       string     `json:"id"`
+	Command  string     `json:"command"`
+	Token    string     `json:"token"`
+	Status   JobStatus  `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+
+	seq int
+}
+
+type queuedJob struct {
+	job *Job
+	run func() error
+}
+
+// jobs runs queued operations one at a time, in order
+type jobs struct {
+	mu    sync.Mutex
+	next  int
+	byID  map[string]*Job
+	queue chan queuedJob
+}
+
+func newJobs() *jobs {
+	return &jobs{byID: map[string]*Job{}, queue: make(chan queuedJob, 64)}
+}
+
+// submit queues fn and returns its job
+func (j *jobs) submit(token, command string, fn func() error) (Job, error) {
+	j.mu.Lock()
+	j.next++
+	job := &Job{ID: fmt.Sprintf("%d", j.next), seq: j.next, Command: command, Token: token, Status: JobPending, Created: time.Now().UTC()}
+	j.byID[job.ID] = job
+	j.prune()
+	snapshot := *job
+	j.mu.Unlock()
+
+	select {
+	case j.queue <- queuedJob{job: job, run: fn}:
+		return snapshot, nil
+	default:
+		j.mu.Lock()
+		delete(j.byID, job.ID)
+		j.mu.Unlock()
+		return Job{}, fmt.Errorf("too many queued jobs, try again later")
+	}
+}
+
+// work runs queued jobs until the queue is closed. exec wraps each run, so
+// jobs are serialized with synchronous changes.
+func (j *jobs) work(exec func(token, command string, fn func() error) error) {
+	for q := range j.queue {
+		j.update(q.job, func(job *Job) {
+			now := time.Now().UTC()
+			job.Status, job.Started = JobRunning, &now
+		})
+
+		err := exec(q.job.Token, q.job.Command, q.run)
+
+		j.update(q.job, func(job *Job) {
+			now := time.Now().UTC()
+			job.Finished = &now
+			if err != nil {
+				job.Status, job.Error = JobFailed, err.Error()
+			} else {
+				job.Status = JobSucceeded
+			}
+		})
+	}
+}
+
+func (j *jobs) update(job *Job, fn func(*Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(job)
+}
+
+func (j *jobs) get(id string) (Job, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.byID[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// list returns all known jobs, newest first
+func (j *jobs) list() []Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	list := make([]Job, 0, len(j.byID))
+	for _, job := range j.byID {
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].seq > list[b].seq })
+	return list
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs
+func (j *jobs) prune() {
+	var finished []*Job
+	for _, job := range j.byID {
+		if job.Finished != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].Finished.Before(*finished[b].Finished) })
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(j.byID, job.ID)
+	}
+}
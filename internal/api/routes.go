@@ -0,0 +1,437 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+var (
+	versionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+	namePattern    = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]*$`)
+)
+
+// route maps a method and path pattern to a handler. Pattern segments in
+// braces are parameters.
+type route struct {
+	method  string
+	pattern []string
+	public  bool
+	handle  func(*request)
+}
+
+func (s *Server) buildRoutes() []route {
+	r := func(method, pattern string, handle func(*request)) route {
+		return route{method: method, pattern: strings.Split(strings.Trim(pattern, "/"), "/"), handle: handle}
+	}
+
+	routes := []route{
+		r("GET", "/v1/sites", s.listSites),
+		r("POST", "/v1/sites", s.createSite),
+		r("GET", "/v1/sites/{domain}", s.getSite),
+		r("DELETE", "/v1/sites/{domain}", s.deleteSite),
+		r("PUT", "/v1/sites/{domain}/webroot", s.updateWebroot),
+		r("PUT", "/v1/sites/{domain}/modules/{module}", s.addModule),
+		r("DELETE", "/v1/sites/{domain}/modules/{module}", s.removeModule),
+		r("PUT", "/v1/sites/{domain}/php", s.enablePHP),
+		r("DELETE", "/v1/sites/{domain}/php", s.disablePHP),
+		r("GET", "/v1/sites/{domain}/backups", s.listBackups),
+		r("POST", "/v1/sites/{domain}/backups", s.runBackup),
+		r("POST", "/v1/sites/{domain}/backups/{kind}/{name}/restore", s.restoreBackup),
+		r("PUT", "/v1/sites/{domain}/backups/schedule", s.enableBackup),
+		r("DELETE", "/v1/sites/{domain}/backups/schedule", s.disableBackup),
+		r("GET", "/v1/modules", s.listModules),
+		r("GET", "/v1/php", s.listPHP),
+		r("PUT", "/v1/php/{version}", s.installPHP),
+		r("DELETE", "/v1/php/{version}", s.uninstallPHP),
+		r("GET", "/v1/php/{version}/extensions", s.listExtensions),
+		r("PUT", "/v1/php/{version}/extensions/{extension}", s.addExtension),
+		r("DELETE", "/v1/php/{version}/extensions/{extension}", s.removeExtension),
+		r("GET", "/v1/databases/backup", s.getDatabaseBackup),
+		r("PUT", "/v1/databases/backup", s.enableDatabaseBackup),
+		r("DELETE", "/v1/databases/backup", s.disableDatabaseBackup),
+		r("GET", "/v1/jobs", s.listJobs),
+		r("GET", "/v1/jobs/{id}", s.getJob),
+	}
+
+	openapi := r("GET", "/v1/openapi.json", func(req *request) {
+		req.w.Header().Set("Content-Type", "application/json")
+		req.w.Write(openAPIDocument)
+	})
+	openapi.public = true
+	return append(routes, openapi)
+}
+
+// match finds the route for a request. allowed reports whether the path
+// exists with another method.
+func (s *Server) match(method, path string) (*route, map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	allowed := false
+
+	for i := range s.routes {
+		rt := &s.routes[i]
+		params, ok := matchPattern(rt.pattern, segments)
+		if !ok {
+			continue
+		}
+		if rt.method == method {
+			return rt, params, true
+		}
+		allowed = true
+	}
+	return nil, nil, allowed
+}
+
+func matchPattern(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[p[1:len(p)-1]] = segments[i]
+		} else if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// siteParam returns the domain of a site route, responding with 404 when
+// the site does not exist
+func (req *request) siteParam() (string, bool) {
+	domain := req.params["domain"]
+	if !site.ValidDomain(domain) {
+		writeError(req.w, http.StatusNotFound, "site "+domain+" does not exist")
+		return "", false
+	}
+	if _, err := os.Stat(config.GetSiteConfigPath(domain)); err != nil {
+		writeError(req.w, http.StatusNotFound, "site "+domain+" does not exist")
+		return "", false
+	}
+	return domain, true
+}
+
+// param returns a path parameter, responding with 400 when it does not
+// match pattern
+func (req *request) param(name string, pattern *regexp.Regexp) (string, bool) {
+	value := req.params[name]
+	if !pattern.MatchString(value) {
+		writeError(req.w, http.StatusBadRequest, "invalid "+name+" "+value)
+		return "", false
+	}
+	return value, true
+}
+
+func (s *Server) listSites(req *request) {
+	sites, err := site.List()
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if sites == nil {
+		sites = []site.Info{}
+	}
+	writeJSON(req.w, http.StatusOK, sites)
+}
+
+func (s *Server) createSite(req *request) {
+	var body struct {
+		Domain string `json:"domain"`
+	}
+	if !req.decode(&body) {
+		return
+	}
+	domain := strings.ToLower(body.Domain)
+	if !site.ValidDomain(domain) {
+		writeError(req.w, http.StatusBadRequest, "invalid domain "+body.Domain)
+		return
+	}
+	if _, err := os.Stat(config.GetSiteConfigPath(domain)); err == nil {
+		writeError(req.w, http.StatusConflict, "site "+domain+" already exists")
+		return
+	}
+
+	s.change(req, "create-site "+domain, http.StatusCreated,
+		func() error { return site.Create(domain) },
+		func() (interface{}, error) { return site.Get(domain) })
+}
+
+func (s *Server) getSite(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	info, err := site.Get(domain)
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(req.w, http.StatusOK, info)
+}
+
+func (s *Server) deleteSite(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	s.change(req, "delete-site "+domain, 0, func() error { return site.Remove(domain) }, nil)
+}
+
+func (s *Server) updateWebroot(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	var body struct {
+		Path string `json:"path"`
+	}
+	if !req.decode(&body) {
+		return
+	}
+	if strings.Contains(body.Path, "..") {
+		writeError(req.w, http.StatusBadRequest, "webroot must be inside the site directory")
+		return
+	}
+
+	s.change(req, "update-webroot "+domain+" "+body.Path, http.StatusOK,
+		func() error { return site.UpdateWebroot(domain, body.Path) },
+		func() (interface{}, error) { return site.Get(domain) })
+}
+
+func (s *Server) addModule(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	name, ok := req.param("module", namePattern)
+	if !ok {
+		return
+	}
+	s.change(req, "add-module "+domain+" "+name, http.StatusOK,
+		func() error { return module.Add(domain, name) },
+		func() (interface{}, error) { return site.Get(domain) })
+}
+
+func (s *Server) removeModule(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	name, ok := req.param("module", namePattern)
+	if !ok {
+		return
+	}
+	s.change(req, "remove-module "+domain+" "+name, http.StatusOK,
+		func() error { return module.Remove(domain, name) },
+		func() (interface{}, error) { return site.Get(domain) })
+}
+
+func (s *Server) enablePHP(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	var body struct {
+		Version string `json:"version"`
+	}
+	if !req.decode(&body) {
+		return
+	}
+	if !versionPattern.MatchString(body.Version) {
+		writeError(req.w, http.StatusBadRequest, "invalid PHP version "+body.Version)
+		return
+	}
+	s.change(req, "enable-php "+domain+" "+body.Version, http.StatusOK,
+		func() error { return php.Enable(domain, body.Version) },
+		func() (interface{}, error) { return site.Get(domain) })
+}
+
+func (s *Server) disablePHP(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	s.change(req, "disable-php "+domain, http.StatusOK,
+		func() error { return php.Disable(domain) },
+		func() (interface{}, error) { return site.Get(domain) })
+}
+
+func (s *Server) listBackups(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	snapshots, err := backup.Snapshots(domain)
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if snapshots == nil {
+		snapshots = []backup.Snapshot{}
+	}
+	writeJSON(req.w, http.StatusOK, map[string]interface{}{
+		"enabled":   backup.SiteEnabled(domain),
+		"snapshots": snapshots,
+	})
+}
+
+func (s *Server) runBackup(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	s.async(req, "backup-run "+domain, func() error {
+		_, err := backup.RunSite(domain)
+		return err
+	})
+}
+
+func (s *Server) restoreBackup(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	kind, name := req.params["kind"], req.params["name"]
+	s.async(req, "backup-restore "+domain+" "+kind+" "+name, func() error {
+		return backup.RestoreSite(domain, kind, name)
+	})
+}
+
+func (s *Server) enableBackup(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	s.change(req, "enable-backup "+domain, 0, func() error { return backup.EnableSite(domain) }, nil)
+}
+
+func (s *Server) disableBackup(req *request) {
+	domain, ok := req.siteParam()
+	if !ok {
+		return
+	}
+	s.change(req, "disable-backup "+domain, 0, func() error { return backup.DisableSite(domain) }, nil)
+}
+
+func (s *Server) listModules(req *request) {
+	names, err := module.Available()
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	writeJSON(req.w, http.StatusOK, names)
+}
+
+func (s *Server) listPHP(req *request) {
+	versions := php.InstalledVersions()
+	if versions == nil {
+		versions = []string{}
+	}
+	writeJSON(req.w, http.StatusOK, map[string]interface{}{"installed": versions})
+}
+
+func (s *Server) installPHP(req *request) {
+	version, ok := req.param("version", versionPattern)
+	if !ok {
+		return
+	}
+	s.async(req, "php-install "+version, func() error { return php.Install(version) })
+}
+
+func (s *Server) uninstallPHP(req *request) {
+	version, ok := req.param("version", versionPattern)
+	if !ok {
+		return
+	}
+	s.async(req, "php-uninstall "+version, func() error { return php.Uninstall(version) })
+}
+
+func (s *Server) listExtensions(req *request) {
+	version, ok := req.param("version", versionPattern)
+	if !ok {
+		return
+	}
+	packages, err := php.AvailableModules(version)
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	type extension struct {
+		Name        string `json:"name"`
+		Version     string `json:"version,omitempty"`
+		Description string `json:"description,omitempty"`
+	}
+	extensions := []extension{}
+	for _, p := range packages {
+		extensions = append(extensions, extension{p.Name, p.Version, p.Description})
+	}
+	writeJSON(req.w, http.StatusOK, extensions)
+}
+
+func (s *Server) addExtension(req *request) {
+	version, ok := req.param("version", versionPattern)
+	if !ok {
+		return
+	}
+	name, ok := req.param("extension", namePattern)
+	if !ok {
+		return
+	}
+	s.async(req, "php-module-add "+version+" "+name, func() error { return php.AddModule(version, name) })
+}
+
+func (s *Server) removeExtension(req *request) {
+	version, ok := req.param("version", versionPattern)
+	if !ok {
+		return
+	}
+	name, ok := req.param("extension", namePattern)
+	if !ok {
+		return
+	}
+	s.async(req, "php-module-remove "+version+" "+name, func() error { return php.RemoveModule(version, name) })
+}
+
+func (s *Server) getDatabaseBackup(req *request) {
+	writeJSON(req.w, http.StatusOK, map[string]bool{"enabled": backup.DatabaseEnabled()})
+}
+
+func (s *Server) enableDatabaseBackup(req *request) {
+	s.change(req, "enable-dbbackup", 0, backup.EnableDatabase, nil)
+}
+
+func (s *Server) disableDatabaseBackup(req *request) {
+	s.change(req, "disable-dbbackup", 0, backup.DisableDatabase, nil)
+}
+
+func (s *Server) listJobs(req *request) {
+	writeJSON(req.w, http.StatusOK, s.jobs.list())
+}
+
+func (s *Server) getJob(req *request) {
+	job, ok := s.jobs.get(req.params["id"])
+	if !ok {
+		writeError(req.w, http.StatusNotFound, "job "+req.params["id"]+" does not exist")
+		return
+	}
+	writeJSON(req.w, http.StatusOK, job)
+}
@@ -0,0 +1,223 @@
+// Package api serves cliboard's JSON/HTTP API. It listens on a unix socket
+// or a loopback address, authenticates requests with scoped tokens and runs
+// slow operations as jobs that clients poll.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doko89/cliboard/internal/audit"
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// maxBodyBytes bounds the size of request bodies
+const maxBodyBytes = 1 << 20
+
+// DefaultListen returns the address the API listens on when none is given
+func DefaultListen() string {
+	return "unix:" + filepath.Join(config.RunDir, "api.sock")
+}
+
+// Server is the API's HTTP handler
+type Server struct {
+	jobs   *jobs
+	routes []route
+
+	// changes serializes operations that modify the configuration
+	changes sync.Mutex
+}
+
+// NewServer returns an API handler and starts its job worker
+func NewServer() *Server {
+	s := &Server{jobs: newJobs()}
+	s.routes = s.buildRoutes()
+	go s.jobs.work(s.run)
+	return s
+}
+
+// Serve runs the API on addr, either "unix:<path>" or a loopback host:port
+func Serve(addr string) error {
+	listener, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	server := &http.Server{
+		Handler:           NewServer(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      5 * time.Minute,
+	}
+
+	fmt.Printf("Serving the cliboard API on %s\n", addr)
+	return server.Serve(listener)
+}
+
+// listen opens the API's listener. TCP is restricted to loopback addresses
+// as the API is not meant to be exposed directly.
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create socket directory: %v", err)
+		}
+		// Remove a socket left behind by a previous run
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+		}
+		if err := os.Chmod(path, 0660); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to restrict socket permissions: %v", err)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %v", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("refusing to listen on %s, the API only listens on a unix socket or a loopback address", addr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	return listener, nil
+}
+
+// ServeHTTP authenticates and routes a request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	route, params, allowed := s.match(r.Method, r.URL.Path)
+	if route == nil {
+		if allowed {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		} else {
+			writeError(w, http.StatusNotFound, "not found")
+		}
+		return
+	}
+
+	req := &request{w: w, r: r, params: params}
+	if !route.public {
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		token, found := lookupToken(strings.TrimSpace(secret))
+		if !ok || !found {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cliboard"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		if r.Method != http.MethodGet && token.Scope != ScopeAdmin {
+			writeError(w, http.StatusForbidden, "token "+token.Name+" is read-only")
+			return
+		}
+		req.token = token
+	}
+
+	route.handle(req)
+}
+
+// change runs a modifying operation synchronously and responds with the
+// result of done, or with the operation's error
+func (s *Server) change(req *request, command string, status int, fn func() error, done func() (interface{}, error)) {
+	if err := s.run(req.token.Name, command, fn); err != nil {
+		writeError(req.w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if done == nil {
+		req.w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	result, err := done()
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(req.w, status, result)
+}
+
+// async queues a slow operation as a job and responds with the job
+func (s *Server) async(req *request, command string, fn func() error) {
+	job, err := s.jobs.submit(req.token.Name, command, fn)
+	if err != nil {
+		writeError(req.w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	req.w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(req.w, http.StatusAccepted, job)
+}
+
+// run executes a modifying operation on behalf of a token and records it in
+// the audit log and the configuration history
+func (s *Server) run(token, command string, fn func() error) error {
+	s.changes.Lock()
+	defer s.changes.Unlock()
+
+	err, record := audit.Run(audit.SourceAPI, token, command, fn)
+	if record != nil {
+		log.Printf("api: %v", record)
+	}
+
+	if err != nil {
+		log.Printf("api: %s (token %s): %v", command, token, err)
+	} else {
+		log.Printf("api: %s (token %s)", command, token)
+	}
+	return err
+}
+
+// request is a request being handled along with its path parameters and
+// authenticated token
+type request struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	params map[string]string
+	token  Token
+}
+
+// decode reads the JSON body of a request into v
+func (req *request) decode(v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(req.w, req.r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(req.w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeError(req.w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Scope limits what a token may do
+type Scope string
+
+const (
+	// ScopeRead allows GET requests only
+	ScopeRead Scope = "read"
+	// ScopeAdmin allows every request
+	ScopeAdmin Scope = "admin"
+)
+
+// tokenPrefix makes tokens easy to recognize, e.g. in leaked logs
+const tokenPrefix = "cbt_"
+
+// Token is an API token. Only a hash of the secret is stored.
+type Token struct {
+	Name    string    `json:"name"`
+	Scope   Scope     `json:"scope"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+var tokenNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// Tokens returns the API tokens sorted by name
+func Tokens() ([]Token, error) {
+	data, err := os.ReadFile(tokensFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %v", err)
+	}
+
+	var tokens []Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", tokensFile(), err)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	return tokens, nil
+}
+
+// CreateToken creates a token and returns its secret, which is not stored
+// and cannot be shown again
+func CreateToken(name string, scope Scope) (string, error) {
+	if !tokenNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid token name %q", name)
+	}
+	if scope != ScopeRead && scope != ScopeAdmin {
+		return "", fmt.Errorf("invalid scope %q, expected %s or %s", scope, ScopeRead, ScopeAdmin)
+	}
+
+	release, err := lock.Acquire(lock.Tokens)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	tokens, err := Tokens()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tokens {
+		if t.Name == name {
+			return "", fmt.Errorf("API token %s already exists", name)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	secret := tokenPrefix + hex.EncodeToString(b)
+
+	tokens = append(tokens, Token{Name: name, Scope: scope, Hash: hashToken(secret), Created: time.Now().UTC()})
+	if err := writeTokens(tokens); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// RevokeToken deletes a token
+func RevokeToken(name string) error {
+	release, err := lock.Acquire(lock.Tokens)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tokens, err := Tokens()
+	if err != nil {
+		return err
+	}
+
+	for i, t := range tokens {
+		if t.Name == name {
+			return writeTokens(append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return fmt.Errorf("API token %s does not exist", name)
+}
+
+// lookupToken returns the token matching a secret
+func lookupToken(secret string) (Token, bool) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, false
+	}
+
+	tokens, err := Tokens()
+	if err != nil {
+		return Token{}, false
+	}
+
+	hash := hashToken(secret)
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1 {
+			return t, true
+		}
+	}
+	return Token{}, false
+}
+
+// hashToken hashes a secret for storage. Tokens are long random strings, so
+// a plain hash is enough.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeTokens(tokens []Token) error {
+	if err := os.MkdirAll(config.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(tokensFile(), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write API tokens: %v", err)
+	}
+	return os.Chmod(tokensFile(), 0600)
+}
+
+func tokensFile() string {
+	return filepath.Join(config.ConfigDir, "api-tokens.json")
+}
@@ -0,0 +1,185 @@
+// Package audit keeps an append-only JSON-lines log of every operation that
+// changes the system, who ran it, what it touched and how it ended.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// Where an operation came from
+const (
+	SourceCLI   = "cli"
+	SourcePanel = "panel"
+	SourceAPI   = "api"
+	SourceTUI   = "tui"
+)
+
+// Entry is one audited operation
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	User     string    `json:"user"`
+	SudoUser string    `json:"sudo_user,omitempty"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args,omitempty"`
+	Site     string    `json:"site,omitempty"`
+	Files    []string  `json:"files,omitempty"`
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_seconds"`
+}
+
+// Filter selects entries in List
+type Filter struct {
+	Since time.Time
+	Site  string
+	// User matches the user or the user who ran sudo
+	User string
+}
+
+// Operation is an operation in progress
+type Operation struct {
+	entry  Entry
+	start  time.Time
+	before map[string]fileState
+}
+
+// Begin starts auditing an operation. user is who asked for it: empty for
+// the command line, which records the OS user and SUDO_USER instead.
+func Begin(source, user, command string, args []string) *Operation {
+	op := &Operation{
+		entry: Entry{Source: source, User: user, Command: command, Args: args},
+		start: time.Now(),
+	}
+
+	if source == SourceCLI || user == "" {
+		op.entry.User = osUser()
+		op.entry.SudoUser = os.Getenv("SUDO_USER")
+	}
+
+	// Deleted sites only show up in the arguments
+	for _, arg := range args {
+		if _, err := os.Stat(config.GetSiteConfigPath(arg)); err == nil && !strings.ContainsAny(arg, "/\\") {
+			op.entry.Site = arg
+			break
+		}
+	}
+
+	op.before = snapshot()
+	return op
+}
+
+// End records the outcome of an operation. Failures to write the log are
+// returned so callers can warn about them; they do not undo the operation.
+func (op *Operation) End(err error) error {
+	e := op.entry
+	e.Time = op.start.UTC()
+	e.Duration = time.Since(op.start).Round(time.Millisecond).Seconds()
+	e.Files = changed(op.before, snapshot())
+
+	if err != nil {
+		e.Result, e.Error = "error", err.Error()
+	} else {
+		e.Result = "ok"
+	}
+
+	// New sites only show up in the files
+	if e.Site == "" {
+		for _, f := range e.Files {
+			if filepath.Dir(f) == config.CaddySitesDir && strings.HasSuffix(f, ".caddy") {
+				e.Site = strings.TrimSuffix(filepath.Base(f), ".caddy")
+				break
+			}
+			if dir := strings.TrimSuffix(f, "/"); dir != f && filepath.Dir(dir) == config.SitesRootDir {
+				e.Site = filepath.Base(dir)
+				break
+			}
+		}
+	}
+
+	return write(e)
+}
+
+// List returns the entries matching filter, oldest first
+func List(filter Filter) ([]Entry, error) {
+	file, err := os.Open(logFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if !filter.Since.IsZero() && e.Time.Before(filter.Since) {
+			continue
+		}
+		if filter.Site != "" && e.Site != filter.Site {
+			continue
+		}
+		if filter.User != "" && e.User != filter.User && e.SudoUser != filter.User {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return entries, nil
+}
+
+// write appends an entry to the log in a single write, which O_APPEND keeps
+// whole when several processes log at once
+func write(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(config.LogDir, 0750); err != nil {
+		return fmt.Errorf("failed to create log directory: %v", err)
+	}
+	file, err := os.OpenFile(logFile(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
+
+func logFile() string {
+	return filepath.Join(config.LogDir, "audit.log")
+}
+
+// osUser returns the name of the user running cliboard
+func osUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// fileState is what a snapshot remembers of a file
+type fileState struct {
+	size    int64
+	mode    os.FileMode
+	modTime int64
+}
+
+// snapshot records the state of the files cliboard manages: the Caddy
+// configuration, cliboard's own configuration, its cron jobs and scripts
+// and the site directories themselves (not their contents)
+func snapshot() map[string]fileState {
+	files := map[string]fileState{}
+
+	add := func(path string, info os.FileInfo) {
+		files[path] = fileState{size: info.Size(), mode: info.Mode(), modTime: info.ModTime().UnixNano()}
+	}
+
+	for _, dir := range []string{config.CaddyRootDir, config.ConfigDir} {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !info.IsDir() {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+
+	for _, dir := range []string{config.CronDir, config.BinDir} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), "cliboard-") {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				add(filepath.Join(dir, entry.Name()), info)
+			}
+		}
+	}
+
+	sites, _ := os.ReadDir(config.SitesRootDir)
+	for _, entry := range sites {
+		if info, err := entry.Info(); err == nil && entry.IsDir() {
+			// Only whether the directory exists matters
+			files[filepath.Join(config.SitesRootDir, entry.Name())+"/"] = fileState{mode: info.Mode()}
+		}
+	}
+
+	return files
+}
+
+// changed returns the sorted paths that were added, removed or modified
+// between two snapshots
+func changed(before, after map[string]fileState) []string {
+	var paths []string
+	for path, state := range after {
+		if old, ok := before[path]; !ok || old != state {
+			paths = append(paths, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
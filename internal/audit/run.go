@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/history"
+)
+
+// Run runs a change made through the panel, the API or the TUI on behalf of
+// user. It records the change in the audit log and commits the configuration
+// to the history as "<source> <command> (by <user>)", so that every front end
+// records the same. err is what fn returned; record is set when the change
+// could not be recorded, which doesn't undo it.
+func Run(source, user, command string, fn func() error) (err, record error) {
+	fields := strings.Fields(command)
+	op := Begin(source, user, fields[0], fields[1:])
+	err = fn()
+
+	var problems []string
+	if aerr := op.End(err); aerr != nil {
+		problems = append(problems, fmt.Sprintf("failed to write audit log: %v", aerr))
+	}
+
+	if history.Available() {
+		msg := source + " " + command
+		if user != "" {
+			msg += " (by " + user + ")"
+		}
+		if err != nil {
+			msg += " (failed)"
+		}
+		if herr := history.Record(msg); herr != nil {
+			problems = append(problems, fmt.Sprintf("failed to record configuration history: %v", herr))
+		}
+	}
+
+	if len(problems) > 0 {
+		record = errors.New(strings.Join(problems, "; "))
+	}
+	return err, record
+}
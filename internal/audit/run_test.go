@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/history"
+)
+
+// tempConfig points the paths audit and history use at temporary directories
+func tempConfig(t *testing.T) {
+	t.Helper()
+	saved := []*string{&config.CaddyRootDir, &config.CaddySitesDir, &config.ConfigDir, &config.LogDir, &config.StateDir, &config.RunDir}
+	values := make([]string, len(saved))
+	for i, p := range saved {
+		values[i] = *p
+	}
+	t.Cleanup(func() {
+		for i, p := range saved {
+			*p = values[i]
+		}
+	})
+
+	root := t.TempDir()
+	config.CaddyRootDir = filepath.Join(root, "caddy")
+	config.CaddySitesDir = filepath.Join(config.CaddyRootDir, "sites.d")
+	config.ConfigDir = filepath.Join(root, "cliboard")
+	config.LogDir = filepath.Join(root, "log")
+	config.StateDir = filepath.Join(root, "state")
+	config.RunDir = filepath.Join(root, "run")
+	if err := os.MkdirAll(config.CaddySitesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tempConfig(t)
+	site := filepath.Join(config.CaddySitesDir, "example.com.caddy")
+
+	err, record := Run(SourcePanel, "alice", "create-site example.com --php=8.2", func() error {
+		return os.WriteFile(site, []byte("example.com {\n}\n"), 0644)
+	})
+	if err != nil || record != nil {
+		t.Fatalf("Run: %v, %v", err, record)
+	}
+
+	failure := errors.New("caddy validate failed")
+	err, _ = Run(SourceAPI, "deploy", "delete-site example.com", func() error { return failure })
+	if err != failure {
+		t.Fatalf("Run returned %v, want the error of the change", err)
+	}
+
+	entries, lerr := List(Filter{})
+	if lerr != nil || len(entries) != 2 {
+		t.Fatalf("audit log: %v, %v", entries, lerr)
+	}
+	e := entries[0]
+	if e.Source != SourcePanel || e.User != "alice" || e.Command != "create-site" || e.Site != "example.com" || e.Result != "ok" {
+		t.Errorf("first entry %+v", e)
+	}
+	if len(e.Args) != 2 || e.Args[1] != "--php=8.2" || len(e.Files) != 1 || e.Files[0] != site {
+		t.Errorf("first entry args %q, files %q", e.Args, e.Files)
+	}
+	e = entries[1]
+	if e.Source != SourceAPI || e.User != "deploy" || e.Result != "error" || e.Error != failure.Error() {
+		t.Errorf("second entry %+v", e)
+	}
+
+	if !history.Available() {
+		t.Skip("git is not installed")
+	}
+	changes, herr := history.List(0, "")
+	if herr != nil || len(changes) != 1 {
+		t.Fatalf("history: %v, %v", changes, herr)
+	}
+	if want := "panel create-site example.com --php=8.2 (by alice)"; changes[0].Command != want {
+		t.Errorf("history message %q, want %q", changes[0].Command, want)
+	}
+}
@@ -8,10 +8,20 @@ import (
 	"time"
 
 	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/doko89/cliboard/internal/utils"
 )
 
 // EnableSite enables automatic backup for a site
 func EnableSite(domain string) error {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check if site exists
 	siteDir := config.GetSiteDirectory(domain)
 	if _, err := os.Stat(siteDir); os.IsNotExist(err) {
@@ -31,37 +41,70 @@ func EnableSite(domain string) error {
 	}
 
 	// Create cron jobs for daily and weekly backups
-	dailyCron := fmt.Sprintf("0 1 * * * root rsync -a --delete --link-dest=%s/latest %s %s/$(date +%%Y%%m%%d) && ln -sf %s/$(date +%%Y%%m%%d) %s/latest\n",
-		dailyBackupDir, siteDir, dailyBackupDir, dailyBackupDir, dailyBackupDir)
+	site := config.Target(siteDir)
+	daily := config.Target(dailyBackupDir)
+	weekly := config.Target(weeklyBackupDir)
+
+	// cron turns a bare % into a newline. The backup hooks run around the
+	// copy and a pre-backup hook can skip it. Only a failed copy is reported
+	// through the notification channels, since cron output usually goes
+	// nowhere; a skipped backup is not a failure.
+	cliboard := config.GetBinaryPath()
+	hooks := func(name, kind string) string {
+		return fmt.Sprintf("%s hooks run %s-%s --site %s --data kind=%s", cliboard, name, hook.Backup, domain, kind)
+	}
+	failed := fmt.Sprintf("%s notify send %s --subject %s --message", cliboard, notify.BackupFailed, domain)
 
-	weeklyCron := fmt.Sprintf("0 2 * * 0 root rsync -a --delete %s %s/$(date +%%Y%%m%%d) && ln -sf %s/$(date +%%Y%%m%%d) %s/latest\n",
-		siteDir, weeklyBackupDir, weeklyBackupDir, weeklyBackupDir)
+	dailyCron := fmt.Sprintf("%s root %s && { rsync -a --delete --link-dest=%s/latest %s %s/$(date +\\%%Y\\%%m\\%%d) && ln -sfn %s/$(date +\\%%Y\\%%m\\%%d) %s/latest && { %s; true; } || %s 'daily backup failed'; }\n",
+		config.SiteDailySchedule, hooks("pre", "daily"), daily, site, daily, daily, daily, hooks("post", "daily"), failed)
+
+	weeklyCron := fmt.Sprintf("%s root %s && { rsync -a --delete %s %s/$(date +\\%%Y\\%%m\\%%d) && ln -sfn %s/$(date +\\%%Y\\%%m\\%%d) %s/latest && { %s; true; } || %s 'weekly backup failed'; }\n",
+		config.SiteWeeklySchedule, hooks("pre", "weekly"), site, weekly, weekly, weekly, hooks("post", "weekly"), failed)
+
+	// Write cron jobs to the cron directory
+	cronFile := siteCronPath(domain)
 
-	// Write cron jobs to /etc/cron.d/
-	cronFile := fmt.Sprintf("/etc/cron.d/cliboard-backup-%s", domain)
-	
 	cronContent := fmt.Sprintf("# CLIBoard backup cron jobs for %s\n%s%s", domain, dailyCron, weeklyCron)
-	
-	if err := os.WriteFile(cronFile, []byte(cronContent), 0644); err != nil {
+
+	if err := os.MkdirAll(config.CronDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cron directory: %v", err)
+	}
+
+	if err := utils.WriteFileAtomic(cronFile, []byte(cronContent), 0644); err != nil {
 		return fmt.Errorf("failed to create backup cron jobs: %v", err)
 	}
 
-	fmt.Printf("Automatic backups enabled for site %s\n", domain)
 	return nil
 }
 
 // DisableSite disables automatic backup for a site
 func DisableSite(domain string) error {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Remove cron jobs
-	cronFile := fmt.Sprintf("/etc/cron.d/cliboard-backup-%s", domain)
+	cronFile := siteCronPath(domain)
 	if err := os.Remove(cronFile); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove backup cron jobs: %v", err)
 	}
 
-	fmt.Printf("Automatic backups disabled for site %s\n", domain)
 	return nil
 }
 
+// SiteEnabled reports whether automatic backup is enabled for a site
+func SiteEnabled(domain string) bool {
+	_, err := os.Stat(siteCronPath(domain))
+	return err == nil
+}
+
+// SiteEnabledSince returns when automatic backup was last enabled for a site
+func SiteEnabledSince(domain string) (time.Time, bool) {
+	return modTime(siteCronPath(domain))
+}
+
 // EnableDatabase enables automatic database backup
 func EnableDatabase() error {
 	// Check if MariaDB/MySQL is installed
@@ -70,8 +113,8 @@ func EnableDatabase() error {
 	}
 
 	// Create backup directories
-	dailyBackupDir := "/backup/daily/database"
-	weeklyBackupDir := "/backup/weekly/database"
+	dailyBackupDir := databaseDailyPath()
+	weeklyBackupDir := databaseWeeklyPath()
 
 	if err := os.MkdirAll(dailyBackupDir, 0755); err != nil {
 		return fmt.Errorf("failed to create daily backup directory: %v", err)
@@ -95,55 +138,113 @@ mkdir -p "$BACKUP_DIR/$DATE"
 # Get list of databases
 DATABASES=$(mysql -u$MYSQL_USER ${MYSQL_PASSWORD:+-p$MYSQL_PASSWORD} -e "SHOW DATABASES;" | grep -Ev "(Database|information_schema|performance_schema)")
 
-# Backup each database
+# Backup each database, carrying on past failures
+FAILED=0
 for DB in $DATABASES; do
-    mysqldump -u$MYSQL_USER ${MYSQL_PASSWORD:+-p$MYSQL_PASSWORD} --single-transaction --skip-lock-tables "$DB" > "$BACKUP_DIR/$DATE/$DB.sql"
+    mysqldump -u$MYSQL_USER ${MYSQL_PASSWORD:+-p$MYSQL_PASSWORD} --single-transaction --skip-lock-tables "$DB" > "$BACKUP_DIR/$DATE/$DB.sql" || FAILED=1
 done
 
 # Create latest symlink
-ln -sf "$BACKUP_DIR/$DATE" "$BACKUP_DIR/latest"
+ln -sfn "$BACKUP_DIR/$DATE" "$BACKUP_DIR/latest"
+exit $FAILED
 `
 
-	backupScriptPath := "/usr/local/bin/cliboard-db-backup"
-	if err := os.WriteFile(backupScriptPath, []byte(backupScript), 0755); err != nil {
+	backupScriptPath := databaseScriptPath()
+	if err := os.MkdirAll(config.BinDir, 0755); err != nil {
+		return fmt.Errorf("failed to create script directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(backupScriptPath, []byte(backupScript), 0755); err != nil {
 		return fmt.Errorf("failed to create backup script: %v", err)
 	}
 
 	// Create cron jobs for daily and weekly backups
-	dailyCron := fmt.Sprintf("0 3 * * * root /usr/local/bin/cliboard-db-backup %s\n", dailyBackupDir)
-	weeklyCron := fmt.Sprintf("0 4 * * 0 root /usr/local/bin/cliboard-db-backup %s\n", weeklyBackupDir)
+	script := config.Target(backupScriptPath)
+	failed := fmt.Sprintf("%s notify send %s --subject database --message", config.GetBinaryPath(), notify.BackupFailed)
+	dailyCron := fmt.Sprintf("%s root %s %s || %s 'daily database backup failed'\n", config.DatabaseDailySchedule, script, config.Target(dailyBackupDir), failed)
+	weeklyCron := fmt.Sprintf("%s root %s %s || %s 'weekly database backup failed'\n", config.DatabaseWeeklySchedule, script, config.Target(weeklyBackupDir), failed)
+
+	// Write cron jobs to the cron directory
+	cronFile := databaseCronPath()
 
-	// Write cron jobs to /etc/cron.d/
-	cronFile := "/etc/cron.d/cliboard-db-backup"
-	
 	cronContent := fmt.Sprintf("# CLIBoard database backup cron jobs\n%s%s", dailyCron, weeklyCron)
-	
-	if err := os.WriteFile(cronFile, []byte(cronContent), 0644); err != nil {
+
+	if err := os.MkdirAll(config.CronDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cron directory: %v", err)
+	}
+
+	if err := utils.WriteFileAtomic(cronFile, []byte(cronContent), 0644); err != nil {
 		return fmt.Errorf("failed to create database backup cron jobs: %v", err)
 	}
 
-	fmt.Println("Automatic database backups enabled")
 	return nil
 }
 
+// DatabaseEnabled reports whether automatic database backup is enabled
+func DatabaseEnabled() bool {
+	_, err := os.Stat(databaseCronPath())
+	return err == nil
+}
+
+// DatabaseEnabledSince returns when automatic database backup was last enabled
+func DatabaseEnabledSince() (time.Time, bool) {
+	return modTime(databaseCronPath())
+}
+
 // DisableDatabase disables automatic database backup
 func DisableDatabase() error {
 	// Remove cron jobs
-	cronFile := "/etc/cron.d/cliboard-db-backup"
+	cronFile := databaseCronPath()
 	if err := os.Remove(cronFile); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove database backup cron jobs: %v", err)
 	}
 
 	// Remove backup script
-	backupScriptPath := "/usr/local/bin/cliboard-db-backup"
+	backupScriptPath := databaseScriptPath()
 	if err := os.Remove(backupScriptPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove backup script: %v", err)
 	}
 
-	fmt.Println("Automatic database backups disabled")
 	return nil
 }
 
+// siteCronPath returns the cron file holding the backup jobs of a site
+func siteCronPath(domain string) string {
+	return config.GetCronPath("cliboard-backup-" + domain)
+}
+
+// databaseCronPath returns the cron file holding the database backup jobs
+func databaseCronPath() string {
+	return config.GetCronPath("cliboard-db-backup")
+}
+
+// DatabaseDirs returns the daily and weekly database backup directories
+func DatabaseDirs() []string {
+	return []string{databaseDailyPath(), databaseWeeklyPath()}
+}
+
+// databaseScriptPath returns the path of the database backup script
+func databaseScriptPath() string {
+	return filepath.Join(config.BinDir, "cliboard-db-backup")
+}
+
+// databaseDailyPath returns the daily database backup directory
+func databaseDailyPath() string {
+	return filepath.Join(config.BackupDailyDir, "database")
+}
+
+// databaseWeeklyPath returns the weekly database backup directory
+func databaseWeeklyPath() string {
+	return filepath.Join(config.BackupWeeklyDir, "database")
+}
+
+func modTime(path string) (time.Time, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
 // Helper function to check if database is installed
 func isDatabaseInstalled() bool {
 	// Check for MariaDB
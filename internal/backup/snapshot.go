@@ -0,0 +1,192 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/doko89/cliboard/internal/lock"
+)
+
+// Snapshot is one backup of a site taken by the daily or weekly job
+type Snapshot struct {
+	Kind string    `json:"kind"` // "daily" or "weekly"
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+}
+
+// RunSite takes a daily backup of a site now, the same way the cron job does
+func RunSite(domain string) (Snapshot, error) {
+	var snapshot Snapshot
+	err := hook.Around(hook.Backup, domain, map[string]string{"kind": "daily"}, func() error {
+		var err error
+		snapshot, err = runSite(domain)
+		return err
+	})
+	return snapshot, err
+}
+
+func runSite(domain string) (Snapshot, error) {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer release()
+
+	siteDir := config.GetSiteDirectory(domain)
+	if _, err := os.Stat(siteDir); os.IsNotExist(err) {
+		return Snapshot{}, fmt.Errorf("site %s does not exist", domain)
+	}
+
+	dailyBackupDir := config.GetBackupDailyPath(domain)
+	if err := os.MkdirAll(dailyBackupDir, 0755); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to create daily backup directory: %v", err)
+	}
+
+	now := time.Now()
+	name := now.Format("20060102")
+	target := filepath.Join(dailyBackupDir, name)
+	latest := filepath.Join(dailyBackupDir, "latest")
+
+	// Hard-link unchanged files against the previous backup
+	args := []string{"-a", "--delete"}
+	if _, err := os.Stat(latest); err == nil {
+		args = append(args, "--link-dest="+latest)
+	}
+	args = append(args, siteDir, target)
+
+	if err := rsync(args...); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to back up site %s: %v", domain, err)
+	}
+
+	os.Remove(latest)
+	if err := os.Symlink(target, latest); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to update latest backup link: %v", err)
+	}
+
+	return Snapshot{Kind: "daily", Name: name, Time: now, Path: target}, nil
+}
+
+// Snapshots returns the backups of a site, newest first
+func Snapshots(domain string) ([]Snapshot, error) {
+	var snapshots []Snapshot
+
+	for kind, dir := range map[string]string{
+		"daily":  config.GetBackupDailyPath(domain),
+		"weekly": config.GetBackupWeeklyPath(domain),
+	} {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backups of site %s: %v", domain, err)
+		}
+
+		for _, entry := range entries {
+			// latest is a symlink to one of the snapshots
+			if !entry.IsDir() {
+				continue
+			}
+			t, err := time.ParseInLocation("20060102", entry.Name(), time.Local)
+			if err != nil {
+				continue
+			}
+			snapshots = append(snapshots, Snapshot{Kind: kind, Name: entry.Name(), Time: t, Path: filepath.Join(dir, entry.Name())})
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].Time.Equal(snapshots[j].Time) {
+			return snapshots[i].Time.After(snapshots[j].Time)
+		}
+		return snapshots[i].Kind < snapshots[j].Kind
+	})
+	return snapshots, nil
+}
+
+// RestoreSite replaces the files of a site with those of a backup
+func RestoreSite(domain, kind, name string) error {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	siteDir := config.GetSiteDirectory(domain)
+	if _, err := os.Stat(siteDir); os.IsNotExist(err) {
+		return fmt.Errorf("site %s does not exist", domain)
+	}
+
+	var dir string
+	switch kind {
+	case "daily":
+		dir = config.GetBackupDailyPath(domain)
+	case "weekly":
+		dir = config.GetBackupWeeklyPath(domain)
+	default:
+		return fmt.Errorf("unknown backup kind %q, expected daily or weekly", kind)
+	}
+
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+
+	// Backups hold the site directory itself, not its contents
+	source := filepath.Join(dir, name, domain)
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("backup %s/%s of site %s does not exist", kind, name, domain)
+	}
+
+	if err := rsync("-a", "--delete", source+"/", siteDir+"/"); err != nil {
+		return fmt.Errorf("failed to restore site %s: %v", domain, err)
+	}
+
+	return nil
+}
+
+func rsync(args ...string) error {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		return fmt.Errorf("rsync is not installed")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("rsync", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// LastSiteBackup returns when the latest daily backup of a site was taken
+func LastSiteBackup(domain string) (time.Time, bool) {
+	return lastBackup(config.GetBackupDailyPath(domain))
+}
+
+// LastDatabaseBackup returns when the latest daily database backup was taken
+func LastDatabaseBackup() (time.Time, bool) {
+	return lastBackup(databaseDailyPath())
+}
+
+// lastBackup follows the latest link of a backup directory. The snapshot
+// directory is created by the run, so its modification time is the time of
+// the backup.
+func lastBackup(dir string) (time.Time, bool) {
+	info, err := os.Stat(filepath.Join(dir, "latest"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
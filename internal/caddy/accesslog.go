@@ -0,0 +1,77 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// accessLogTailBytes bounds how much of an access log AccessLog reads
+const accessLogTailBytes = 64 * 1024
+
+// LogEntry is a request from a site's access log. Lines that are not JSON
+// only have Raw set.
+type LogEntry struct {
+	Time   string
+	Remote string
+	Method string
+	URI    string
+	Status int
+	Raw    string
+}
+
+// AccessLog returns up to n of the newest entries of a site's access log,
+// newest first
+func AccessLog(domain string, n int) []LogEntry {
+	f, err := os.Open(filepath.Join(config.CaddyLogDir, domain+".access.log"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > accessLogTailBytes {
+		f.Seek(-accessLogTailBytes, io.SeekEnd)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	var entries []LogEntry
+	for i := len(lines) - 1; i >= 0 && len(entries) < n; i-- {
+		line := lines[i]
+		var record struct {
+			TS      float64 `json:"ts"`
+			Status  int     `json:"status"`
+			Request struct {
+				RemoteIP string `json:"remote_ip"`
+				Method   string `json:"method"`
+				URI      string `json:"uri"`
+			} `json:"request"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			// The first line may have been cut by the seek
+			if i == 0 && len(lines) > 1 {
+				continue
+			}
+			entries = append(entries, LogEntry{Raw: string(line)})
+			continue
+		}
+
+		sec := int64(record.TS)
+		entries = append(entries, LogEntry{
+			Time:   time.Unix(sec, int64((record.TS-float64(sec))*1e9)).Format("2006-01-02 15:04:05"),
+			Remote: record.Request.RemoteIP,
+			Method: record.Request.Method,
+			URI:    record.Request.URI,
+			Status: record.Status,
+		})
+	}
+	return entries
+}
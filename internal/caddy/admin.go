@@ -0,0 +1,114 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// adminHost is the Host header sent to the admin API. Caddy only accepts
+// an empty host or a loopback address on unix socket listeners.
+const adminHost = "127.0.0.1"
+
+// AdminClient talks to the Caddy admin API over a unix socket
+type AdminClient struct {
+	SocketPath string
+	httpClient *http.Client
+}
+
+// AdminError is returned when the admin API rejects a request
+type AdminError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *AdminError) Error() string {
+	return fmt.Sprintf("admin API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// NewAdminClient returns a client for the admin API listening on socketPath
+func NewAdminClient(socketPath string) *AdminClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+
+	return &AdminClient{
+		SocketPath: socketPath,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+// Available reports whether the admin socket exists
+func (c *AdminClient) Available() bool {
+	info, err := os.Stat(c.SocketPath)
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeSocket != 0
+}
+
+// Load replaces the running configuration with the given JSON config
+func (c *AdminClient) Load(cfg []byte) error {
+	_, err := c.do(http.MethodPost, "/load", cfg)
+	return err
+}
+
+// Config returns the running configuration as JSON
+func (c *AdminClient) Config() ([]byte, error) {
+	return c.do(http.MethodGet, "/config/", nil)
+}
+
+func (c *AdminClient) do(method, path string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, "http://"+adminHost+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach admin API at %s: %v", c.SocketPath, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin API response: %v", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, &AdminError{StatusCode: resp.StatusCode, Message: adminErrorMessage(respBody)}
+	}
+
+	return respBody, nil
+}
+
+// adminErrorMessage extracts the error message from an admin API response
+func adminErrorMessage(body []byte) string {
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Error != "" {
+		return payload.Error
+	}
+	return string(bytes.TrimSpace(body))
+}
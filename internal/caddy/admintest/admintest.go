@@ -0,0 +1,158 @@
+// Package admintest provides a fake Caddy admin API for exercising the
+// admin client without a running Caddy.
+package admintest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Server is a fake Caddy admin API listening on a unix socket
+type Server struct {
+	SocketPath string
+
+	dir      string
+	listener net.Listener
+	server   *http.Server
+
+	mu          sync.Mutex
+	config      []byte
+	loads       [][]byte
+	loadErr     string
+	ignoreLoads bool
+}
+
+// NewServer starts a fake admin API on a socket in a new temporary directory
+func NewServer() (*Server, error) {
+	dir, err := os.MkdirTemp("", "caddy-admin")
+	if err != nil {
+		return nil, err
+	}
+
+	socketPath := filepath.Join(dir, "admin.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := &Server{
+		SocketPath: socketPath,
+		dir:        dir,
+		listener:   listener,
+		config:     []byte("null"),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/load", s.handleLoad)
+	mux.HandleFunc("/config/", s.handleConfig)
+	s.server = &http.Server{Handler: checkHost(mux)}
+
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// FailLoads makes subsequent loads fail with the given message.
+// An empty message makes loads succeed again.
+func (s *Server) FailLoads(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadErr = message
+}
+
+// IgnoreLoads makes subsequent loads succeed without changing the running
+// configuration, like a Caddy that quietly kept its old one
+func (s *Server) IgnoreLoads(ignore bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignoreLoads = ignore
+}
+
+// SetConfig replaces the configuration reported as running
+func (s *Server) SetConfig(cfg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = cfg
+}
+
+// Loads returns every configuration posted to /load, in order
+func (s *Server) Loads() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.loads...)
+}
+
+// Close stops the server and removes its socket
+func (s *Server) Close() error {
+	err := s.server.Close()
+	os.RemoveAll(s.dir)
+	return err
+}
+
+func (s *Server) handleLoad(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loads = append(s.loads, body)
+
+	if s.loadErr != "" {
+		writeError(w, http.StatusBadRequest, s.loadErr)
+		return
+	}
+
+	if !json.Valid(body) {
+		writeError(w, http.StatusBadRequest, "decoding request body: invalid JSON")
+		return
+	}
+
+	if !s.ignoreLoads {
+		s.config = body
+	}
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.config)
+}
+
+// checkHost mirrors Caddy's host check for admin sockets
+func checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "", "127.0.0.1", "::1":
+			next.ServeHTTP(w, r)
+		default:
+			writeError(w, http.StatusForbidden, "host not allowed: "+r.Host)
+		}
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
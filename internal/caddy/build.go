@@ -0,0 +1,208 @@
+package caddy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/service"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// ListModules returns the IDs of the modules compiled into the installed Caddy
+func ListModules() ([]string, error) {
+	return listModules("caddy")
+}
+
+// MissingModules returns the module IDs from ids that the installed Caddy lacks
+func MissingModules(ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	available, err := ListModules()
+	if err != nil {
+		return nil, err
+	}
+
+	have := make(map[string]bool, len(available))
+	for _, id := range available {
+		have[id] = true
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !have[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// Plugins returns the plugin packages recorded by the last custom build
+func Plugins() ([]string, error) {
+	data, err := os.ReadFile(pluginsFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Caddy plugin list: %v", err)
+	}
+
+	var plugins []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			plugins = append(plugins, line)
+		}
+	}
+	return plugins, nil
+}
+
+// Build compiles a custom Caddy with xcaddy, including the recorded plugins
+// plus with and minus without. The new binary is checked against the current
+// configuration, swapped in atomically and Caddy is restarted. The previous
+// binary is kept next to it with a .prev suffix, whose path is returned.
+func Build(with, without []string) (string, error) {
+	if _, err := exec.LookPath("xcaddy"); err != nil {
+		return "", fmt.Errorf("xcaddy is not installed, install it with: go install github.com/caddyserver/xcaddy/cmd/xcaddy@latest")
+	}
+
+	target, err := exec.LookPath("caddy")
+	if err != nil {
+		return "", fmt.Errorf("Caddy is not installed")
+	}
+	if target, err = filepath.EvalSymlinks(target); err != nil {
+		return "", fmt.Errorf("failed to resolve Caddy binary: %v", err)
+	}
+
+	release, err := lock.Acquire(lock.Plugins)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	plugins, err := Plugins()
+	if err != nil {
+		return "", err
+	}
+	plugins = mergePlugins(plugins, with, without)
+
+	// Build next to the current binary so the final rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".caddy-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary binary: %v", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	args := []string{"build", "--output", tmp.Name()}
+	for _, p := range plugins {
+		args = append(args, "--with", p)
+	}
+
+	event.Progress("Building Caddy with %d plugin(s)...", len(plugins))
+	output := event.Writer()
+	cmd := exec.Command("xcaddy", args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	output.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to build Caddy: %v", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", fmt.Errorf("failed to make Caddy binary executable: %v", err)
+	}
+
+	// Make sure the new binary accepts the current configuration
+	var stderr bytes.Buffer
+	validate := exec.Command(tmp.Name(), "validate", "--config", filepath.Join(config.CaddyRootDir, "Caddyfile"))
+	validate.Stderr = &stderr
+	if err := validate.Run(); err != nil {
+		return "", fmt.Errorf("new Caddy binary rejects the current configuration: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// Keep the previous binary and swap in the new one
+	previous := target + ".prev"
+	os.Remove(previous)
+	if err := os.Link(target, previous); err != nil {
+		return "", fmt.Errorf("failed to keep previous Caddy binary: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return "", fmt.Errorf("failed to replace Caddy binary: %v", err)
+	}
+
+	if err := writePlugins(plugins); err != nil {
+		return "", err
+	}
+
+	if err := service.Detect().Restart("caddy"); err != nil {
+		return "", fmt.Errorf("failed to restart Caddy: %v", err)
+	}
+
+	return previous, nil
+}
+
+// listModules runs list-modules on a Caddy binary and parses the module IDs
+func listModules(binary string) ([]string, error) {
+	output, err := exec.Command(binary, "list-modules").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Caddy modules: %v", err)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip blank lines and summaries such as "Standard modules: 112"
+		if line == "" || strings.ContainsAny(line, " :") {
+			continue
+		}
+		ids = append(ids, line)
+	}
+	return ids, nil
+}
+
+// mergePlugins returns the sorted plugin set with additions and removals applied
+func mergePlugins(plugins, with, without []string) []string {
+	set := map[string]bool{}
+	for _, p := range append(plugins, with...) {
+		set[p] = true
+	}
+	for _, p := range without {
+		delete(set, p)
+	}
+
+	merged := make([]string, 0, len(set))
+	for p := range set {
+		merged = append(merged, p)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func writePlugins(plugins []string) error {
+	if err := os.MkdirAll(config.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %v", err)
+	}
+
+	content := "# Caddy plugins built in by cliboard caddy build\n" + strings.Join(plugins, "\n") + "\n"
+	if err := utils.WriteFileAtomic(pluginsFile(), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to record Caddy plugins: %v", err)
+	}
+	return nil
+}
+
+func pluginsFile() string {
+	return filepath.Join(config.ConfigDir, "caddy-plugins")
+}
@@ -1,53 +1,208 @@
 package caddy
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/doko89/cliboard/internal/pkgmgr"
+	"github.com/doko89/cliboard/internal/service"
+	"github.com/doko89/cliboard/internal/utils"
 )
 
-// Reload reloads the Caddy server
+// Reloads are deferred while any hold is in place, see Hold
+var (
+	holdMu  sync.Mutex
+	holds   int
+	pending bool
+)
+
+// Hold defers reloads until the returned function is called, so a batch of
+// changes can be validated and loaded with a single reload. Holds nest, and
+// Reload calls made meanwhile, also by other goroutines, are not lost: the
+// last release reloads Caddy once if any were made, and returns the error.
+// Calling release more than once has no further effect.
+func Hold() (release func() error) {
+	holdMu.Lock()
+	holds++
+	holdMu.Unlock()
+
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			holdMu.Lock()
+			holds--
+			reloadNow := holds == 0 && pending
+			if reloadNow {
+				pending = false
+			}
+			holdMu.Unlock()
+
+			if reloadNow {
+				err = Reload()
+			}
+		})
+		return err
+	}
+}
+
+// deferred reports whether a hold is in place, remembering that a reload is
+// due when it is released
+func deferred() bool {
+	holdMu.Lock()
+	defer holdMu.Unlock()
+	if holds > 0 {
+		pending = true
+		return true
+	}
+	return false
+}
+
+// Reload reloads the Caddy server. It loads the configuration through the
+// admin API when its socket is available and falls back to the init system
+// otherwise.
 func Reload() error {
+	// A relocated tree is not served by the running Caddy
+	if config.Root != "" || deferred() {
+		return nil
+	}
+
 	// Check if Caddy is installed
 	if _, err := exec.LookPath("caddy"); err != nil {
 		return fmt.Errorf("Caddy is not installed")
 	}
 
-	// Run caddy reload
-	cmd := exec.Command("systemctl", "reload", "caddy")
-	if err := cmd.Run(); err != nil {
+	err := reload()
+	if err != nil {
+		// The change is on disk but not served, which needs attention
+		notify.Send(notify.Event{Name: notify.ReloadFailed, Message: err.Error()})
+	}
+	return err
+}
+
+func reload() error {
+	admin := NewAdminClient(config.CaddyAdminSocket)
+	if admin.Available() {
+		return reloadViaAdmin(admin)
+	}
+
+	// Reload through the init system
+	if err := service.Detect().Reload("caddy"); err != nil {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
 	return nil
 }
 
-// Install installs Caddy with the necessary configuration
+// Validate checks the main Caddyfile and everything it imports with caddy
+// validate. It does nothing for a relocated tree, whose imports point
+// outside of it, or when Caddy is not installed.
+func Validate() error {
+	if config.Root != "" || !Installed() {
+		return nil
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command("caddy", "validate", "--config", filepath.Join(config.CaddyRootDir, "Caddyfile"), "--adapter", "caddyfile")
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		return fmt.Errorf("caddy validate failed: %s", lines[len(lines)-1])
+	}
+	return nil
+}
+
+// Adapt converts a Caddyfile to Caddy's JSON configuration
+func Adapt(caddyfilePath string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("caddy", "adapt", "--config", caddyfilePath, "--adapter", "caddyfile")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// reloadViaAdmin adapts the main Caddyfile, loads it through the admin API
+// and reads the running configuration back to confirm it was applied
+func reloadViaAdmin(admin *AdminClient) error {
+	cfg, err := Adapt(filepath.Join(config.CaddyRootDir, "Caddyfile"))
+	if err != nil {
+		return fmt.Errorf("failed to adapt Caddy configuration: %v", err)
+	}
+
+	if err := admin.Load(cfg); err != nil {
+		return fmt.Errorf("failed to load Caddy configuration: %v", err)
+	}
+
+	running, err := admin.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read running Caddy configuration: %v", err)
+	}
+
+	if !sameJSON(cfg, running) {
+		return fmt.Errorf("running Caddy configuration does not match the loaded configuration")
+	}
+
+	return nil
+}
+
+// sameJSON reports whether two JSON documents are semantically equal
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// Installed reports whether Caddy is installed
+func Installed() bool {
+	_, err := exec.LookPath("caddy")
+	return err == nil
+}
+
+// Install installs Caddy with the necessary configuration. It does nothing
+// when Caddy is already installed.
 func Install() error {
-	// Check if Caddy is already installed
-	if _, err := exec.LookPath("caddy"); err == nil {
-		fmt.Println("Caddy is already installed")
+	if Installed() {
 		return nil
 	}
 
-	fmt.Println("Installing Caddy...")
+	event.Progress("Installing Caddy...")
+
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return err
+	}
 
 	// Add Caddy repository
-	cmd := exec.Command("sh", "-c", `
-    apt-get update
-    apt-get install -y debian-keyring debian-archive-keyring apt-transport-https curl
-    curl -1sLf 'https://dl.cloudsmith.io/public/caddy/stable/gpg.key' | gpg --dearmor -o /usr/share/keyrings/caddy-stable-archive-keyring.gpg
-    curl -1sLf 'https://dl.cloudsmith.io/public/caddy/stable/debian.deb.txt' | tee /etc/apt/sources.list.d/caddy-stable.list
-    apt-get update
-    apt-get install -y caddy
-    `)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if err := pm.AddCaddyRepository(); err != nil {
+		return fmt.Errorf("failed to install Caddy: %v", err)
+	}
 
-	if err := cmd.Run(); err != nil {
+	if err := pm.Install("caddy"); err != nil {
 		return fmt.Errorf("failed to install Caddy: %v", err)
 	}
 
@@ -66,30 +221,32 @@ func Install() error {
 	}
 
 	// Create main Caddy configuration
-	caddyConfig := `{
-    admin off
+	logDir := config.Target(config.CaddyLogDir)
+	caddyConfig := fmt.Sprintf(`{
+    admin unix/%s|0600
     log {
-        output file /var/log/caddy/access.log
+        output file %s/access.log
         format json
     }
-    email admin@localhost
+    email %s
 }
 
 (common) {
     log {
-        output file /var/log/caddy/{host}.access.log
+        output file %s/{host}.access.log
         format json
     }
     header ?Server "CLIBoard"
     encode gzip
 }
 
-import modules.d/*
-import php.d/*
-import sites.d/*
-`
+import %s/*
+import %s/*
+import %s/*
+`, config.Target(config.CaddyAdminSocket), logDir, config.ACMEEmail, logDir,
+		config.Target(config.CaddyModulesDir), config.Target(config.CaddyPHPDir), config.Target(config.CaddySitesDir))
 
-	if err := os.WriteFile(filepath.Join(config.CaddyRootDir, "Caddyfile"), []byte(caddyConfig), 0644); err != nil {
+	if err := utils.WriteFileAtomic(filepath.Join(config.CaddyRootDir, "Caddyfile"), []byte(caddyConfig), 0644); err != nil {
 		return fmt.Errorf("failed to create Caddy configuration: %v", err)
 	}
 
@@ -109,7 +266,9 @@ import sites.d/*
         remote_ip 192.168.0.0/16
     }
 }`,
-		"ratelimit": `(ratelimit) {
+		"metrics": MetricsModule(MetricsUpstream),
+		"ratelimit": `# cliboard:requires http.handlers.rate_limit github.com/mholt/caddy-ratelimit
+(ratelimit) {
     rate_limit {
         zone dynamic {
             key {remote_host}
@@ -141,7 +300,7 @@ import sites.d/*
 	}
 
 	for name, content := range defaultModules {
-		if err := os.WriteFile(filepath.Join(config.CaddyModulesDir, name), []byte(content), 0644); err != nil {
+		if err := utils.WriteFileAtomic(filepath.Join(config.CaddyModulesDir, name), []byte(content), 0644); err != nil {
 			return fmt.Errorf("failed to create module %s: %v", name, err)
 		}
 	}
@@ -152,11 +311,32 @@ import sites.d/*
 	}
 
 	// Restart Caddy
-	cmd = exec.Command("systemctl", "restart", "caddy")
-	if err := cmd.Run(); err != nil {
+	if err := service.Detect().Restart("caddy"); err != nil {
 		return fmt.Errorf("failed to restart Caddy: %v", err)
 	}
 
-	fmt.Println("Caddy installed successfully")
 	return nil
 }
+
+// MetricsUpstream is where cliboard metrics serve listens by default
+const MetricsUpstream = "127.0.0.1:9190"
+
+// MetricsModule returns the metrics module, which serves /metrics of
+// cliboard metrics serve to loopback and private addresses, the same as the
+// local-access module, and refuses everyone else. The matcher has its own
+// name, as a site importing local-access as well would otherwise define
+// @local twice.
+func MetricsModule(upstream string) string {
+	return fmt.Sprintf(`(metrics) {
+    @metrics_local {
+        remote_ip 127.0.0.1
+        remote_ip 10.0.0.0/8
+        remote_ip 172.16.0.0/12
+        remote_ip 192.168.0.0/16
+    }
+    route /metrics {
+        reverse_proxy @metrics_local %s
+        respond 403
+    }
+}`, upstream)
+}
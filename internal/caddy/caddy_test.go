@@ -0,0 +1,98 @@
+package caddy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/doko89/cliboard/internal/caddy/admintest"
+	"github.com/doko89/cliboard/internal/config"
+)
+
+const adapted = `{"apps":{"http":{"servers":{"srv0":{"listen":[":443"]}}}}}`
+
+// fakeCaddy puts a caddy on PATH whose adapt prints the adapted config
+func fakeCaddy(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho '" + adapted + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, "caddy"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	rootDir := config.CaddyRootDir
+	config.CaddyRootDir = dir
+	t.Cleanup(func() { config.CaddyRootDir = rootDir })
+}
+
+func newAdmin(t *testing.T) (*admintest.Server, *AdminClient) {
+	t.Helper()
+	server, err := admintest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, NewAdminClient(server.SocketPath)
+}
+
+func TestReloadViaAdmin(t *testing.T) {
+	fakeCaddy(t)
+	server, admin := newAdmin(t)
+
+	if !admin.Available() {
+		t.Fatal("admin socket not available")
+	}
+	if err := reloadViaAdmin(admin); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	loads := server.Loads()
+	if len(loads) != 1 || !sameJSON(loads[0], []byte(adapted)) {
+		t.Fatalf("loaded %q, want the adapted config", loads)
+	}
+}
+
+func TestReloadViaAdminLoadFailure(t *testing.T) {
+	fakeCaddy(t)
+	server, admin := newAdmin(t)
+	server.FailLoads("loading new config: http app module: start: listening on :443: address already in use")
+
+	err := reloadViaAdmin(admin)
+	if err == nil {
+		t.Fatal("reload succeeded, want the load error")
+	}
+	if !strings.Contains(err.Error(), "address already in use") {
+		t.Errorf("error %q does not carry the admin API message", err)
+	}
+}
+
+func TestReloadViaAdminMismatch(t *testing.T) {
+	fakeCaddy(t)
+	server, admin := newAdmin(t)
+	server.SetConfig([]byte(`{"apps":{}}`))
+	server.IgnoreLoads(true)
+
+	err := reloadViaAdmin(admin)
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("got %v, want a mismatch error", err)
+	}
+}
+
+func TestSameJSON(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`{"a":1,"b":[1,2]}`, `{ "b": [1, 2], "a": 1 }`, true},
+		{`{"a":1}`, `{"a":2}`, false},
+		{`{"b":[1,2]}`, `{"b":[2,1]}`, false},
+		{`{"a":1}`, `not json`, false},
+	}
+	for _, tt := range tests {
+		if got := sameJSON([]byte(tt.a), []byte(tt.b)); got != tt.want {
+			t.Errorf("sameJSON(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package caddy
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// Certificate is a certificate Caddy obtained and keeps in its storage
+type Certificate struct {
+	Names    []string  `json:"names"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	Path     string    `json:"path"`
+}
+
+// Certificates returns the certificates in Caddy's data directory, sorted by
+// expiry. Caddy stores them as certificates/<issuer>/<name>/<name>.crt.
+func Certificates() ([]Certificate, error) {
+	root := filepath.Join(config.CaddyDataDir, "certificates")
+	var certs []Certificate
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".crt") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// The leaf comes first, followed by the chain
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil
+		}
+
+		names := cert.DNSNames
+		if len(names) == 0 && cert.Subject.CommonName != "" {
+			names = []string{cert.Subject.CommonName}
+		}
+		certs = append(certs, Certificate{
+			Names:    names,
+			Issuer:   filepath.Base(filepath.Dir(filepath.Dir(path))),
+			NotAfter: cert.NotAfter,
+			Path:     path,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %v", err)
+	}
+
+	sort.Slice(certs, func(i, j int) bool { return certs[i].NotAfter.Before(certs[j].NotAfter) })
+	return certs, nil
+}
@@ -0,0 +1,288 @@
+// Package caddyfile parses, edits and formats Caddyfiles.
+//
+// Files are parsed into a tree of directives. Site blocks, snippets and the
+// global options block are top-level directives that carry a block. Tokens
+// are kept verbatim, including quotes, so unchanged parts of a file survive
+// a round trip through Parse and Format.
+package caddyfile
+
+import (
+	"regexp"
+	"strings"
+)
+
+// File is a parsed Caddyfile
+type File struct {
+	Items       []*Directive
+	EndComments []string
+}
+
+// Directive is a single line of a Caddyfile, optionally followed by a block.
+// For top-level blocks Name and Args hold the site addresses or snippet name.
+type Directive struct {
+	Name string
+	Args []string
+
+	// Breaks are the positions in Args before which the directive continues
+	// on the next line with a \, len(Args) being before the opening brace
+	Breaks []int
+
+	// HasBlock is set when the directive is followed by { ... }, even if empty
+	HasBlock bool
+	Block    []*Directive
+
+	// Comments are the comment lines directly above the directive, Comment is
+	// a trailing comment on the same line and EndComments are the comment lines
+	// before the closing brace of the block
+	Comments    []string
+	Comment     string
+	EndComments []string
+
+	// BlankBefore records a blank line above the directive
+	BlankBefore bool
+}
+
+// NewDirective returns a directive without a block
+func NewDirective(name string, args ...string) *Directive {
+	return &Directive{Name: name, Args: args}
+}
+
+// NewBlock returns a directive followed by an empty block
+func NewBlock(name string, args ...string) *Directive {
+	return &Directive{Name: name, Args: args, HasBlock: true}
+}
+
+// Keys returns the addresses of a site block, or the name of a snippet
+func (d *Directive) Keys() []string {
+	var keys []string
+	for _, tok := range append([]string{d.Name}, d.Args...) {
+		for _, key := range strings.Split(tok, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// Site returns the first top-level block serving address, or nil
+func (f *File) Site(address string) *Directive {
+	for _, d := range f.Items {
+		if !d.HasBlock || d.Name == "" || isSnippetName(d.Name) {
+			continue
+		}
+		for _, key := range d.Keys() {
+			if key == address || hostOf(key) == address {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// Sites returns all top-level site blocks
+func (f *File) Sites() []*Directive {
+	var sites []*Directive
+	for _, d := range f.Items {
+		if d.HasBlock && d.Name != "" && !isSnippetName(d.Name) {
+			sites = append(sites, d)
+		}
+	}
+	return sites
+}
+
+// Snippet returns the snippet block with the given name, or nil
+func (f *File) Snippet(name string) *Directive {
+	for _, d := range f.Items {
+		if d.HasBlock && d.Name == "("+name+")" && len(d.Args) == 0 {
+			return d
+		}
+	}
+	return nil
+}
+
+// Snippets returns all top-level snippet blocks
+func (f *File) Snippets() []*Directive {
+	var snippets []*Directive
+	for _, d := range f.Items {
+		if d.HasBlock && isSnippetName(d.Name) {
+			snippets = append(snippets, d)
+		}
+	}
+	return snippets
+}
+
+// SnippetName returns the name of a snippet block without parentheses
+func (d *Directive) SnippetName() string {
+	if !isSnippetName(d.Name) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(d.Name, "("), ")")
+}
+
+// Find returns the directives in the block whose name matches and whose
+// leading arguments equal args
+func (d *Directive) Find(name string, args ...string) []*Directive {
+	var found []*Directive
+	for _, child := range d.Block {
+		if child.matches(name, args) {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// First returns the first directive matching name and args, or nil
+func (d *Directive) First(name string, args ...string) *Directive {
+	if found := d.Find(name, args...); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// Index returns the position of child in the block, or -1
+func (d *Directive) Index(child *Directive) int {
+	for i, c := range d.Block {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert adds child to the block at position i. Out of range positions
+// append to the end of the block.
+func (d *Directive) Insert(i int, child *Directive) {
+	d.HasBlock = true
+	if i < 0 || i > len(d.Block) {
+		i = len(d.Block)
+	}
+	d.Block = append(d.Block, nil)
+	copy(d.Block[i+1:], d.Block[i:])
+	d.Block[i] = child
+}
+
+// Append adds child to the end of the block
+func (d *Directive) Append(child *Directive) {
+	d.Insert(len(d.Block), child)
+}
+
+// Remove removes child from the block and reports whether it was present
+func (d *Directive) Remove(child *Directive) bool {
+	i := d.Index(child)
+	if i < 0 {
+		return false
+	}
+	d.Block = append(d.Block[:i], d.Block[i+1:]...)
+	return true
+}
+
+// RemoveWhere removes every directive in the block for which match returns
+// true and returns how many were removed
+func (d *Directive) RemoveWhere(match func(*Directive) bool) int {
+	kept := d.Block[:0]
+	removed := 0
+	for _, child := range d.Block {
+		if match(child) {
+			removed++
+			continue
+		}
+		kept = append(kept, child)
+	}
+	d.Block = kept
+	return removed
+}
+
+// Move moves child to position i within the block
+func (d *Directive) Move(child *Directive, i int) bool {
+	if !d.Remove(child) {
+		return false
+	}
+	d.Insert(i, child)
+	return true
+}
+
+// Imports returns the arguments of the import directives in the block
+func (d *Directive) Imports() []string {
+	var imports []string
+	for _, child := range d.Find("import") {
+		if len(child.Args) > 0 {
+			imports = append(imports, Unquote(child.Args[0]))
+		}
+	}
+	return imports
+}
+
+// AddImport inserts an import after the imports at the top of the block.
+// It returns false if the import is already present.
+func (d *Directive) AddImport(name string) bool {
+	if d.First("import", name) != nil {
+		return false
+	}
+
+	i := 0
+	for i < len(d.Block) && d.Block[i].Name == "import" {
+		i++
+	}
+	d.Insert(i, NewDirective("import", name))
+	return true
+}
+
+// RemoveImport removes every import of name from the block and reports
+// whether any was present
+func (d *Directive) RemoveImport(name string) bool {
+	return d.RemoveWhere(func(child *Directive) bool {
+		return child.matches("import", []string{name})
+	}) > 0
+}
+
+func (d *Directive) matches(name string, args []string) bool {
+	if d.Name != name || len(d.Args) < len(args) {
+		return false
+	}
+	for i, arg := range args {
+		if Unquote(d.Args[i]) != arg {
+			return false
+		}
+	}
+	return true
+}
+
+var needsQuoting = regexp.MustCompile(`[\s"{}#]|^$`)
+
+// Quote returns s as a token, quoting it if necessary
+func Quote(s string) string {
+	if !needsQuoting.MatchString(s) {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Unquote returns the value of a raw token
+func Unquote(tok string) string {
+	if len(tok) >= 2 && tok[0] == '"' && tok[len(tok)-1] == '"' {
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(tok[1 : len(tok)-1])
+	}
+	if len(tok) >= 2 && tok[0] == '`' && tok[len(tok)-1] == '`' {
+		return tok[1 : len(tok)-1]
+	}
+	return tok
+}
+
+func isSnippetName(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "(") && strings.HasSuffix(name, ")")
+}
+
+// hostOf strips the scheme and port from a site address
+func hostOf(address string) string {
+	if i := strings.Index(address, "://"); i >= 0 {
+		address = address[i+3:]
+	}
+	if i := strings.Index(address, "/"); i >= 0 {
+		address = address[:i]
+	}
+	if i := strings.LastIndex(address, ":"); i >= 0 && !strings.HasSuffix(address, "]") {
+		address = address[:i]
+	}
+	return address
+}
@@ -0,0 +1,173 @@
+package caddyfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// want is the formatted source, when it differs from src
+		want string
+	}{
+		{
+			name: "site",
+			src:  "example.com {\n    root * /var/www/example.com\n    file_server\n}\n",
+		},
+		{
+			name: "tabs",
+			src:  "example.com {\n\troot * /srv\n\tfile_server\n}\n",
+			want: "example.com {\n    root * /srv\n    file_server\n}\n",
+		},
+		{
+			name: "comments",
+			src:  "# header\n\nexample.com { # site\n    # above\n    file_server # trailing\n\n    # before end\n}\n\n# end\n",
+		},
+		{
+			name: "comments without blank lines",
+			src:  "# header\nexample.com {\n    file_server\n    # before end\n}\n# end\n",
+		},
+		{
+			name: "blank lines",
+			src:  "example.com {\n    encode gzip\n\n    file_server\n}\n",
+		},
+		{
+			name: "quoted tokens",
+			src:  "example.com {\n    respond \"hello { world # not a comment\" 200\n    header X-Quote \"say \\\"hi\\\"\"\n}\n",
+		},
+		{
+			name: "backquoted tokens",
+			src:  "example.com {\n    header X-Raw `a \"b\" \\c`\n}\n",
+		},
+		{
+			name: "heredoc",
+			src:  "example.com {\n    respond <<HTML\n        <p>{ not a block }</p>\n        HTML 200\n    log\n}\n",
+		},
+		{
+			name: "continuation",
+			src:  "example.com {\n    reverse_proxy 10.0.0.1:80 \\\n        10.0.0.2:80 \\\n        10.0.0.3:80\n}\n",
+		},
+		{
+			name: "continuation before brace",
+			src:  "example.com www.example.com \\\n{\n    respond ok\n}\n",
+		},
+		{
+			name: "continuation indentation",
+			src:  "reverse_proxy a \\\n\t\tb\n",
+			want: "reverse_proxy a \\\n    b\n",
+		},
+		{
+			name: "nested blocks",
+			src:  "example.com {\n    handle /api/* {\n        reverse_proxy localhost:8080 {\n            lb_policy first\n        }\n    }\n}\n",
+		},
+		{
+			name: "snippets and imports",
+			src:  "(security) {\n    header X-Frame-Options DENY\n}\n\nexample.com {\n    import security\n    import /etc/caddy/php.d/8.2.caddy\n}\n",
+		},
+		{
+			name: "global options",
+			src:  "{\n    admin unix//var/lib/caddy/admin.sock\n}\n\nimport sites.d/*.caddy\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.src
+			}
+			got := string(f.Format())
+			if got != want {
+				t.Fatalf("Format:\n%s\nwant:\n%s", got, want)
+			}
+
+			// Formatted output is stable
+			f, err = Parse([]byte(got))
+			if err != nil {
+				t.Fatalf("Parse of formatted source: %v", err)
+			}
+			if again := string(f.Format()); again != got {
+				t.Fatalf("second Format:\n%s\nwant:\n%s", again, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"missing closing brace", "example.com {\n    file_server\n", "missing }"},
+		{"unexpected closing brace", "example.com\n}\n", "line 2: unexpected }"},
+		{"unterminated quote", "example.com {\n    respond \"hello\n}\n", "line 2: unterminated quoted string"},
+		{"unterminated backquote", "example.com {\n    respond `hello\n}\n", "unterminated quoted string"},
+		{"unclosed heredoc", "respond <<EOF\nhello\n", `heredoc marker "EOF" is never closed`},
+		{"token after brace", "example.com {\n} extra\n", `line 2: unexpected "extra" after }`},
+		{"stray continuation", "\\\nexample.com\n", "line 1: line continuation outside of a directive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	src := "(common) {\n    encode gzip\n}\n\nexample.com, www.example.com {\n    import common\n    reverse_proxy a \\\n        b\n}\n"
+	f, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := f.Snippet("common"); s == nil || len(s.Block) != 1 {
+		t.Fatalf("snippet common not parsed: %+v", s)
+	}
+	site := f.Site("www.example.com")
+	if site == nil {
+		t.Fatal("site www.example.com not found")
+	}
+	if got := strings.Join(site.Keys(), " "); got != "example.com www.example.com" {
+		t.Errorf("keys %q", got)
+	}
+	if got := site.Imports(); len(got) != 1 || got[0] != "common" {
+		t.Errorf("imports %q", got)
+	}
+	proxy := site.First("reverse_proxy")
+	if proxy == nil || strings.Join(proxy.Args, " ") != "a b" {
+		t.Fatalf("continued directive parsed as %+v", proxy)
+	}
+}
+
+func TestEditKeepsContinuations(t *testing.T) {
+	src := "example.com {\n    reverse_proxy a \\\n        b\n}\n"
+	f, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Site("example.com").AddImport("php82")
+
+	want := "example.com {\n    import php82\n    reverse_proxy a \\\n        b\n}\n"
+	if got := string(f.Format()); got != want {
+		t.Fatalf("Format:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{"plain", "two words", `say "hi"`, `back\slash`, "{", "#", ""} {
+		if got := Unquote(Quote(s)); got != s {
+			t.Errorf("Unquote(Quote(%q)) = %q", s, got)
+		}
+	}
+}
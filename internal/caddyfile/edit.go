@@ -0,0 +1,41 @@
+package caddyfile
+
+import "fmt"
+
+// EditSite parses the Caddyfile at path, calls edit with the block serving
+// address and writes the file back formatted. Nothing is written if edit
+// returns an error.
+func EditSite(path, address string, edit func(site *Directive) error) error {
+	f, err := ParseFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read site configuration: %v", err)
+	}
+
+	site := f.Site(address)
+	if site == nil {
+		return fmt.Errorf("no site block for %s in %s", address, path)
+	}
+
+	if err := edit(site); err != nil {
+		return err
+	}
+
+	if err := f.WriteFile(path); err != nil {
+		return fmt.Errorf("failed to update site configuration: %v", err)
+	}
+	return nil
+}
+
+// ReadSite parses the Caddyfile at path and returns the block serving address
+func ReadSite(path, address string) (*Directive, error) {
+	f, err := ParseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read site configuration: %v", err)
+	}
+
+	site := f.Site(address)
+	if site == nil {
+		return nil, fmt.Errorf("no site block for %s in %s", address, path)
+	}
+	return site, nil
+}
@@ -0,0 +1,105 @@
+package caddyfile
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+const indent = "    "
+
+// Format returns the file as formatted Caddyfile source
+func (f *File) Format() []byte {
+	var b bytes.Buffer
+
+	for i, d := range f.Items {
+		if i > 0 && (d.BlankBefore || d.HasBlock || f.Items[i-1].HasBlock) {
+			b.WriteString("\n")
+		}
+		writeDirective(&b, d, 0)
+	}
+
+	writeComments(&b, f.EndComments, 0)
+
+	return b.Bytes()
+}
+
+// WriteFile formats the file and atomically replaces path with it, keeping
+// the mode of an existing file
+func (f *File) WriteFile(path string) error {
+	return utils.WriteFileAtomic(path, f.Format(), 0644)
+}
+
+// Format returns the directive and its block as formatted source
+func (d *Directive) Format() []byte {
+	var b bytes.Buffer
+	writeDirective(&b, d, 0)
+	return b.Bytes()
+}
+
+func writeDirective(b *bytes.Buffer, d *Directive, depth int) {
+	prefix := strings.Repeat(indent, depth)
+
+	writeComments(b, d.Comments, depth)
+
+	b.WriteString(prefix)
+	b.WriteString(d.Name)
+	for i, arg := range d.Args {
+		writeSeparator(b, d, i, depth)
+		b.WriteString(arg)
+	}
+	if d.HasBlock {
+		if d.Name != "" {
+			// A brace on a line of its own lines up with the closing one
+			writeSeparator(b, d, len(d.Args), depth-1)
+		}
+		b.WriteString("{")
+	}
+	if d.Comment != "" {
+		b.WriteString(" ")
+		b.WriteString(d.Comment)
+	}
+	b.WriteString("\n")
+
+	if !d.HasBlock {
+		return
+	}
+
+	for i, child := range d.Block {
+		if i > 0 && child.BlankBefore {
+			b.WriteString("\n")
+		}
+		writeDirective(b, child, depth+1)
+	}
+	writeComments(b, d.EndComments, depth+1)
+
+	b.WriteString(prefix)
+	b.WriteString("}\n")
+}
+
+// writeSeparator writes what goes before argument i: a space, or a line
+// continuation where the directive was broken over lines
+func writeSeparator(b *bytes.Buffer, d *Directive, i, depth int) {
+	for _, at := range d.Breaks {
+		if at == i {
+			b.WriteString(" \\\n")
+			b.WriteString(strings.Repeat(indent, depth+1))
+			return
+		}
+	}
+	b.WriteString(" ")
+}
+
+func writeComments(b *bytes.Buffer, comments []string, depth int) {
+	prefix := strings.Repeat(indent, depth)
+	for _, c := range comments {
+		if c == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(prefix)
+		b.WriteString(c)
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,151 @@
+package caddyfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenNewline
+	tokenComment
+	tokenContinuation
+)
+
+// token is a single lexical token. Word tokens keep their raw text,
+// including any quotes, so the file can be written back unchanged.
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+var heredocStart = regexp.MustCompile(`^<<([A-Za-z0-9_-]+)$`)
+
+// tokenize splits Caddyfile source into tokens
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	var current strings.Builder
+	var quote rune
+	line := 1
+	tokenLine := 1
+
+	runes := []rune(src)
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{kind: tokenWord, text: current.String(), line: tokenLine})
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		// Inside a quoted string everything up to the closing quote is kept
+		if quote != 0 {
+			current.WriteRune(r)
+			if r == '\n' {
+				line++
+			}
+			if r == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+				if runes[i] == '\n' {
+					line++
+				}
+				continue
+			}
+			if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case r == '"' || r == '`':
+			if current.Len() == 0 {
+				tokenLine = line
+			}
+			quote = r
+			current.WriteRune(r)
+
+		case r == '\\' && i+1 < len(runes) && runes[i+1] == '\n':
+			// Line continuation
+			flush()
+			tokens = append(tokens, token{kind: tokenContinuation, line: line})
+			i++
+			line++
+
+		case r == '\n':
+			flush()
+			if m := heredocStart.FindStringSubmatch(lastWord(tokens)); m != nil {
+				body, consumed, lines, err := readHeredoc(runes[i+1:], m[1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				// Tokens after the closing marker continue the directive
+				tokens[len(tokens)-1].text += "\n" + body
+				i += consumed
+				line += lines + 1
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenNewline, line: line})
+			line++
+
+		case r == ' ' || r == '\t' || r == '\r':
+			flush()
+
+		case r == '#' && current.Len() == 0:
+			start := i
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenComment, text: strings.TrimRight(string(runes[start:i]), " \t\r"), line: line})
+			i--
+
+		default:
+			if current.Len() == 0 {
+				tokenLine = line
+			}
+			current.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("line %d: unterminated quoted string", tokenLine)
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// lastWord returns the text of the last token if it is a word on the current line
+func lastWord(tokens []token) string {
+	if len(tokens) == 0 || tokens[len(tokens)-1].kind != tokenWord {
+		return ""
+	}
+	return tokens[len(tokens)-1].text
+}
+
+// readHeredoc reads heredoc lines up to and including the closing marker.
+// Tokens after the marker on the closing line are left for the lexer.
+// It returns the body, the number of runes consumed and the number of lines read.
+func readHeredoc(runes []rune, marker string) (string, int, int, error) {
+	lines := strings.SplitAfter(string(runes), "\n")
+	consumed := 0
+	for i, l := range lines {
+		trimmed := strings.TrimLeft(l, " \t")
+		rest := strings.TrimPrefix(trimmed, marker)
+		if rest != trimmed && (rest == "" || strings.ContainsRune(" \t\r\n", rune(rest[0]))) {
+			closing := l[:len(l)-len(rest)]
+			body := strings.Join(lines[:i], "") + closing
+			return body, consumed + len([]rune(closing)), i, nil
+		}
+		consumed += len([]rune(l))
+	}
+	return "", 0, 0, fmt.Errorf("heredoc marker %q is never closed", marker)
+}
@@ -0,0 +1,191 @@
+package caddyfile
+
+import (
+	"fmt"
+	"os"
+)
+
+// Parse parses Caddyfile source into a File
+func Parse(src []byte) (*File, error) {
+	tokens, err := tokenize(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	items, endComments, err := p.parseBody(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{Items: items, EndComments: endComments}, nil
+}
+
+// ParseFile reads and parses the Caddyfile at path
+func ParseFile(path string) (*File, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) next() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, true
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// parseBody parses directives until the end of input or, when nested,
+// until the closing brace of the current block
+func (p *parser) parseBody(nested bool) ([]*Directive, []string, error) {
+	var items []*Directive
+	var comments []string
+	newlines := 0
+	blank := false
+
+	for {
+		tok, ok := p.next()
+		if !ok {
+			if nested {
+				return nil, nil, fmt.Errorf("unexpected end of file: missing }")
+			}
+			return items, endComments(comments, blank), nil
+		}
+
+		switch tok.kind {
+		case tokenNewline:
+			newlines++
+			continue
+
+		case tokenComment:
+			if newlines > 1 {
+				if len(comments) > 0 {
+					comments = append(comments, "")
+				} else {
+					blank = true
+				}
+			}
+			comments = append(comments, tok.text)
+			newlines = 0
+			continue
+
+		case tokenContinuation:
+			return nil, nil, fmt.Errorf("line %d: line continuation outside of a directive", tok.line)
+		}
+
+		if tok.text == "}" {
+			if !nested {
+				return nil, nil, fmt.Errorf("line %d: unexpected }", tok.line)
+			}
+			return items, endComments(comments, blank), nil
+		}
+
+		d, err := p.parseDirective(tok)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(comments) > 0 && newlines > 1 {
+			// Keep the blank line between the comments and the directive
+			comments = append(comments, "")
+		}
+		d.Comments = comments
+		d.BlankBefore = blank || (len(comments) == 0 && newlines > 1)
+		items = append(items, d)
+
+		comments = nil
+		newlines = 0
+		blank = false
+	}
+}
+
+// endComments returns the comments before the end of a body, keeping a
+// blank line above them
+func endComments(comments []string, blank bool) []string {
+	if blank && len(comments) > 0 {
+		return append([]string{""}, comments...)
+	}
+	return comments
+}
+
+// parseDirective parses the rest of a directive whose first token is first
+func (p *parser) parseDirective(first token) (*Directive, error) {
+	d := &Directive{}
+	if first.text == "{" {
+		// A bare block, such as the global options block
+		return d, p.parseBlock(d)
+	}
+	d.Name = first.text
+
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokenNewline {
+			return d, nil
+		}
+
+		if tok.kind == tokenComment {
+			p.pos++
+			d.Comment = tok.text
+			return d, nil
+		}
+
+		if tok.kind == tokenContinuation {
+			p.pos++
+			d.Breaks = append(d.Breaks, len(d.Args))
+			continue
+		}
+
+		if tok.text == "}" {
+			return d, nil
+		}
+
+		p.pos++
+		if tok.text == "{" {
+			return d, p.parseBlock(d)
+		}
+		d.Args = append(d.Args, tok.text)
+	}
+}
+
+// parseBlock parses the body of d after its opening brace
+func (p *parser) parseBlock(d *Directive) error {
+	d.HasBlock = true
+
+	if tok, ok := p.peek(); ok && tok.kind == tokenComment {
+		p.pos++
+		d.Comment = tok.text
+	}
+
+	block, endComments, err := p.parseBody(true)
+	if err != nil {
+		return err
+	}
+	d.Block = block
+	d.EndComments = endComments
+
+	// Anything after the closing brace on the same line is a comment or an error
+	if tok, ok := p.peek(); ok && tok.kind == tokenWord && tok.text != "}" {
+		return fmt.Errorf("line %d: unexpected %q after }", tok.line, tok.text)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when no other config file is given
+const DefaultConfigFile = "/etc/cliboard/config.yaml"
+
+// DefaultUpdateURL is where releases are published for self-update
+const DefaultUpdateURL = "https://github.com/doko89/cliboard/releases/download"
+
+// ReleasePublicKey is the base64 ed25519 public key releases are signed
+// with. Release builds set it with -ldflags "-X
+// github.com/doko89/cliboard/internal/config.ReleasePublicKey=<key>".
+var ReleasePublicKey = ""
+
+// Settings is the content of the config file. Empty values fall back to
+// the defaults, and the Caddy subdirectories default to CaddyRoot.
+type Settings struct {
+	Paths struct {
+		SitesRoot        string `yaml:"sites_root"`
+		CaddyRoot        string `yaml:"caddy_root"`
+		CaddyModules     string `yaml:"caddy_modules"`
+		CaddyPHP         string `yaml:"caddy_php"`
+		CaddySites       string `yaml:"caddy_sites"`
+		CaddyLogs        string `yaml:"caddy_logs"`
+		CaddyAdminSocket string `yaml:"caddy_admin_socket"`
+		CaddyData        string `yaml:"caddy_data"`
+		ConfigDir        string `yaml:"config_dir"`
+		StateDir         string `yaml:"state_dir"`
+		RunDir           string `yaml:"run_dir"`
+		LogDir           string `yaml:"log_dir"`
+		BackupDaily      string `yaml:"backup_daily"`
+		BackupWeekly     string `yaml:"backup_weekly"`
+		CronDir          string `yaml:"cron_dir"`
+		BinDir           string `yaml:"bin_dir"`
+	} `yaml:"paths"`
+
+	Schedule struct {
+		SiteDaily      string `yaml:"site_daily"`
+		SiteWeekly     string `yaml:"site_weekly"`
+		DatabaseDaily  string `yaml:"database_daily"`
+		DatabaseWeekly string `yaml:"database_weekly"`
+	} `yaml:"schedule"`
+
+	Hooks struct {
+		Timeout string `yaml:"timeout"`
+	} `yaml:"hooks"`
+
+	Update struct {
+		URL       string `yaml:"url"`
+		Channel   string `yaml:"channel"`
+		PublicKey string `yaml:"public_key"`
+	} `yaml:"update"`
+
+	ACMEEmail string `yaml:"acme_email"`
+}
+
+// Options control where configuration is read from
+type Options struct {
+	// File is the config file. It defaults to $CLIBOARD_CONFIG, then to
+	// DefaultConfigFile under Root.
+	File string
+
+	// Root relocates every filesystem path. It defaults to $CLIBOARD_ROOT.
+	Root string
+
+	// Set holds key=value overrides, e.g. paths.sites_root=/srv/sites
+	Set []string
+}
+
+// Backup schedules, the ACME account email, the hook timeout and the
+// self-update settings, set by Load
+var (
+	SiteDailySchedule      = "0 1 * * *"
+	SiteWeeklySchedule     = "0 2 * * 0"
+	DatabaseDailySchedule  = "0 3 * * *"
+	DatabaseWeeklySchedule = "0 4 * * 0"
+
+	ACMEEmail = "admin@localhost"
+
+	// HookTimeout is how long a single hook may run, e.g. "60s"
+	HookTimeout = "60s"
+
+	// UpdateURL, UpdateChannel and UpdatePublicKey configure self-update
+	UpdateURL       = DefaultUpdateURL
+	UpdateChannel   = "stable"
+	UpdatePublicKey = ""
+)
+
+// current holds the settings in effect after Load, without the root prefix
+var current = Defaults()
+
+// Defaults returns the built-in settings
+func Defaults() Settings {
+	var s Settings
+	s.Paths.SitesRoot = "/apps/sites"
+	s.Paths.CaddyRoot = "/etc/caddy"
+	s.Paths.CaddyLogs = "/var/log/caddy"
+	s.Paths.CaddyAdminSocket = "/var/lib/caddy/admin.sock"
+	s.Paths.CaddyData = "/var/lib/caddy/.local/share/caddy"
+	s.Paths.ConfigDir = "/etc/cliboard"
+	s.Paths.StateDir = "/var/lib/cliboard"
+	s.Paths.RunDir = "/run/cliboard"
+	s.Paths.LogDir = "/var/log/cliboard"
+	s.Paths.BackupDaily = "/backup/daily"
+	s.Paths.BackupWeekly = "/backup/weekly"
+	s.Paths.CronDir = "/etc/cron.d"
+	s.Paths.BinDir = "/usr/local/bin"
+	s.Schedule.SiteDaily = "0 1 * * *"
+	s.Schedule.SiteWeekly = "0 2 * * 0"
+	s.Schedule.DatabaseDaily = "0 3 * * *"
+	s.Schedule.DatabaseWeekly = "0 4 * * 0"
+	s.Hooks.Timeout = "60s"
+	s.Update.URL = DefaultUpdateURL
+	s.Update.Channel = "stable"
+	s.Update.PublicKey = ReleasePublicKey
+	s.ACMEEmail = "admin@localhost"
+	return s
+}
+
+// setting describes one overridable setting
+type setting struct {
+	key   string
+	env   string
+	value *string
+}
+
+// settings lists every setting with its config key and environment variable
+func (s *Settings) settings() []setting {
+	return []setting{
+		{"paths.sites_root", "CLIBOARD_SITES_ROOT", &s.Paths.SitesRoot},
+		{"paths.caddy_root", "CLIBOARD_CADDY_ROOT", &s.Paths.CaddyRoot},
+		{"paths.caddy_modules", "CLIBOARD_CADDY_MODULES", &s.Paths.CaddyModules},
+		{"paths.caddy_php", "CLIBOARD_CADDY_PHP", &s.Paths.CaddyPHP},
+		{"paths.caddy_sites", "CLIBOARD_CADDY_SITES", &s.Paths.CaddySites},
+		{"paths.caddy_logs", "CLIBOARD_CADDY_LOGS", &s.Paths.CaddyLogs},
+		{"paths.caddy_admin_socket", "CLIBOARD_CADDY_ADMIN_SOCKET", &s.Paths.CaddyAdminSocket},
+		{"paths.caddy_data", "CLIBOARD_CADDY_DATA", &s.Paths.CaddyData},
+		{"paths.config_dir", "CLIBOARD_CONFIG_DIR", &s.Paths.ConfigDir},
+		{"paths.state_dir", "CLIBOARD_STATE_DIR", &s.Paths.StateDir},
+		{"paths.run_dir", "CLIBOARD_RUN_DIR", &s.Paths.RunDir},
+		{"paths.log_dir", "CLIBOARD_LOG_DIR", &s.Paths.LogDir},
+		{"paths.backup_daily", "CLIBOARD_BACKUP_DAILY", &s.Paths.BackupDaily},
+		{"paths.backup_weekly", "CLIBOARD_BACKUP_WEEKLY", &s.Paths.BackupWeekly},
+		{"paths.cron_dir", "CLIBOARD_CRON_DIR", &s.Paths.CronDir},
+		{"paths.bin_dir", "CLIBOARD_BIN_DIR", &s.Paths.BinDir},
+		{"schedule.site_daily", "CLIBOARD_SCHEDULE_SITE_DAILY", &s.Schedule.SiteDaily},
+		{"schedule.site_weekly", "CLIBOARD_SCHEDULE_SITE_WEEKLY", &s.Schedule.SiteWeekly},
+		{"schedule.database_daily", "CLIBOARD_SCHEDULE_DATABASE_DAILY", &s.Schedule.DatabaseDaily},
+		{"schedule.database_weekly", "CLIBOARD_SCHEDULE_DATABASE_WEEKLY", &s.Schedule.DatabaseWeekly},
+		{"hooks.timeout", "CLIBOARD_HOOK_TIMEOUT", &s.Hooks.Timeout},
+		{"update.url", "CLIBOARD_UPDATE_URL", &s.Update.URL},
+		{"update.channel", "CLIBOARD_UPDATE_CHANNEL", &s.Update.Channel},
+		{"update.public_key", "CLIBOARD_UPDATE_PUBLIC_KEY", &s.Update.PublicKey},
+		{"acme_email", "CLIBOARD_ACME_EMAIL", &s.ACMEEmail},
+	}
+}
+
+// Load reads the config file, applies environment and flag overrides in
+// that order and sets the package paths. A missing default config file is
+// not an error.
+func Load(opts Options) error {
+	root := opts.Root
+	if root == "" {
+		root = os.Getenv("CLIBOARD_ROOT")
+	}
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return fmt.Errorf("invalid root %s: %v", root, err)
+		}
+		root = abs
+	}
+
+	file := opts.File
+	explicit := file != ""
+	if file == "" {
+		file = os.Getenv("CLIBOARD_CONFIG")
+		explicit = file != ""
+	}
+	if file == "" {
+		file = rooted(root, DefaultConfigFile)
+	}
+
+	s := Defaults()
+
+	data, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %v", file, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+	default:
+		return fmt.Errorf("failed to read config file %s: %v", file, err)
+	}
+
+	settings := s.settings()
+
+	for _, st := range settings {
+		if value, ok := os.LookupEnv(st.env); ok {
+			*st.value = value
+		}
+	}
+
+	for _, kv := range opts.Set {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q, expected key=value", kv)
+		}
+		found := false
+		for _, st := range settings {
+			if st.key == key {
+				*st.value = value
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown setting %s", key)
+		}
+	}
+
+	// Caddy subdirectories follow the Caddy root unless set explicitly
+	if s.Paths.CaddyModules == "" {
+		s.Paths.CaddyModules = filepath.Join(s.Paths.CaddyRoot, "modules.d")
+	}
+	if s.Paths.CaddyPHP == "" {
+		s.Paths.CaddyPHP = filepath.Join(s.Paths.CaddyRoot, "php.d")
+	}
+	if s.Paths.CaddySites == "" {
+		s.Paths.CaddySites = filepath.Join(s.Paths.CaddyRoot, "sites.d")
+	}
+
+	apply(s, root)
+	return nil
+}
+
+// Current returns the settings in effect, without the root prefix
+func Current() Settings {
+	return current
+}
+
+// Values returns every setting as key and value, sorted by key
+func (s Settings) Values() [][2]string {
+	var values [][2]string
+	for _, st := range s.settings() {
+		values = append(values, [2]string{st.key, *st.value})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i][0] < values[j][0] })
+	return values
+}
+
+// Environment returns every setting as an environment variable assignment,
+// so a cliboard started with it uses the same settings
+func (s Settings) Environment() []string {
+	var env []string
+	for _, st := range s.settings() {
+		env = append(env, st.env+"="+*st.value)
+	}
+	return env
+}
+
+// apply sets the package variables from s, relocated under root
+func apply(s Settings, root string) {
+	current = s
+	Root = root
+
+	SitesRootDir = rooted(root, s.Paths.SitesRoot)
+	CaddyRootDir = rooted(root, s.Paths.CaddyRoot)
+	CaddyModulesDir = rooted(root, s.Paths.CaddyModules)
+	CaddyPHPDir = rooted(root, s.Paths.CaddyPHP)
+	CaddySitesDir = rooted(root, s.Paths.CaddySites)
+	CaddyLogDir = rooted(root, s.Paths.CaddyLogs)
+	CaddyAdminSocket = rooted(root, s.Paths.CaddyAdminSocket)
+	CaddyDataDir = rooted(root, s.Paths.CaddyData)
+	ConfigDir = rooted(root, s.Paths.ConfigDir)
+	StateDir = rooted(root, s.Paths.StateDir)
+	RunDir = rooted(root, s.Paths.RunDir)
+	LogDir = rooted(root, s.Paths.LogDir)
+	BackupDailyDir = rooted(root, s.Paths.BackupDaily)
+	BackupWeeklyDir = rooted(root, s.Paths.BackupWeekly)
+	CronDir = rooted(root, s.Paths.CronDir)
+	BinDir = rooted(root, s.Paths.BinDir)
+
+	SiteDailySchedule = s.Schedule.SiteDaily
+	SiteWeeklySchedule = s.Schedule.SiteWeekly
+	DatabaseDailySchedule = s.Schedule.DatabaseDaily
+	DatabaseWeeklySchedule = s.Schedule.DatabaseWeekly
+	HookTimeout = s.Hooks.Timeout
+	UpdateURL = s.Update.URL
+	UpdateChannel = s.Update.Channel
+	UpdatePublicKey = s.Update.PublicKey
+	ACMEEmail = s.ACMEEmail
+}
+
+// rooted returns path relocated under root
+func rooted(root, path string) string {
+	if root == "" {
+		return path
+	}
+	return filepath.Join(root, path)
+}
@@ -1,18 +1,45 @@
 package config
 
-const (
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Paths are set by Load from the defaults, the config file and any
+// overrides, and carry the --root prefix when one is given.
+var (
 	// Site directories
 	SitesRootDir = "/apps/sites"
-	
+
 	// Caddy configuration directories
 	CaddyRootDir    = "/etc/caddy"
 	CaddyModulesDir = "/etc/caddy/modules.d"
 	CaddyPHPDir     = "/etc/caddy/php.d"
 	CaddySitesDir   = "/etc/caddy/sites.d"
-	
+	CaddyLogDir     = "/var/log/caddy"
+
+	// Caddy admin API socket
+	CaddyAdminSocket = "/var/lib/caddy/admin.sock"
+
+	// Caddy data directory holding certificates
+	CaddyDataDir = "/var/lib/caddy/.local/share/caddy"
+
+	// Configuration, state, runtime files and logs of cliboard itself
+	ConfigDir = "/etc/cliboard"
+	StateDir  = "/var/lib/cliboard"
+	RunDir    = "/run/cliboard"
+	LogDir    = "/var/log/cliboard"
+
 	// Backup directories
 	BackupDailyDir  = "/backup/daily"
 	BackupWeeklyDir = "/backup/weekly"
+
+	// System directories cliboard installs cron jobs and scripts into
+	CronDir = "/etc/cron.d"
+	BinDir  = "/usr/local/bin"
+
+	// Root is the prefix all filesystem paths are relocated under
+	Root = ""
 )
 
 // GetSiteDirectory returns the full directory path for a site
@@ -25,6 +52,13 @@ func GetSiteConfigPath(domain string) string {
 	return CaddySitesDir + "/" + domain + ".caddy"
 }
 
+// GetSuspendedSiteConfigPath returns where the Caddy configuration of a
+// suspended site is kept. The main Caddyfile doesn't import it, so the site
+// is out of service until it is moved back to sites.d.
+func GetSuspendedSiteConfigPath(domain string) string {
+	return CaddyRootDir + "/suspended/" + domain + ".caddy"
+}
+
 // GetPHPConfigPath returns the PHP configuration file path for a specific PHP version
 func GetPHPConfigPath(version string) string {
 	return CaddyPHPDir + "/php" + version + "_config"
@@ -44,3 +78,31 @@ func GetBackupDailyPath(domain string) string {
 func GetBackupWeeklyPath(domain string) string {
 	return BackupWeeklyDir + "/" + domain
 }
+
+// GetCronPath returns the path of a cron file installed by cliboard
+func GetCronPath(name string) string {
+	return CronDir + "/" + name
+}
+
+// GetBinaryPath returns the path of the installed cliboard binary, for use
+// in cron jobs and scripts
+func GetBinaryPath() string {
+	return Target(BinDir + "/cliboard")
+}
+
+// Target returns path as seen on the managed system, without the --root
+// prefix. Use it for paths written into configuration files, scripts and
+// cron jobs.
+func Target(path string) string {
+	if Root == "" {
+		return path
+	}
+	rel, err := filepath.Rel(Root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	if rel == "." {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(rel)
+}
@@ -0,0 +1,161 @@
+// Package database creates MariaDB/MySQL databases for sites. It connects as
+// root over the local socket, with the password the database backup job
+// uses, if any.
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// passwordFile holds the password of the database root user
+const passwordFile = "/root/.mysql_password"
+
+// MySQL limits database names to 64 and user names to 32 characters
+const (
+	maxNameLength = 64
+	maxUserLength = 32
+)
+
+var unsafeChars = regexp.MustCompile(`[^a-z0-9_]`)
+
+// Credentials are what a site connects to its database with
+type Credentials struct {
+	Name     string `json:"name"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Host     string `json:"host"`
+}
+
+// Installed reports whether a MariaDB or MySQL client is available
+func Installed() bool {
+	_, err := client()
+	return err == nil
+}
+
+// NameFor returns the database and user name for a site: the domain with
+// every character other than letters and digits replaced by underscores
+func NameFor(domain string) string {
+	name := unsafeChars.ReplaceAllString(strings.ToLower(domain), "_")
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
+
+// Create creates the database of a site and a user that may only use it,
+// and saves the credentials to the configuration directory
+func Create(domain string) (Credentials, error) {
+	name := NameFor(domain)
+	user := name
+	if len(user) > maxUserLength {
+		user = user[:maxUserLength]
+	}
+
+	password, err := randomPassword()
+	if err != nil {
+		return Credentials{}, err
+	}
+	c := Credentials{Name: name, User: user, Password: password, Host: "localhost"}
+
+	// The password is passed on stdin, not on the command line
+	err = run(fmt.Sprintf("CREATE DATABASE `%s` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;\n"+
+		"CREATE USER '%s'@'localhost' IDENTIFIED BY '%s';\n"+
+		"GRANT ALL PRIVILEGES ON `%s`.* TO '%s'@'localhost';\n",
+		c.Name, c.User, c.Password, c.Name, c.User))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to create database %s: %v", c.Name, err)
+	}
+
+	if err := save(domain, c); err != nil {
+		Drop(domain, c)
+		return Credentials{}, err
+	}
+	return c, nil
+}
+
+// Drop deletes a database created by Create, its user and the saved
+// credentials
+func Drop(domain string, c Credentials) error {
+	err := run(fmt.Sprintf("DROP USER IF EXISTS '%s'@'localhost';\nDROP DATABASE IF EXISTS `%s`;\n", c.User, c.Name))
+	if err != nil {
+		return fmt.Errorf("failed to drop database %s: %v", c.Name, err)
+	}
+	if err := os.Remove(CredentialsPath(domain)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CredentialsPath is where the credentials of a site's database are kept,
+// outside the site directory so they are never served
+func CredentialsPath(domain string) string {
+	return filepath.Join(config.ConfigDir, "databases", domain+".env")
+}
+
+// save writes credentials in the format of a .env file, readable by root only
+func save(domain string, c Credentials) error {
+	path := CredentialsPath(domain)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	content := fmt.Sprintf("DB_HOST=%s\nDB_DATABASE=%s\nDB_USERNAME=%s\nDB_PASSWORD=%s\n", c.Host, c.Name, c.User, c.Password)
+	if err := utils.WriteFileAtomic(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to save database credentials: %v", err)
+	}
+	return nil
+}
+
+// run passes SQL statements to the database client as root
+func run(sql string) error {
+	name, err := client()
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(name, "--user=root")
+	if password, err := os.ReadFile(filepath.Join(config.Root, passwordFile)); err == nil {
+		cmd.Env = append(os.Environ(), "MYSQL_PWD="+strings.TrimSpace(string(password)))
+	}
+	cmd.Stdin = strings.NewReader(sql)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(output.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// client finds the MariaDB or MySQL command line client
+func client() (string, error) {
+	for _, name := range []string{"mariadb", "mysql"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("MariaDB/MySQL is not installed")
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,289 @@
+package doctor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/service"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+const backupCronPrefix = "cliboard-backup-"
+
+func checkCaddyBinary() []Finding {
+	if _, err := exec.LookPath("caddy"); err != nil {
+		return []Finding{finding(Error, "", "Caddy is not installed")}
+	}
+	return nil
+}
+
+func checkCaddyService() []Finding {
+	// A relocated tree is not served by the host's Caddy
+	if config.Root != "" {
+		return nil
+	}
+
+	services := service.Detect()
+	if services.IsActive("caddy") {
+		return nil
+	}
+
+	f := finding(Error, "", "Caddy service is not running (%s)", services.Name())
+	return []Finding{fixable(f, func() error { return services.Start("caddy") })}
+}
+
+func checkCaddyValidate() []Finding {
+	if err := caddy.Validate(); err != nil {
+		return []Finding{finding(Error, filepath.Join(config.CaddyRootDir, "Caddyfile"), "%v", err)}
+	}
+	return nil
+}
+
+func checkSiteDrift() []Finding {
+	var findings []Finding
+
+	configured := map[string]bool{}
+	for _, domain := range siteConfigs() {
+		configured[domain] = true
+
+		siteDir := config.GetSiteDirectory(domain)
+		if utils.DirectoryExists(siteDir) {
+			continue
+		}
+		f := finding(Warning, siteDir, "site %s has a Caddy configuration but no site directory", domain)
+		findings = append(findings, fixable(f, func() error { return os.MkdirAll(siteDir, 0755) }))
+	}
+
+	entries, _ := os.ReadDir(config.SitesRootDir)
+	for _, entry := range entries {
+		if entry.IsDir() && !configured[entry.Name()] {
+			findings = append(findings, finding(Warning, filepath.Join(config.SitesRootDir, entry.Name()),
+				"site directory %s has no Caddy configuration", entry.Name()))
+		}
+	}
+
+	return findings
+}
+
+func checkPHPFPM() []Finding {
+	var findings []Finding
+
+	for _, path := range listFiles(config.CaddyPHPDir) {
+		f, err := caddyfile.ParseFile(path)
+		if err != nil {
+			findings = append(findings, finding(Error, path, "cannot parse PHP configuration: %v", err))
+			continue
+		}
+
+		for _, snippet := range f.Snippets() {
+			version := strings.TrimSuffix(strings.TrimPrefix(snippet.SnippetName(), "php"), "_config")
+
+			for _, d := range snippet.Find("php_fastcgi") {
+				if len(d.Args) == 0 || !strings.HasPrefix(d.Args[0], "unix/") {
+					continue
+				}
+
+				socket := filepath.Join(config.Root, strings.TrimPrefix(d.Args[0], "unix/"))
+				if isSocket(socket) {
+					continue
+				}
+
+				fpm := php.Names(version).Service
+				f := finding(Error, socket, "PHP-FPM socket for PHP %s does not exist, is %s running?", version, fpm)
+				if config.Root == "" {
+					f = fixable(f, func() error { return service.Detect().Start(fpm) })
+				}
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	return findings
+}
+
+func checkImports() []Finding {
+	var findings []Finding
+
+	// Collect every snippet the main Caddyfile makes available
+	snippets := map[string]bool{}
+	files := append(listFiles(config.CaddyModulesDir), listFiles(config.CaddyPHPDir)...)
+	files = append(files, filepath.Join(config.CaddyRootDir, "Caddyfile"))
+	for _, path := range files {
+		f, err := caddyfile.ParseFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				findings = append(findings, finding(Error, path, "cannot parse: %v", err))
+			}
+			continue
+		}
+		for _, s := range f.Snippets() {
+			snippets[s.SnippetName()] = true
+		}
+	}
+
+	for _, domain := range siteConfigs() {
+		path := config.GetSiteConfigPath(domain)
+		f, err := caddyfile.ParseFile(path)
+		if err != nil {
+			findings = append(findings, finding(Error, path, "cannot parse site configuration: %v", err))
+			continue
+		}
+
+		for _, site := range f.Sites() {
+			for _, name := range site.Imports() {
+				if snippets[name] || importsFile(name) {
+					continue
+				}
+				findings = append(findings, finding(Error, path, "site %s imports %s, which is neither a snippet nor a file", domain, name))
+			}
+		}
+	}
+
+	return findings
+}
+
+func checkBackupCron() []Finding {
+	var findings []Finding
+
+	entries, _ := os.ReadDir(config.CronDir)
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), backupCronPrefix) {
+			continue
+		}
+
+		domain := strings.TrimPrefix(entry.Name(), backupCronPrefix)
+		if utils.DirectoryExists(config.GetSiteDirectory(domain)) {
+			continue
+		}
+
+		cronFile := filepath.Join(config.CronDir, entry.Name())
+		f := finding(Warning, cronFile, "backup cron job for %s points at a site that does not exist", domain)
+		findings = append(findings, fixable(f, func() error { return os.Remove(cronFile) }))
+	}
+
+	return findings
+}
+
+func checkPermissions() []Finding {
+	var findings []Finding
+
+	// Nothing under the Caddy configuration may be world-writable
+	filepath.Walk(config.CaddyRootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		if info.Mode().Perm()&0002 != 0 {
+			mode := info.Mode().Perm() &^ 0002
+			f := finding(Error, path, "Caddy configuration is world-writable")
+			findings = append(findings, fixable(f, func() error { return os.Chmod(path, mode) }))
+		}
+		return nil
+	})
+
+	// cron ignores files that are writable by group or others or not owned by root
+	entries, _ := os.ReadDir(config.CronDir)
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "cliboard-") {
+			continue
+		}
+		path := filepath.Join(config.CronDir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Mode().Perm()&0022 != 0 || !ownedByRoot(info) {
+			f := finding(Error, path, "cron file must be owned by root and not group or world-writable, cron ignores it")
+			findings = append(findings, fixable(f, func() error {
+				if err := os.Chown(path, 0, 0); err != nil {
+					return err
+				}
+				return os.Chmod(path, 0644)
+			}))
+		}
+	}
+
+	// The database backup script must be executable
+	script := filepath.Join(config.BinDir, "cliboard-db-backup")
+	if info, err := os.Stat(script); err == nil && info.Mode().Perm()&0100 == 0 {
+		f := finding(Error, script, "database backup script is not executable")
+		findings = append(findings, fixable(f, func() error { return os.Chmod(script, 0755) }))
+	}
+
+	// The admin socket gives full control over Caddy
+	if info, err := os.Stat(config.CaddyAdminSocket); err == nil && info.Mode().Perm()&0077 != 0 {
+		findings = append(findings, finding(Warning, config.CaddyAdminSocket,
+			"Caddy admin socket is accessible to other users (mode %o)", info.Mode().Perm()))
+	}
+
+	// Site directories must not be world-writable
+	sites, _ := os.ReadDir(config.SitesRootDir)
+	for _, entry := range sites {
+		path := filepath.Join(config.SitesRootDir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() || info.Mode().Perm()&0002 == 0 {
+			continue
+		}
+		mode := info.Mode().Perm() &^ 0002
+		f := finding(Warning, path, "site directory is world-writable")
+		findings = append(findings, fixable(f, func() error { return os.Chmod(path, mode) }))
+	}
+
+	return findings
+}
+
+// siteConfigs returns the domains that have a file in sites.d
+func siteConfigs() []string {
+	var domains []string
+	for _, path := range listFiles(config.CaddySitesDir) {
+		if name := filepath.Base(path); strings.HasSuffix(name, ".caddy") {
+			domains = append(domains, strings.TrimSuffix(name, ".caddy"))
+		}
+	}
+	return domains
+}
+
+// listFiles returns the regular files in dir
+func listFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files
+}
+
+// importsFile reports whether an import refers to existing files
+func importsFile(pattern string) bool {
+	target := pattern
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(config.CaddyRootDir, target)
+	} else {
+		target = filepath.Join(config.Root, target)
+	}
+
+	matches, err := filepath.Glob(target)
+	return err == nil && len(matches) > 0
+}
+
+func isSocket(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+func ownedByRoot(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return !ok || stat.Uid == 0
+}
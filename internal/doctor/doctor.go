@@ -0,0 +1,123 @@
+// Package doctor checks a cliboard installation for broken or drifting
+// configuration and repairs what can be repaired safely.
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Severity ranks how serious a finding is
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// MarshalJSON encodes the severity by name
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Finding is a single problem found by a check
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Path     string   `json:"path,omitempty"`
+	Fixable  bool     `json:"fixable"`
+	Fixed    bool     `json:"fixed"`
+	FixError string   `json:"fix_error,omitempty"`
+
+	fix func() error
+}
+
+// Report is the result of running all checks
+type Report struct {
+	Findings []Finding      `json:"findings"`
+	Counts   map[string]int `json:"counts"`
+}
+
+// Problems returns the number of errors that were not fixed
+func (r Report) Problems() int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == Error && !f.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+// check inspects one aspect of the installation
+type check struct {
+	name string
+	run  func() []Finding
+}
+
+// checks lists every check in the order they run
+var checks = []check{
+	{"caddy-binary", checkCaddyBinary},
+	{"caddy-service", checkCaddyService},
+	{"caddy-validate", checkCaddyValidate},
+	{"site-drift", checkSiteDrift},
+	{"php-fpm", checkPHPFPM},
+	{"imports", checkImports},
+	{"backup-cron", checkBackupCron},
+	{"permissions", checkPermissions},
+}
+
+// Run runs every check. With fix set, fixable findings are repaired.
+func Run(fix bool) Report {
+	var report Report
+
+	for _, c := range checks {
+		for _, f := range c.run() {
+			f.Check = c.name
+			if fix && f.Fixable && f.fix != nil {
+				if err := f.fix(); err != nil {
+					f.FixError = err.Error()
+				} else {
+					f.Fixed = true
+				}
+			}
+			report.Findings = append(report.Findings, f)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Severity > report.Findings[j].Severity
+	})
+
+	report.Counts = map[string]int{}
+	for _, f := range report.Findings {
+		report.Counts[f.Severity.String()]++
+	}
+
+	return report
+}
+
+func finding(severity Severity, path, format string, args ...interface{}) Finding {
+	return Finding{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// fixable returns f with a repair attached
+func fixable(f Finding, fix func() error) Finding {
+	f.Fixable = true
+	f.fix = fix
+	return f
+}
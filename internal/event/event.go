@@ -0,0 +1,73 @@
+// Package event lets operations in the internal packages report progress
+// without printing, so whoever drives them decides how to show it.
+package event
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	mu      sync.RWMutex
+	handler func(message string)
+)
+
+// Handle sets the function progress messages are passed to and returns a
+// function that restores the previous one
+func Handle(h func(message string)) (restore func()) {
+	mu.Lock()
+	previous := handler
+	handler = h
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		handler = previous
+		mu.Unlock()
+	}
+}
+
+// Progress reports a step of the operation in progress
+func Progress(format string, args ...interface{}) {
+	mu.RLock()
+	h := handler
+	mu.RUnlock()
+
+	if h != nil {
+		h(fmt.Sprintf(format, args...))
+	}
+}
+
+// Writer returns a writer reporting each line written to it as progress,
+// for passing the output of external commands on
+func Writer() io.WriteCloser {
+	return &lineWriter{}
+}
+
+type lineWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.buf.WriteString(line)
+			return len(p), nil
+		}
+		Progress("%s", bytes.TrimRight([]byte(line), "\r\n"))
+	}
+}
+
+// Close reports any incomplete last line
+func (w *lineWriter) Close() error {
+	if w.buf.Len() > 0 {
+		Progress("%s", w.buf.String())
+		w.buf.Reset()
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package health looks for conditions an administrator should hear about:
+// stale backups, full disks, expiring certificates and stopped PHP-FPM
+// pools. The conditions are reported through the notify package.
+package health
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/service"
+	"github.com/doko89/cliboard/internal/site"
+)
+
+// Events lists the events Check can raise
+var Events = []string{notify.BackupStale, notify.DiskFull, notify.CertificateExpiring, notify.PHPFPMDown}
+
+// Check runs every check with the thresholds of c
+func Check(c notify.Config) ([]notify.Event, error) {
+	sites, err := site.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []notify.Event
+	events = append(events, checkBackups(c, sites)...)
+	events = append(events, checkDisks(c)...)
+	events = append(events, checkPHPFPM(sites)...)
+
+	certs, err := checkCertificates(c)
+	events = append(events, certs...)
+
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	for i := range events {
+		events[i].Host, events[i].Time = host, now
+	}
+	return events, err
+}
+
+// Run checks and reports the conditions found through notify.Reconcile
+func Run() ([]notify.Event, error) {
+	c, err := notify.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := Check(c)
+	if err != nil {
+		return events, err
+	}
+	return events, notify.Reconcile(Events, events)
+}
+
+func checkBackups(c notify.Config, sites []site.Info) []notify.Event {
+	var events []notify.Event
+
+	stale := func(subject string, last time.Time, ok bool, since time.Time) {
+		// Give a newly enabled backup time for its first run
+		if !ok {
+			if time.Since(since) > c.BackupMaxAge() {
+				events = append(events, notify.Event{Name: notify.BackupStale, Subject: subject,
+					Message: fmt.Sprintf("no backup since backup was enabled %s", since.Format(time.RFC3339))})
+			}
+			return
+		}
+		if age := time.Since(last); age > c.BackupMaxAge() {
+			events = append(events, notify.Event{Name: notify.BackupStale, Subject: subject,
+				Message: fmt.Sprintf("last backup is %s old (%s)", age.Round(time.Hour), last.Format(time.RFC3339))})
+		}
+	}
+
+	for _, s := range sites {
+		if !s.Backup {
+			continue
+		}
+		since, _ := backup.SiteEnabledSince(s.Domain)
+		last, ok := backup.LastSiteBackup(s.Domain)
+		stale(s.Domain, last, ok, since)
+	}
+
+	if since, enabled := backup.DatabaseEnabledSince(); enabled {
+		last, ok := backup.LastDatabaseBackup()
+		stale("database", last, ok, since)
+	}
+
+	return events
+}
+
+// checkDisks reports file systems holding sites or backups above the
+// threshold, each file system once
+func checkDisks(c notify.Config) []notify.Event {
+	var events []notify.Event
+
+	seen := map[uint64]bool{}
+	for _, dir := range []string{config.SitesRootDir, config.BackupDailyDir, config.BackupWeeklyDir} {
+		var st syscall.Stat_t
+		if err := syscall.Stat(dir, &st); err != nil || seen[uint64(st.Dev)] {
+			continue
+		}
+		seen[uint64(st.Dev)] = true
+
+		used, ok := DiskUsage(dir)
+		if !ok || used < c.Thresholds.DiskPercent {
+			continue
+		}
+		events = append(events, notify.Event{Name: notify.DiskFull, Subject: config.Target(dir),
+			Message: fmt.Sprintf("file system of %s is %d%% full", config.Target(dir), used)})
+	}
+
+	return events
+}
+
+// DiskUsage returns how full the file system holding path is, in percent of
+// the space available to unprivileged users, as df reports it
+func DiskUsage(path string) (int, bool) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return 0, false
+	}
+
+	used := fs.Blocks - fs.Bfree
+	total := used + fs.Bavail
+	if total == 0 {
+		return 0, false
+	}
+	return int(math.Ceil(float64(used) * 100 / float64(total))), true
+}
+
+func checkCertificates(c notify.Config) ([]notify.Event, error) {
+	certs, err := caddy.Certificates()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []notify.Event
+	limit := time.Duration(c.Thresholds.CertificateDays) * 24 * time.Hour
+	for _, cert := range certs {
+		left := time.Until(cert.NotAfter)
+		if left > limit || len(cert.Names) == 0 {
+			continue
+		}
+
+		msg := fmt.Sprintf("certificate expires in %d days (%s)", int(left.Hours()/24), cert.NotAfter.Format(time.RFC3339))
+		if left <= 0 {
+			msg = fmt.Sprintf("certificate expired %s", cert.NotAfter.Format(time.RFC3339))
+		}
+		events = append(events, notify.Event{Name: notify.CertificateExpiring, Subject: cert.Names[0], Message: msg})
+	}
+	return events, nil
+}
+
+// checkPHPFPM reports PHP-FPM services used by sites that are not running
+func checkPHPFPM(sites []site.Info) []notify.Event {
+	// A relocated tree is not served by the host's services
+	if config.Root != "" {
+		return nil
+	}
+
+	users := map[string][]string{}
+	for _, s := range sites {
+		if s.PHPVersion != "" {
+			users[s.PHPVersion] = append(users[s.PHPVersion], s.Domain)
+		}
+	}
+
+	versions := make([]string, 0, len(users))
+	for version := range users {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	var events []notify.Event
+	services := service.Detect()
+	for _, version := range versions {
+		fpm := php.Names(version).Service
+		if services.IsActive(fpm) {
+			continue
+		}
+		events = append(events, notify.Event{Name: notify.PHPFPMDown, Subject: fpm,
+			Message: fmt.Sprintf("%s is not running, used by %d site(s)", fpm, len(users[version]))})
+	}
+	return events
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// DefaultSchedule runs the checks every 15 minutes
+const DefaultSchedule = "*/15 * * * *"
+
+// Schedule installs a cron job running the checks on schedule
+func Schedule(schedule string) error {
+	job := site.CronJob{Schedule: schedule, Command: config.GetBinaryPath() + " notify check --quiet"}
+	if err := job.Validate(); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("# CLIBoard health checks\n%s root %s\n", job.Schedule, job.Command)
+	if err := os.MkdirAll(config.CronDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cron directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(cronPath(), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to create health check cron job: %v", err)
+	}
+	return nil
+}
+
+// Unschedule removes the cron job running the checks
+func Unschedule() error {
+	if err := os.Remove(cronPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove health check cron job: %v", err)
+	}
+	return nil
+}
+
+// Scheduled reports whether the checks run from cron
+func Scheduled() bool {
+	_, err := os.Stat(cronPath())
+	return err == nil
+}
+
+func cronPath() string {
+	return config.GetCronPath("cliboard-health")
+}
@@ -0,0 +1,242 @@
+// Package history records every change to the Caddy configuration in a
+// local git repository, so changes can be listed, inspected and rolled back.
+//
+// The repository's git directory lives under the state directory and uses
+// the Caddy configuration directory as its work tree, so nothing is added
+// to /etc/caddy itself.
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Entry is one recorded change
+type Entry struct {
+	ID      string
+	Time    time.Time
+	User    string
+	Command string
+	Files   []string
+}
+
+// Available reports whether git is installed
+func Available() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// Record commits any pending change to the configuration with the command
+// that made it. It does nothing when the configuration is unchanged.
+func Record(command string) error {
+	if !utils.DirectoryExists(config.CaddyRootDir) {
+		return nil
+	}
+
+	// Concurrent commands would otherwise race for the git index
+	release, err := lock.Acquire(lock.History)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := ensureRepository(); err != nil {
+		return err
+	}
+
+	if _, err := git("add", "--all"); err != nil {
+		return err
+	}
+
+	// Nothing staged means nothing changed
+	if _, err := git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+
+	user := utils.InvokingUser()
+	hostname, _ := os.Hostname()
+	author := fmt.Sprintf("%s <%s@%s>", user, user, hostname)
+
+	_, err = git("-c", "user.name=cliboard", "-c", "user.email=cliboard@"+hostname,
+		"commit", "--quiet", "--no-verify", "--author", author, "--message", command)
+	return err
+}
+
+// List returns recorded changes, newest first. When path is not empty only
+// changes touching that file are returned.
+func List(limit int, path string) ([]Entry, error) {
+	if !exists() {
+		return nil, nil
+	}
+
+	args := []string{"log", "--name-only", "--format=%x1e%h%x1f%aI%x1f%an%x1f%s"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", limit))
+	}
+	if path != "" {
+		rel, err := relative(path)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--", rel)
+	}
+
+	output, err := git(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, record := range strings.Split(output, "\x1e") {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+
+		lines := strings.Split(record, "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+
+		entry := Entry{ID: fields[0], User: fields[2], Command: fields[3]}
+		entry.Time, _ = time.Parse(time.RFC3339, fields[1])
+		for _, f := range lines[1:] {
+			if f = strings.TrimSpace(f); f != "" {
+				entry.Files = append(entry.Files, f)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Show returns the summary and patch of a recorded change
+func Show(id string) (string, error) {
+	if err := requireRepository(); err != nil {
+		return "", err
+	}
+	return git("show", "--stat", "--patch", "--format=fuller", id)
+}
+
+// Diff returns the changes between a recorded change and the current
+// configuration, or between two recorded changes
+func Diff(from, to string) (string, error) {
+	if err := requireRepository(); err != nil {
+		return "", err
+	}
+	if to == "" {
+		if _, err := git("add", "--all"); err != nil {
+			return "", err
+		}
+		return git("diff", "--cached", from)
+	}
+	return git("diff", from, to)
+}
+
+// Rollback restores the configuration to the state of a recorded change.
+// When path is not empty only that file is restored; it is removed if it
+// did not exist at that point.
+func Rollback(id, path string) error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+
+	if _, err := git("rev-parse", "--verify", "--quiet", id+"^{commit}"); err != nil {
+		return fmt.Errorf("unknown history entry %s", id)
+	}
+
+	pathspec := "."
+	if path != "" {
+		rel, err := relative(path)
+		if err != nil {
+			return err
+		}
+		pathspec = rel
+
+		// Files created after the entry are removed rather than restored
+		if _, err := git("cat-file", "-e", id+":"+rel); err != nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %v", path, err)
+			}
+			return nil
+		}
+	}
+
+	_, err := git("restore", "--source", id, "--staged", "--worktree", "--", pathspec)
+	return err
+}
+
+// gitDir returns the git directory of the history repository
+func gitDir() string {
+	return filepath.Join(config.StateDir, "caddy-history.git")
+}
+
+func exists() bool {
+	return utils.DirectoryExists(gitDir())
+}
+
+func requireRepository() error {
+	if !Available() {
+		return fmt.Errorf("git is not installed")
+	}
+	if !exists() {
+		return fmt.Errorf("no configuration history recorded yet")
+	}
+	return nil
+}
+
+// ensureRepository creates the history repository if needed
+func ensureRepository() error {
+	if !Available() {
+		return fmt.Errorf("git is not installed")
+	}
+	if exists() {
+		return nil
+	}
+
+	if err := os.MkdirAll(config.StateDir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	if _, err := git("init", "--quiet"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// relative returns path relative to the Caddy configuration directory
+func relative(path string) (string, error) {
+	rel, err := filepath.Rel(config.CaddyRootDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside %s and is not tracked", path, config.CaddyRootDir)
+	}
+	return rel, nil
+}
+
+// git runs git against the history repository and returns its output
+func git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	base := []string{"--git-dir", gitDir(), "--work-tree", config.CaddyRootDir}
+	cmd := exec.Command("git", append(base, args...)...)
+	cmd.Dir = config.CaddyRootDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %v: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,227 @@
+// Package hook runs administrator-provided executables before and after
+// operations, e.g. to register DNS when a site is created. The hooks of
+// pre-create-site live in hooks/pre-create-site.d/ in the configuration
+// directory, and hooks of a single site in hooks/sites/<domain>/.
+//
+// Each hook gets the event as JSON on stdin. A pre- hook that exits with a
+// non-zero status vetoes the operation; failures of post- hooks are logged
+// and reported but do not undo the operation.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+)
+
+// Operations hooks run around. Each has a pre- and a post- hook, e.g.
+// pre-create-site and post-create-site.
+const (
+	CreateSite   = "create-site"
+	DeleteSite   = "delete-site"
+	PHPChange    = "php-change"
+	AddModule    = "add-module"
+	RemoveModule = "remove-module"
+	Backup       = "backup"
+	// Deploy hooks are run by deploy tooling with cliboard hooks run
+	Deploy = "deploy"
+)
+
+// Operations lists every operation with hooks
+var Operations = []string{CreateSite, DeleteSite, PHPChange, AddModule, RemoveModule, Backup, Deploy}
+
+// Event is passed to a hook on stdin
+type Event struct {
+	Hook string `json:"hook"`
+	Site string `json:"site,omitempty"`
+	// Data holds details of the operation, e.g. the PHP version
+	Data map[string]string `json:"data,omitempty"`
+	Time time.Time         `json:"time"`
+}
+
+// Names returns every hook name, pre- and post- for each operation
+func Names() []string {
+	var names []string
+	for _, op := range Operations {
+		names = append(names, "pre-"+op, "post-"+op)
+	}
+	return names
+}
+
+// Valid reports whether name is a hook name
+func Valid(name string) bool {
+	for _, n := range Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Around runs the pre- hooks of an operation, then op unless a hook vetoed
+// it, then the post- hooks if op succeeded. It must be called without locks
+// held, as hooks may run cliboard themselves.
+func Around(operation, site string, data map[string]string, op func() error) error {
+	if err := Run("pre-"+operation, site, data); err != nil {
+		return err
+	}
+	if err := op(); err != nil {
+		return err
+	}
+	if err := Run("post-"+operation, site, data); err != nil {
+		event.Progress("Warning: %v", err)
+	}
+	return nil
+}
+
+// Run runs the hooks of name, global hooks first and then those of the
+// site, each in the order of their file names. It stops at the first hook
+// that fails.
+func Run(name, site string, data map[string]string) error {
+	if !Valid(name) {
+		return fmt.Errorf("unknown hook %s", name)
+	}
+
+	paths := List(name, site)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(config.HookTimeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid hook timeout %q", config.HookTimeout)
+	}
+
+	input, err := json.Marshal(Event{Hook: name, Site: site, Data: data, Time: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := runOne(path, name, site, input, timeout); err != nil {
+			if strings.HasPrefix(name, "pre-") {
+				return fmt.Errorf("%s hook %s refused: %v", name, filepath.Base(path), err)
+			}
+			return fmt.Errorf("%s hook %s failed: %v", name, filepath.Base(path), err)
+		}
+	}
+	return nil
+}
+
+// List returns the executables run for a hook, global ones first
+func List(name, site string) []string {
+	dirs := []string{filepath.Join(Dir(), name+".d")}
+	if site != "" {
+		dirs = append(dirs, filepath.Join(Dir(), "sites", site, name+".d"))
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		var names []string
+		for _, entry := range entries {
+			// Skip hidden files and editor backups like run-parts does
+			n := entry.Name()
+			if strings.HasPrefix(n, ".") || strings.HasSuffix(n, "~") {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(dir, n))
+			if err != nil || !info.Mode().IsRegular() || info.Mode()&0111 == 0 {
+				continue
+			}
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			paths = append(paths, filepath.Join(dir, n))
+		}
+	}
+	return paths
+}
+
+// Dir returns the directory holding the hook directories
+func Dir() string {
+	return filepath.Join(config.ConfigDir, "hooks")
+}
+
+// runOne runs a single hook, logging its output. A hook that outlives the
+// timeout is killed along with its children.
+func runOne(path, name, site string, input []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = &output, &output
+	self, _ := os.Executable()
+	cmd.Env = append(os.Environ(), "CLIBOARD_HOOK="+name, "CLIBOARD_SITE="+site, "CLIBOARD_BIN="+self, "CLIBOARD_ROOT="+config.Root)
+	cmd.Env = append(cmd.Env, config.Current().Environment()...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	logRun(path, name, site, output.Bytes(), time.Since(start), err)
+
+	if err != nil {
+		if last := lastLine(output.String()); last != "" {
+			return fmt.Errorf("%v: %s", err, last)
+		}
+		return err
+	}
+	return nil
+}
+
+// logRun appends the outcome and output of a hook to hooks.log
+func logRun(path, name, site string, output []byte, took time.Duration, runErr error) {
+	if err := os.MkdirAll(config.LogDir, 0750); err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(config.LogDir, "hooks.log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	result := "ok"
+	if runErr != nil {
+		result = runErr.Error()
+	}
+	prefix := fmt.Sprintf("%s %s %s", time.Now().UTC().Format(time.RFC3339), name, filepath.Base(path))
+	if site != "" {
+		prefix += " " + site
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(&b, "%s: %s\n", prefix, line)
+		}
+	}
+	fmt.Fprintf(&b, "%s: %s (%s)\n", prefix, result, took.Round(time.Millisecond))
+	file.WriteString(b.String())
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,225 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+)
+
+// tempHooks points the configuration and log directories at temporary ones
+// and returns a directory for the hooks to write to
+func tempHooks(t *testing.T) string {
+	t.Helper()
+	configDir, logDir, timeout := config.ConfigDir, config.LogDir, config.HookTimeout
+	t.Cleanup(func() { config.ConfigDir, config.LogDir, config.HookTimeout = configDir, logDir, timeout })
+
+	config.ConfigDir, config.LogDir, config.HookTimeout = t.TempDir(), t.TempDir(), "10s"
+	return t.TempDir()
+}
+
+// writeHook writes an executable shell script to a hook directory below
+// the hooks directory
+func writeHook(t *testing.T, dir, name, script string, mode os.FileMode) {
+	t.Helper()
+	dir = filepath.Join(Dir(), dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+func TestList(t *testing.T) {
+	tempHooks(t)
+	writeHook(t, "pre-create-site.d", "20-second", "", 0755)
+	writeHook(t, "pre-create-site.d", "10-first", "", 0755)
+	writeHook(t, "pre-create-site.d", ".hidden", "", 0755)
+	writeHook(t, "pre-create-site.d", "10-first~", "", 0755)
+	writeHook(t, "pre-create-site.d", "30-not-executable", "", 0644)
+	if err := os.MkdirAll(filepath.Join(Dir(), "pre-create-site.d", "40-directory"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeHook(t, "sites/example.com/pre-create-site.d", "00-site", "", 0755)
+	writeHook(t, "sites/other.com/pre-create-site.d", "00-other", "", 0755)
+
+	var got []string
+	for _, path := range List("pre-create-site", "example.com") {
+		rel, _ := filepath.Rel(Dir(), path)
+		got = append(got, rel)
+	}
+	want := []string{
+		"pre-create-site.d/10-first",
+		"pre-create-site.d/20-second",
+		"sites/example.com/pre-create-site.d/00-site",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("List = %q, want %q", got, want)
+	}
+
+	if got := List("pre-create-site", ""); len(got) != 2 {
+		t.Errorf("without a site List = %q, want the global hooks", got)
+	}
+}
+
+func TestRunInput(t *testing.T) {
+	out := tempHooks(t)
+	order := filepath.Join(out, "order")
+	writeHook(t, "post-php-change.d", "10-global", `cat > `+filepath.Join(out, "stdin")+`; echo "global:$CLIBOARD_HOOK:$CLIBOARD_SITE" >> `+order, 0755)
+	writeHook(t, "sites/example.com/post-php-change.d", "10-site", `echo site >> `+order, 0755)
+
+	before := time.Now().UTC().Add(-time.Second)
+	if err := Run("post-php-change", "example.com", map[string]string{"version": "8.2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readLines(t, order); strings.Join(got, " ") != "global:post-php-change:example.com site" {
+		t.Errorf("hooks ran as %q", got)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatalf("stdin is not an event: %v: %s", err, data)
+	}
+	if e.Hook != "post-php-change" || e.Site != "example.com" || e.Data["version"] != "8.2" || e.Time.Before(before) {
+		t.Errorf("event %+v", e)
+	}
+
+	if _, err := os.Stat(filepath.Join(config.LogDir, "hooks.log")); err != nil {
+		t.Errorf("runs not logged: %v", err)
+	}
+}
+
+func TestRunUnknown(t *testing.T) {
+	tempHooks(t)
+	if err := Run("pre-nothing", "", nil); err == nil {
+		t.Error("unknown hook accepted")
+	}
+}
+
+func TestAroundVeto(t *testing.T) {
+	out := tempHooks(t)
+	ran := filepath.Join(out, "ran")
+	writeHook(t, "pre-delete-site.d", "10-allow", "echo allow >> "+ran, 0755)
+	writeHook(t, "sites/example.com/pre-delete-site.d", "10-protect", "echo 'site is protected'; exit 1", 0755)
+	writeHook(t, "sites/example.com/pre-delete-site.d", "20-after", "echo after >> "+ran, 0755)
+	writeHook(t, "post-delete-site.d", "10-post", "echo post >> "+ran, 0755)
+
+	called := false
+	err := Around(DeleteSite, "example.com", nil, func() error {
+		called = true
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "pre-delete-site hook 10-protect refused") || !strings.Contains(err.Error(), "site is protected") {
+		t.Fatalf("got %v, want the veto with the hook's output", err)
+	}
+	if called {
+		t.Error("vetoed operation ran")
+	}
+	if got := readLines(t, ran); strings.Join(got, " ") != "allow" {
+		t.Errorf("hooks ran as %q, want only the one before the veto", got)
+	}
+
+	// Other sites are not affected
+	if err := Around(DeleteSite, "other.com", nil, func() error { called = true; return nil }); err != nil || !called {
+		t.Errorf("other site: %v, ran %v", err, called)
+	}
+}
+
+func TestAroundPostFailure(t *testing.T) {
+	tempHooks(t)
+	writeHook(t, "post-create-site.d", "10-dns", "echo 'dns api down' >&2; exit 2", 0755)
+
+	var warnings []string
+	restore := event.Handle(func(message string) { warnings = append(warnings, message) })
+	defer restore()
+
+	if err := Around(CreateSite, "example.com", nil, func() error { return nil }); err != nil {
+		t.Fatalf("failing post- hook failed the operation: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "post-create-site hook 10-dns failed") || !strings.Contains(warnings[0], "dns api down") {
+		t.Errorf("warnings %q", warnings)
+	}
+}
+
+func TestAroundOperationFailure(t *testing.T) {
+	out := tempHooks(t)
+	ran := filepath.Join(out, "ran")
+	writeHook(t, "post-create-site.d", "10-post", "echo post >> "+ran, 0755)
+
+	failure := errors.New("caddy validate failed")
+	if err := Around(CreateSite, "example.com", nil, func() error { return failure }); err != failure {
+		t.Errorf("got %v, want the operation's error", err)
+	}
+	if got := readLines(t, ran); got != nil {
+		t.Errorf("post- hook ran after a failed operation")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	out := tempHooks(t)
+	config.HookTimeout = "300ms"
+	pidFile := filepath.Join(out, "pid")
+	writeHook(t, "pre-backup.d", "10-slow", "sleep 30 & echo $! > "+pidFile+"; wait", 0755)
+
+	start := time.Now()
+	err := Run("pre-backup", "example.com", nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 300ms") {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("Run took %s", took)
+	}
+
+	// The hook's children are killed with it
+	lines := readLines(t, pidFile)
+	if len(lines) != 1 {
+		t.Fatalf("no pid written: %q", lines)
+	}
+	pid, _ := strconv.Atoi(lines[0])
+	deadline := time.Now().Add(2 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("child %d of the hook still runs", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// alive reports whether a process runs, counting zombies as dead
+func alive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
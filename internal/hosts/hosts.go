@@ -0,0 +1,203 @@
+// Package hosts keeps an inventory of remote servers and runs cliboard on
+// them over SSH. The system ssh client is used, so keys, agents and
+// ~/.ssh/config work as they do for an interactive login.
+package hosts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Host is a server cliboard is installed on
+type Host struct {
+	Name string `json:"name"`
+	// Address is [user@]host as given to ssh
+	Address  string `json:"address"`
+	Port     int    `json:"port,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	// Options are extra ssh -o options, e.g. UserKnownHostsFile=...
+	Options []string `json:"options,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	// Sudo runs cliboard through sudo -n when logging in as another user
+	Sudo bool `json:"sudo,omitempty"`
+	// Binary is the path of cliboard on the host, cliboard when empty
+	Binary string    `json:"binary,omitempty"`
+	Added  time.Time `json:"added"`
+}
+
+var (
+	hostNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+	addressPattern  = regexp.MustCompile(`^([a-z_][a-z0-9_.-]*@)?[a-zA-Z0-9.:_-]+$`)
+)
+
+// Hosts returns the inventory sorted by name
+func Hosts() ([]Host, error) {
+	data, err := os.ReadFile(hostsFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts: %v", err)
+	}
+
+	var hosts []Host
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", hostsFile(), err)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts, nil
+}
+
+// AddHost adds a host to the inventory
+func AddHost(h Host) error {
+	if !hostNamePattern.MatchString(h.Name) || h.Name == "all" {
+		return fmt.Errorf("invalid host name %q", h.Name)
+	}
+	if !addressPattern.MatchString(h.Address) {
+		return fmt.Errorf("invalid address %q, expected [user@]host", h.Address)
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("invalid port %d", h.Port)
+	}
+	for _, tag := range h.Tags {
+		if !hostNamePattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	for _, option := range h.Options {
+		if !strings.Contains(option, "=") || strings.HasPrefix(option, "-") {
+			return fmt.Errorf("invalid ssh option %q, expected Name=value", option)
+		}
+	}
+
+	release, err := lock.Acquire(lock.Hosts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	hosts, err := Hosts()
+	if err != nil {
+		return err
+	}
+	for _, existing := range hosts {
+		if existing.Name == h.Name {
+			return fmt.Errorf("host %s already exists", h.Name)
+		}
+	}
+
+	h.Added = time.Now().UTC()
+	return writeHosts(append(hosts, h))
+}
+
+// RemoveHost deletes a host from the inventory
+func RemoveHost(name string) error {
+	release, err := lock.Acquire(lock.Hosts)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	hosts, err := Hosts()
+	if err != nil {
+		return err
+	}
+
+	for i, h := range hosts {
+		if h.Name == name {
+			return writeHosts(append(hosts[:i], hosts[i+1:]...))
+		}
+	}
+	return fmt.Errorf("host %s does not exist", name)
+}
+
+// Select returns the hosts matching a selector: all, tag:<tag> or a comma
+// separated list of names and tag: selectors
+func Select(selector string) ([]Host, error) {
+	hosts, err := Hosts()
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts in the inventory, add one with cliboard hosts add")
+	}
+
+	var selected []Host
+	seen := map[string]bool{}
+	pick := func(h Host) {
+		if !seen[h.Name] {
+			seen[h.Name] = true
+			selected = append(selected, h)
+		}
+	}
+
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+		case part == "all":
+			for _, h := range hosts {
+				pick(h)
+			}
+		case strings.HasPrefix(part, "tag:"):
+			tag := strings.TrimPrefix(part, "tag:")
+			found := false
+			for _, h := range hosts {
+				for _, t := range h.Tags {
+					if t == tag {
+						pick(h)
+						found = true
+					}
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no host is tagged %s", tag)
+			}
+		default:
+			found := false
+			for _, h := range hosts {
+				if h.Name == part {
+					pick(h)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("host %s does not exist", part)
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no hosts selected")
+	}
+	return selected, nil
+}
+
+func writeHosts(hosts []Host) error {
+	if err := os.MkdirAll(config.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(hosts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(hostsFile(), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write hosts: %v", err)
+	}
+	return nil
+}
+
+func hostsFile() string {
+	return filepath.Join(config.ConfigDir, "hosts.json")
+}
@@ -0,0 +1,130 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sshBinary is the ssh client to run, replaced by tests
+var sshBinary = "ssh"
+
+// Result is the outcome of running cliboard on one host
+type Result struct {
+	Host     string `json:"host"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+	// Error is set when ssh itself failed, e.g. the host is unreachable
+	Error string `json:"error,omitempty"`
+}
+
+// OK reports whether the command succeeded
+func (r Result) OK() bool {
+	return r.ExitCode == 0 && r.Error == ""
+}
+
+// Command returns the ssh command running cliboard with args on the host.
+// With a terminal, ssh allocates one so prompts work.
+func (h Host) Command(ctx context.Context, args []string, terminal bool) *exec.Cmd {
+	sshArgs := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10"}
+	if terminal {
+		sshArgs = append(sshArgs, "-t")
+	} else {
+		sshArgs = append(sshArgs, "-T")
+	}
+	if h.Port != 0 {
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(h.Port))
+	}
+	if h.Identity != "" {
+		sshArgs = append(sshArgs, "-i", h.Identity, "-o", "IdentitiesOnly=yes")
+	}
+	for _, option := range h.Options {
+		sshArgs = append(sshArgs, "-o", option)
+	}
+	sshArgs = append(sshArgs, "--", h.Address, h.remoteCommand(args))
+
+	return exec.CommandContext(ctx, sshBinary, sshArgs...)
+}
+
+// remoteCommand quotes the cliboard invocation for the remote shell
+func (h Host) remoteCommand(args []string) string {
+	binary := h.Binary
+	if binary == "" {
+		binary = "cliboard"
+	}
+
+	words := []string{quote(binary)}
+	if h.Sudo {
+		words = append([]string{"sudo", "-n"}, words...)
+	}
+	for _, arg := range args {
+		words = append(words, quote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// Run runs cliboard with args on the host and captures its output. stdin
+// is passed on when it is not nil.
+func (h Host) Run(ctx context.Context, args []string, stdin io.Reader) Result {
+	var stdout, stderr bytes.Buffer
+	cmd := h.Command(ctx, args, false)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	result := Result{Host: h.Name}
+	err := cmd.Run()
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exit) && exit.ExitCode() != 255:
+		result.ExitCode = exit.ExitCode()
+	default:
+		// ssh exits with 255 when it cannot connect or authenticate
+		result.ExitCode = -1
+		result.Error = strings.TrimSpace(stderr.String())
+		if result.Error == "" {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// RunAll runs cliboard with args on every host, at most parallel at a time,
+// and returns the results in the order of hosts
+func RunAll(ctx context.Context, hosts []Host, args []string, parallel int) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]Result, len(hosts))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h Host) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = h.Run(ctx, args, nil)
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
+
+// quote quotes a word for a POSIX shell unless it is plainly safe
+func quote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@,+%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
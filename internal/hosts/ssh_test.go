@@ -0,0 +1,179 @@
+package hosts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// awkward are arguments the remote shell must pass through unchanged
+var awkward = []string{"two words", "it's", `say "hi"`, "$HOME", "a;b", "`id`", "--", "-x", "", "*", "tab\there", "new\nline"}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"list-sites":          "list-sites",
+		"--php=8.2":           "--php=8.2",
+		"user@example.com":    "user@example.com",
+		"/usr/local/bin/x":    "/usr/local/bin/x",
+		"":                    "''",
+		"two words":           "'two words'",
+		"it's":                `'it'\''s'`,
+		"$HOME":               "'$HOME'",
+		"a;b":                 "'a;b'",
+		`say "hi"`:            `'say "hi"'`,
+		"*":                   "'*'",
+		"x && rm -rf /":       "'x && rm -rf /'",
+		"`id`":                "'`id`'",
+		"'":                   `''\'''`,
+		"multi\nline":         "'multi\nline'",
+		"example.com,www.x.y": "example.com,www.x.y",
+	}
+	for in, want := range tests {
+		if got := quote(in); got != want {
+			t.Errorf("quote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	tests := []struct {
+		host Host
+		args []string
+		want string
+	}{
+		{Host{}, []string{"list-sites"}, "cliboard list-sites"},
+		{Host{Sudo: true}, []string{"list-sites"}, "sudo -n cliboard list-sites"},
+		{Host{Binary: "/opt/cli board/cliboard"}, []string{"version"}, "'/opt/cli board/cliboard' version"},
+		{Host{}, []string{"create-site", "--", "a b;c", "$x"}, "cliboard create-site -- 'a b;c' '$x'"},
+	}
+	for _, tt := range tests {
+		if got := tt.host.remoteCommand(tt.args); got != tt.want {
+			t.Errorf("remoteCommand(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	h := Host{
+		Address:  "deploy@web1.example.com",
+		Port:     2222,
+		Identity: "/root/.ssh/fleet",
+		Options:  []string{"StrictHostKeyChecking=accept-new"},
+	}
+	cmd := h.Command(context.Background(), []string{"list-sites", "--json"}, false)
+
+	want := []string{
+		"ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=10", "-T",
+		"-p", "2222",
+		"-i", "/root/.ssh/fleet", "-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=accept-new",
+		"--", "deploy@web1.example.com", "cliboard list-sites --json",
+	}
+	if strings.Join(cmd.Args, "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("ssh argv\n%q\nwant\n%q", cmd.Args, want)
+	}
+
+	if cmd := h.Command(context.Background(), nil, true); cmd.Args[5] != "-t" {
+		t.Errorf("no terminal requested: %q", cmd.Args)
+	}
+}
+
+// fakeSSH replaces ssh with a script that runs the remote command in a
+// local shell, the way sshd hands it to the login shell
+func fakeSSH(t *testing.T) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\neval \"command=\\${$#}\"\nexec sh -c \"$command\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	binary := sshBinary
+	sshBinary = script
+	t.Cleanup(func() { sshBinary = binary })
+}
+
+// checkEcho checks that printf on the other side received args unchanged
+func checkEcho(t *testing.T, r Result, args []string) {
+	t.Helper()
+	if !r.OK() {
+		t.Fatalf("run failed: exit %d, %s %s", r.ExitCode, r.Error, r.Stderr)
+	}
+	want := ""
+	for _, arg := range args {
+		want += "<" + arg + ">"
+	}
+	if r.Stdout != want {
+		t.Errorf("remote received\n%s\nwant\n%s", r.Stdout, want)
+	}
+}
+
+func TestRunQuoting(t *testing.T) {
+	fakeSSH(t)
+	h := Host{Name: "local", Address: "localhost", Binary: "printf"}
+
+	r := h.Run(context.Background(), append([]string{"<%s>"}, awkward...), nil)
+	checkEcho(t, r, awkward)
+	if r.Host != "local" {
+		t.Errorf("result for host %q", r.Host)
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	fakeSSH(t)
+	h := Host{Name: "local", Address: "localhost", Binary: "sh"}
+
+	r := h.Run(context.Background(), []string{"-c", "echo oops >&2; exit 3"}, nil)
+	if r.ExitCode != 3 || r.Error != "" || r.Stderr != "oops\n" {
+		t.Errorf("got %+v, want exit 3 with the remote stderr", r)
+	}
+
+	r = h.Run(context.Background(), []string{"-c", "echo refused >&2; exit 255"}, nil)
+	if r.ExitCode != -1 || r.Error != "refused" {
+		t.Errorf("got %+v, want an ssh failure", r)
+	}
+}
+
+func TestRunAllOrder(t *testing.T) {
+	fakeSSH(t)
+	var hosts []Host
+	for i := 0; i < 5; i++ {
+		hosts = append(hosts, Host{Name: "h" + strconv.Itoa(i), Address: "localhost", Binary: "echo"})
+	}
+
+	results := RunAll(context.Background(), hosts, []string{"a b"}, 2)
+	for i, r := range results {
+		if r.Host != hosts[i].Name || r.Stdout != "a b\n" {
+			t.Errorf("result %d = %+v", i, r)
+		}
+	}
+}
+
+// TestSSHD runs against a real sshd when CLIBOARD_TEST_SSHD is set to
+// [user@]host:port, e.g. a throwaway sshd on 127.0.0.1:2222. The key in
+// CLIBOARD_TEST_SSH_IDENTITY is used if set.
+func TestSSHD(t *testing.T) {
+	target := os.Getenv("CLIBOARD_TEST_SSHD")
+	if target == "" {
+		t.Skip("CLIBOARD_TEST_SSHD is not set")
+	}
+	i := strings.LastIndex(target, ":")
+	if i < 0 {
+		t.Fatalf("CLIBOARD_TEST_SSHD %q has no port", target)
+	}
+	port, err := strconv.Atoi(target[i+1:])
+	if err != nil {
+		t.Fatalf("CLIBOARD_TEST_SSHD %q: %v", target, err)
+	}
+
+	h := Host{
+		Name:     "sshd",
+		Address:  target[:i],
+		Port:     port,
+		Identity: os.Getenv("CLIBOARD_TEST_SSH_IDENTITY"),
+		Options:  []string{"StrictHostKeyChecking=no", "UserKnownHostsFile=/dev/null", "LogLevel=ERROR"},
+		Binary:   "printf",
+	}
+	checkEcho(t, h.Run(context.Background(), append([]string{"<%s>"}, awkward...), nil), awkward)
+}
@@ -0,0 +1,62 @@
+// Package lock serializes read-modify-write changes between cliboard
+// processes with flock(2) on files in the run directory. Each site has its
+// own lock so changes to different sites don't wait for each other; files
+// shared by all sites are guarded by named locks.
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// Lock names for files shared between sites
+const (
+	Caddyfile = "caddyfile"
+	Modules   = "caddy-modules"
+	History   = "history"
+	Plugins   = "caddy-plugins"
+	Tokens    = "api-tokens"
+	Users     = "panel-users"
+	Hosts     = "hosts"
+	Notify    = "notify-state"
+	Monitor   = "monitor-state"
+)
+
+// Acquire blocks until the named lock is held and returns a function that
+// releases it. The lock is released as well when the process exits.
+func Acquire(name string) (release func(), err error) {
+	dir := filepath.Join(config.RunDir, "locks")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %v", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock %s: %v", name, err)
+	}
+
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to acquire lock %s: %v", name, err)
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// Site locks the configuration, cron jobs and backups of one site
+func Site(domain string) (release func(), err error) {
+	return Acquire("site-" + domain)
+}
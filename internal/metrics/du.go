@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"io/fs"
+	"path/filepath"
+	"syscall"
+)
+
+// diskUsage returns the space allocated to the files under dirs, like du.
+// Files hard-linked between backups are counted once.
+func diskUsage(dirs ...string) int64 {
+	type inode struct{ dev, ino uint64 }
+	seen := map[inode]bool{}
+
+	var total int64
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			st, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				total += info.Size()
+				return nil
+			}
+			if st.Nlink > 1 {
+				key := inode{uint64(st.Dev), uint64(st.Ino)}
+				if seen[key] {
+					return nil
+				}
+				seen[key] = true
+			}
+			total += st.Blocks * 512
+			return nil
+		})
+	}
+	return total
+}
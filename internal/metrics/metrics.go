@@ -0,0 +1,303 @@
+// Package metrics exposes the state of the server in the Prometheus text
+// format: sites, disk usage, backups, PHP-FPM pools, certificates and the
+// results of the health checks and site monitoring.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/health"
+	"github.com/doko89/cliboard/internal/monitor"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// DefaultListen is the address metrics serve listens on by default, the one
+// the metrics Caddy module proxies to
+const DefaultListen = caddy.MetricsUpstream
+
+// sizeInterval is how long directory sizes are cached, as walking large
+// sites on every scrape would be slow
+const sizeInterval = 5 * time.Minute
+
+// Serve serves /metrics on a loopback address until the process is stopped
+func Serve(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %v", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("refusing to listen on %s, expose metrics through Caddy with the metrics module instead", addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", NewHandler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      time.Minute,
+	}
+	fmt.Printf("Serving metrics on http://%s/metrics\n", addr)
+	return server.ListenAndServe()
+}
+
+// Handler writes the metrics on every request
+type Handler struct {
+	sizes *sizes
+}
+
+// NewHandler returns a metrics handler
+func NewHandler() *Handler {
+	return &Handler{sizes: newSizes(sizeInterval)}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := h.Write(w); err != nil {
+		fmt.Fprintf(w, "# error: %v\n", err)
+	}
+}
+
+// Write writes every metric to out
+func (h *Handler) Write(out io.Writer) error {
+	sites, err := site.List()
+	if err != nil {
+		return err
+	}
+	w := &writer{out: out}
+	now := time.Now()
+
+	w.family("cliboard_sites", "gauge", "Number of sites.")
+	w.sample("cliboard_sites", float64(len(sites)))
+
+	w.family("cliboard_site_disk_bytes", "gauge", "Disk space used by the directory of a site.")
+	for _, s := range sites {
+		w.sample("cliboard_site_disk_bytes", float64(h.sizes.get(s.Directory)), "site", s.Domain)
+	}
+
+	w.family("cliboard_site_backup_enabled", "gauge", "Whether automatic backup is enabled for a site.")
+	for _, s := range sites {
+		w.sample("cliboard_site_backup_enabled", boolValue(s.Backup), "site", s.Domain)
+	}
+
+	w.family("cliboard_backup_age_seconds", "gauge", "Time since the last successful daily backup.")
+	for _, s := range sites {
+		if last, ok := backup.LastSiteBackup(s.Domain); ok {
+			w.sample("cliboard_backup_age_seconds", now.Sub(last).Seconds(), "site", s.Domain)
+		}
+	}
+	if last, ok := backup.LastDatabaseBackup(); ok {
+		w.sample("cliboard_backup_age_seconds", now.Sub(last).Seconds(), "site", "database")
+	}
+
+	w.family("cliboard_backup_bytes", "gauge", "Disk space used by the daily and weekly backups, hard links counted once.")
+	for _, s := range sites {
+		size := h.sizes.get(config.GetBackupDailyPath(s.Domain), config.GetBackupWeeklyPath(s.Domain))
+		if size > 0 {
+			w.sample("cliboard_backup_bytes", float64(size), "site", s.Domain)
+		}
+	}
+	if backup.DatabaseEnabled() {
+		w.sample("cliboard_backup_bytes", float64(h.sizes.get(backup.DatabaseDirs()...)), "site", "database")
+	}
+
+	writePHP(w, sites)
+
+	certs, err := caddy.Certificates()
+	if err != nil {
+		return err
+	}
+	w.family("cliboard_certificate_expiry_timestamp_seconds", "gauge", "Expiry of a certificate Caddy manages, as a Unix timestamp.")
+	for _, c := range certs {
+		for _, name := range c.Names {
+			w.sample("cliboard_certificate_expiry_timestamp_seconds", float64(c.NotAfter.Unix()), "name", name, "issuer", c.Issuer)
+		}
+	}
+
+	return writeChecks(w)
+}
+
+func writePHP(w *writer, sites []site.Info) {
+	used := map[string]bool{}
+	for _, s := range sites {
+		if s.PHPVersion != "" {
+			used[s.PHPVersion] = true
+		}
+	}
+	for _, version := range php.InstalledVersions() {
+		used[version] = true
+	}
+	versions := make([]string, 0, len(used))
+	for version := range used {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	statuses := map[string]php.PoolStatus{}
+	w.family("cliboard_phpfpm_status_up", "gauge", "Whether the status page of the PHP-FPM pool could be read.")
+	for _, version := range versions {
+		status, err := php.Status(version)
+		if err == nil {
+			statuses[version] = status
+		}
+		w.sample("cliboard_phpfpm_status_up", boolValue(err == nil), "version", version)
+	}
+
+	pools := []struct {
+		name, kind, help string
+		value            func(php.PoolStatus) int64
+	}{
+		{"cliboard_phpfpm_active_processes", "gauge", "Active PHP-FPM processes.", func(s php.PoolStatus) int64 { return s.ActiveProcesses }},
+		{"cliboard_phpfpm_idle_processes", "gauge", "Idle PHP-FPM processes.", func(s php.PoolStatus) int64 { return s.IdleProcesses }},
+		{"cliboard_phpfpm_listen_queue", "gauge", "Requests waiting for a PHP-FPM process.", func(s php.PoolStatus) int64 { return s.ListenQueue }},
+		{"cliboard_phpfpm_accepted_connections_total", "counter", "Requests accepted by the PHP-FPM pool.", func(s php.PoolStatus) int64 { return s.AcceptedConn }},
+		{"cliboard_phpfpm_max_children_reached_total", "counter", "Times the PHP-FPM pool reached its process limit.", func(s php.PoolStatus) int64 { return s.MaxChildrenReached }},
+		{"cliboard_phpfpm_slow_requests_total", "counter", "Slow requests of the PHP-FPM pool.", func(s php.PoolStatus) int64 { return s.SlowRequests }},
+	}
+	for _, p := range pools {
+		w.family(p.name, p.kind, p.help)
+		for _, version := range versions {
+			if status, ok := statuses[version]; ok {
+				w.sample(p.name, float64(p.value(status)), "version", version, "pool", status.Pool)
+			}
+		}
+	}
+}
+
+// writeChecks exposes the conditions of the health checks and the recorded
+// state of the site monitoring
+func writeChecks(w *writer) error {
+	c, err := notify.LoadConfig()
+	if err != nil {
+		return err
+	}
+	problems, err := health.Check(c)
+	if err != nil {
+		return err
+	}
+	count := map[string]int{}
+	for _, p := range problems {
+		count[p.Name]++
+	}
+	w.family("cliboard_health_problems", "gauge", "Problems found by the health checks.")
+	for _, event := range health.Events {
+		w.sample("cliboard_health_problems", float64(count[event]), "check", event)
+	}
+
+	statuses, err := monitor.Statuses()
+	if err != nil {
+		return err
+	}
+	w.family("cliboard_site_up", "gauge", "Whether a site was up at the last monitoring check.")
+	for _, s := range statuses {
+		w.sample("cliboard_site_up", boolValue(s.State == monitor.Up), "site", s.Domain)
+	}
+	w.family("cliboard_site_latency_seconds", "gauge", "Response time of a site at the last monitoring check.")
+	for _, s := range statuses {
+		w.sample("cliboard_site_latency_seconds", float64(s.LatencyMS)/1000, "site", s.Domain)
+	}
+	w.family("cliboard_site_checked_timestamp_seconds", "gauge", "Time of the last monitoring check of a site.")
+	for _, s := range statuses {
+		w.sample("cliboard_site_checked_timestamp_seconds", float64(s.Checked.Unix()), "site", s.Domain)
+	}
+	return nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writer writes the Prometheus text exposition format
+type writer struct {
+	out io.Writer
+}
+
+func (w *writer) family(name, kind, help string) {
+	fmt.Fprintf(w.out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a value with label name and value pairs
+func (w *writer) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(w.out, "%s %s\n", b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sizes caches the disk usage of directories
+type sizes struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]sizeEntry
+}
+
+type sizeEntry struct {
+	bytes int64
+	at    time.Time
+}
+
+func newSizes(ttl time.Duration) *sizes {
+	return &sizes{ttl: ttl, entries: map[string]sizeEntry{}}
+}
+
+// get returns the disk usage of dirs together
+func (s *sizes) get(dirs ...string) int64 {
+	key := strings.Join(dirs, "\x00")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && time.Since(e.at) < s.ttl {
+		return e.bytes
+	}
+
+	bytes := diskUsage(dirs...)
+	s.entries[key] = sizeEntry{bytes: bytes, at: time.Now()}
+	return bytes
+}
+
+// InstallModule writes the metrics module to modules.d, proxying to the
+// given metrics serve address, and reloads Caddy for sites importing it
+func InstallModule(upstream string) error {
+	if _, _, err := net.SplitHostPort(upstream); err != nil {
+		return fmt.Errorf("invalid upstream address %q: %v", upstream, err)
+	}
+
+	if err := os.MkdirAll(config.CaddyModulesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create modules directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(config.GetModulePath("metrics"), []byte(caddy.MetricsModule(upstream)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to create module metrics: %v", err)
+	}
+	return caddy.Reload()
+}
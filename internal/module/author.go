@@ -0,0 +1,258 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// namePattern limits module names to what is safe as both a file name in
+// modules.d and a snippet name
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Show returns the contents of a module in modules.d
+func Show(name string) ([]byte, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(config.GetModulePath(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("module %s does not exist", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module %s: %v", name, err)
+	}
+	return content, nil
+}
+
+// Check verifies that content is a single snippet block named after the
+// module, optionally preceded by comments such as its metadata
+func Check(name string, content []byte) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+
+	f, err := caddyfile.Parse(content)
+	if err != nil {
+		return fmt.Errorf("module %s does not parse: %v", name, err)
+	}
+	if len(f.Items) != 1 || !f.Items[0].HasBlock || len(f.Items[0].Args) > 0 || f.Items[0].SnippetName() == "" {
+		return fmt.Errorf("module %s must contain exactly one (%s) { ... } snippet block", name, name)
+	}
+	if got := f.Items[0].SnippetName(); got != name {
+		return fmt.Errorf("module %s defines snippet (%s), expected (%s)", name, got, name)
+	}
+	return nil
+}
+
+// Create writes a new module to modules.d
+func Create(name string, content []byte) error {
+	return save(name, content, true)
+}
+
+// Update replaces the contents of an existing module and reloads Caddy for
+// the sites importing it
+func Update(name string, content []byte) error {
+	return save(name, content, false)
+}
+
+func save(name string, content []byte, create bool) error {
+	if err := Check(name, content); err != nil {
+		return err
+	}
+
+	release, err := lock.Acquire(lock.Modules)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	path := config.GetModulePath(name)
+	old, err := os.ReadFile(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read module %s: %v", name, err)
+	}
+	if create && exists {
+		return fmt.Errorf("module %s already exists", name)
+	}
+	if !create && !exists {
+		return fmt.Errorf("module %s does not exist", name)
+	}
+
+	if err := os.MkdirAll(config.CaddyModulesDir, 0755); err != nil {
+		return fmt.Errorf("failed to create modules directory: %v", err)
+	}
+	if !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+	if err := utils.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write module %s: %v", name, err)
+	}
+
+	// Put the previous contents back if Caddy rejects the new ones
+	if err := caddy.Validate(); err != nil {
+		if exists {
+			utils.WriteFileAtomic(path, old, 0644)
+		} else {
+			os.Remove(path)
+		}
+		return err
+	}
+
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+	return nil
+}
+
+// Delete removes a module from modules.d. Modules still imported by a site
+// or another module are refused, and so is a deletion Caddy rejects.
+func Delete(name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+
+	release, err := lock.Acquire(lock.Modules)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	path := config.GetModulePath(name)
+	old, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("module %s does not exist", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read module %s: %v", name, err)
+	}
+
+	modules, err := ImportedBy(name)
+	if err != nil {
+		return err
+	}
+	if len(modules) > 0 {
+		return fmt.Errorf("module %s is imported by module %s, delete or edit those first", name, strings.Join(modules, ", "))
+	}
+	sites, err := Users(name)
+	if err != nil {
+		return err
+	}
+	if len(sites) > 0 {
+		return fmt.Errorf("module %s is still used by %s, remove it from those sites first", name, strings.Join(sites, ", "))
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete module %s: %v", name, err)
+	}
+
+	// Put the module back if Caddy needs it after all
+	if err := caddy.Validate(); err != nil {
+		utils.WriteFileAtomic(path, old, 0644)
+		return err
+	}
+
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+	return nil
+}
+
+// Users returns the sites that import a module, directly or through other
+// modules. Suspended sites count as well, as they import it again when they
+// are resumed.
+func Users(name string) ([]string, error) {
+	modules, err := ImportedBy(name)
+	if err != nil {
+		return nil, err
+	}
+	names := append([]string{name}, modules...)
+
+	var sites []string
+	for _, pattern := range []string{config.GetSiteConfigPath("*"), config.GetSuspendedSiteConfigPath("*")} {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			f, err := caddyfile.ParseFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+			}
+			domain := strings.TrimSuffix(filepath.Base(path), ".caddy")
+			if importsAny(f.Sites(), names) && !contains(sites, domain) {
+				sites = append(sites, domain)
+			}
+		}
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+// ImportedBy returns the modules that import a module, directly or through
+// other modules
+func ImportedBy(name string) ([]string, error) {
+	available, err := Available()
+	if err != nil {
+		return nil, err
+	}
+
+	imports := map[string][]*caddyfile.Directive{}
+	for _, module := range available {
+		f, err := caddyfile.ParseFile(config.GetModulePath(module))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse module %s: %v", module, err)
+		}
+		imports[module] = f.Snippets()
+	}
+
+	// Follow the imports backwards until no new module turns up
+	found := []string{name}
+	for i := 0; i < len(found); i++ {
+		for _, module := range available {
+			if !contains(found, module) && importsAny(imports[module], found[i:i+1]) {
+				found = append(found, module)
+			}
+		}
+	}
+
+	modules := found[1:]
+	sort.Strings(modules)
+	return modules, nil
+}
+
+// importsAny reports whether any of the blocks imports one of names
+func importsAny(blocks []*caddyfile.Directive, names []string) bool {
+	for _, block := range blocks {
+		for _, imported := range block.Imports() {
+			if contains(names, imported) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidName checks that a module name is safe to use as a file and snippet name
+func ValidName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid module name %q", name)
+	}
+	return nil
+}
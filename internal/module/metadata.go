@@ -0,0 +1,150 @@
+package module
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// requiresPrefix starts a metadata comment declaring a required Caddy plugin:
+//
+//	# cliboard:requires <caddy module id> [<plugin package>]
+const requiresPrefix = "# cliboard:requires"
+
+// pluginDirectives maps directives that stock Caddy lacks to the module and
+// plugin providing them, so modules written without metadata are checked too
+var pluginDirectives = map[string]Requirement{
+	"rate_limit": {Module: "http.handlers.rate_limit", Plugin: "github.com/mholt/caddy-ratelimit"},
+	"cache":      {Module: "http.handlers.cache", Plugin: "github.com/caddyserver/cache-handler"},
+	"crowdsec":   {Module: "http.handlers.crowdsec", Plugin: "github.com/hslatman/caddy-crowdsec-bouncer/http"},
+	"cgi":        {Module: "http.handlers.cgi", Plugin: "github.com/aksdb/caddy-cgi/v2"},
+	"webdav":     {Module: "http.handlers.webdav", Plugin: "github.com/mholt/caddy-webdav"},
+}
+
+// Requirement is a Caddy module a snippet needs and the plugin providing it
+type Requirement struct {
+	Module string
+	Plugin string
+}
+
+// Metadata is the cliboard metadata declared in a module's comments
+type Metadata struct {
+	Requires []Requirement
+}
+
+// ReadMetadata reads the metadata comments of a module in modules.d
+func ReadMetadata(moduleName string) (Metadata, error) {
+	var meta Metadata
+
+	file, err := os.Open(config.GetModulePath(moduleName))
+	if err != nil {
+		return meta, fmt.Errorf("failed to read module %s: %v", moduleName, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, requiresPrefix) {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, requiresPrefix))
+		if len(fields) == 0 {
+			continue
+		}
+		req := Requirement{Module: fields[0]}
+		if len(fields) > 1 {
+			req.Plugin = fields[1]
+		}
+		meta.Requires = append(meta.Requires, req)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return meta, fmt.Errorf("failed to read module %s: %v", moduleName, err)
+	}
+
+	// Add requirements implied by the directives the snippet uses
+	if f, err := caddyfile.ParseFile(config.GetModulePath(moduleName)); err == nil {
+		walkDirectives(f.Items, func(d *caddyfile.Directive) {
+			if req, ok := pluginDirectives[d.Name]; ok && !meta.requires(req.Module) {
+				meta.Requires = append(meta.Requires, req)
+			}
+		})
+	}
+
+	return meta, nil
+}
+
+func (m Metadata) requires(id string) bool {
+	for _, req := range m.Requires {
+		if req.Module == id {
+			return true
+		}
+	}
+	return false
+}
+
+func walkDirectives(directives []*caddyfile.Directive, fn func(*caddyfile.Directive)) {
+	for _, d := range directives {
+		fn(d)
+		walkDirectives(d.Block, fn)
+	}
+}
+
+// MissingPlugins returns the requirements of a module that the installed
+// Caddy does not satisfy
+func MissingPlugins(moduleName string) ([]Requirement, error) {
+	// A relocated tree is not served by the installed Caddy
+	if config.Root != "" {
+		return nil, nil
+	}
+
+	meta, err := ReadMetadata(moduleName)
+	if err != nil || len(meta.Requires) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, len(meta.Requires))
+	for i, req := range meta.Requires {
+		ids[i] = req.Module
+	}
+
+	missing, err := caddy.MissingModules(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Requirement
+	for _, req := range meta.Requires {
+		for _, id := range missing {
+			if req.Module == id {
+				result = append(result, req)
+			}
+		}
+	}
+	return result, nil
+}
+
+// missingPluginsError describes unmet requirements and how to fix them
+func missingPluginsError(moduleName string, missing []Requirement) error {
+	var ids, plugins []string
+	for _, req := range missing {
+		ids = append(ids, req.Module)
+		if req.Plugin != "" {
+			plugins = append(plugins, "--with "+req.Plugin)
+		}
+	}
+
+	msg := fmt.Sprintf("module %s needs Caddy modules this Caddy build lacks: %s", moduleName, strings.Join(ids, ", "))
+	if len(plugins) > 0 {
+		msg += fmt.Sprintf("; rebuild Caddy with: cliboard caddy build %s", strings.Join(plugins, " "))
+	}
+	return errors.New(msg)
+}
@@ -4,15 +4,31 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
 	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/doko89/cliboard/internal/lock"
 )
 
 // Add adds a module to a site configuration
 func Add(domain, moduleName string) error {
+	return hook.Around(hook.AddModule, domain, map[string]string{"module": moduleName}, func() error {
+		return add(domain, moduleName)
+	})
+}
+
+func add(domain, moduleName string) error {
+	if err := ValidName(moduleName); err != nil {
+		return err
+	}
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check if module exists
 	modulePath := config.GetModulePath(moduleName)
 	if _, err := os.Stat(modulePath); os.IsNotExist(err) {
@@ -24,27 +40,28 @@ func Add(domain, moduleName string) error {
 	if _, err := os.Stat(siteConfigPath); os.IsNotExist(err) {
 		return fmt.Errorf("site %s does not exist", domain)
 	}
+	if _, err := os.Stat(config.GetSuspendedSiteConfigPath(domain)); err == nil {
+		return fmt.Errorf("site %s is suspended, resume it first", domain)
+	}
 
-	// Read site configuration
-	siteConfig, err := os.ReadFile(siteConfigPath)
+	// Check that Caddy has the plugins the module needs
+	missing, err := MissingPlugins(moduleName)
 	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
+		return fmt.Errorf("failed to check Caddy plugins: %v", err)
 	}
-
-	// Check if module is already imported
-	if strings.Contains(string(siteConfig), fmt.Sprintf("import %s", moduleName)) {
-		return fmt.Errorf("module %s is already enabled for site %s", moduleName, domain)
+	if len(missing) > 0 {
+		return missingPluginsError(moduleName, missing)
 	}
 
 	// Add module import to site configuration
-	updatedConfig := strings.Replace(string(siteConfig),
-		fmt.Sprintf("%s {", domain),
-		fmt.Sprintf("%s {\n    import %s", domain, moduleName),
-		1)
-
-	// Write updated configuration
-	if err := os.WriteFile(siteConfigPath, []byte(updatedConfig), 0644); err != nil {
-		return fmt.Errorf("failed to update site configuration: %v", err)
+	err = caddyfile.EditSite(siteConfigPath, domain, func(site *caddyfile.Directive) error {
+		if !site.AddImport(moduleName) {
+			return fmt.Errorf("module %s is already enabled for site %s", moduleName, domain)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Reload Caddy to apply changes
@@ -52,110 +69,69 @@ func Add(domain, moduleName string) error {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
-	fmt.Printf("Module %s added to site %s successfully\n", moduleName, domain)
 	return nil
 }
 
 // Remove removes a module from a site configuration
 func Remove(domain, moduleName string) error {
-	// Check if site exists
-	siteConfigPath := config.GetSiteConfigPath(domain)
-	if _, err := os.Stat(siteConfigPath); os.IsNotExist(err) {
-		return fmt.Errorf("site %s does not exist", domain)
-	}
-
-	// Read site configuration
-	siteConfig, err := os.ReadFile(siteConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
-	}
-
-	// Check if module is imported
-	importLine := fmt.Sprintf("import %s", moduleName)
-	if !strings.Contains(string(siteConfig), importLine) {
-		return fmt.Errorf("module %s is not enabled for site %s", moduleName, domain)
-	}
-
-	// Remove module import from site configuration
-	updatedConfig := strings.Replace(string(siteConfig),
-		fmt.Sprintf("    import %s\n", moduleName),
-		"",
-		1)
+	return hook.Around(hook.RemoveModule, domain, map[string]string{"module": moduleName}, func() error {
+		return remove(domain, moduleName)
+	})
+}
 
-	// Write updated configuration
-	if err := os.WriteFile(siteConfigPath, []byte(updatedConfig), 0644); err != nil {
-		return fmt.Errorf("failed to update site configuration: %v", err)
+func remove(domain, moduleName string) error {
+	if err := ValidName(moduleName); err != nil {
+		return err
 	}
-
-	// Reload Caddy to apply changes
-	if err := caddy.Reload(); err != nil {
-		return fmt.Errorf("failed to reload Caddy: %v", err)
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	fmt.Printf("Module %s removed from site %s successfully\n", moduleName, domain)
-	return nil
-}
-
-// List lists active modules for a site
-func List(domain string) error {
 	// Check if site exists
 	siteConfigPath := config.GetSiteConfigPath(domain)
 	if _, err := os.Stat(siteConfigPath); os.IsNotExist(err) {
 		return fmt.Errorf("site %s does not exist", domain)
 	}
-
-	// Read site configuration
-	siteConfig, err := os.ReadFile(siteConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
+	if _, err := os.Stat(config.GetSuspendedSiteConfigPath(domain)); err == nil {
+		return fmt.Errorf("site %s is suspended, resume it first", domain)
 	}
 
-	// Extract module imports
-	var modules []string
-	lines := strings.Split(string(siteConfig), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "import ") {
-			moduleName := strings.TrimPrefix(line, "import ")
-			modules = append(modules, moduleName)
+	// Remove module import from site configuration
+	err = caddyfile.EditSite(siteConfigPath, domain, func(site *caddyfile.Directive) error {
+		if !site.RemoveImport(moduleName) {
+			return fmt.Errorf("module %s is not enabled for site %s", moduleName, domain)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Print modules
-	if len(modules) == 0 {
-		fmt.Printf("No active modules for site %s\n", domain)
-	} else {
-		fmt.Printf("Active modules for site %s:\n", domain)
-		for _, module := range modules {
-			fmt.Printf("- %s\n", module)
-		}
+	// Reload Caddy to apply changes
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
 	return nil
 }
 
-// ListAvailable lists all available Caddy modules
-func ListAvailable() error {
+// Available returns the names of the modules in modules.d
+func Available() ([]string, error) {
 	files, err := ioutil.ReadDir(config.CaddyModulesDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("modules directory does not exist: %s", config.CaddyModulesDir)
+			return nil, fmt.Errorf("modules directory does not exist: %s", config.CaddyModulesDir)
 		}
-		return fmt.Errorf("failed to read modules directory: %v", err)
+		return nil, fmt.Errorf("failed to read modules directory: %v", err)
 	}
 
-	if len(files) == 0 {
-		fmt.Println("No available modules found")
-		return nil
-	}
-
-	fmt.Println("Available Caddy modules:")
+	var names []string
 	for _, file := range files {
-		if file.IsDir() {
-			continue
+		if !file.IsDir() {
+			names = append(names, file.Name())
 		}
-		fmt.Printf("- %s\n", file.Name())
 	}
-
-	return nil
+	return names, nil
 }
@@ -0,0 +1,151 @@
+// Package monitor probes the sites over HTTP(S) on the local server,
+// records their latency and up/down state and reports state changes through
+// the notify package.
+//
+// Probes are configured in monitor.yaml in the configuration directory;
+// every site is probed with the defaults unless it has its own entry:
+//
+//	interval: 60s
+//	defaults:
+//	  path: /
+//	sites:
+//	  shop.example.com:
+//	    path: /health
+//	    status: 200
+//	    match: ok
+package monitor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of monitor.yaml
+type Config struct {
+	// Interval between rounds of monitor run
+	Interval string `yaml:"interval"`
+	// Timeout of a single probe
+	Timeout string `yaml:"timeout"`
+	// Address the probes connect to, whatever the site's domain resolves to
+	Address string `yaml:"address"`
+	// Failures is how many probes in a row must fail before a site is down
+	Failures int `yaml:"failures"`
+
+	Defaults Probe            `yaml:"defaults"`
+	Sites    map[string]Probe `yaml:"sites"`
+}
+
+// Probe describes how a site is checked. Fields left empty in a site entry
+// are taken from the defaults.
+type Probe struct {
+	// Scheme is https or http
+	Scheme string `yaml:"scheme,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	// Status is the expected status code, any code below 400 when 0
+	Status int `yaml:"status,omitempty"`
+	// Match is a regular expression the response body must match
+	Match string `yaml:"match,omitempty"`
+	// Insecure skips verification of the certificate
+	Insecure bool `yaml:"insecure,omitempty"`
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// LoadConfig reads monitor.yaml. A missing file probes every site with the
+// defaults.
+func LoadConfig() (Config, error) {
+	var c Config
+	data, err := os.ReadFile(configFile())
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return c, fmt.Errorf("failed to parse %s: %v", configFile(), err)
+		}
+	case !os.IsNotExist(err):
+		return c, fmt.Errorf("failed to read monitor settings: %v", err)
+	}
+
+	if c.Interval == "" {
+		c.Interval = "60s"
+	}
+	if c.Timeout == "" {
+		c.Timeout = "10s"
+	}
+	if c.Address == "" {
+		c.Address = "127.0.0.1"
+	}
+	if c.Failures == 0 {
+		c.Failures = 2
+	}
+	if c.Defaults.Scheme == "" {
+		c.Defaults.Scheme = "https"
+	}
+	if c.Defaults.Path == "" {
+		c.Defaults.Path = "/"
+	}
+
+	return c, c.validate()
+}
+
+func (c Config) validate() error {
+	for _, s := range []string{c.Interval, c.Timeout} {
+		if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q in %s", s, configFile())
+		}
+	}
+	for domain := range c.Sites {
+		p := c.probe(domain)
+		if p.Scheme != "http" && p.Scheme != "https" {
+			return fmt.Errorf("site %s: scheme must be http or https", domain)
+		}
+		if _, err := regexp.Compile(p.Match); err != nil {
+			return fmt.Errorf("site %s: invalid match: %v", domain, err)
+		}
+	}
+	if _, err := regexp.Compile(c.Defaults.Match); err != nil {
+		return fmt.Errorf("defaults: invalid match: %v", err)
+	}
+	return nil
+}
+
+// IntervalDuration returns the time between rounds of monitor run
+func (c Config) IntervalDuration() time.Duration {
+	d, _ := time.ParseDuration(c.Interval)
+	return d
+}
+
+func (c Config) timeout() time.Duration {
+	d, _ := time.ParseDuration(c.Timeout)
+	return d
+}
+
+// probe returns the probe of a site, filled in from the defaults
+func (c Config) probe(domain string) Probe {
+	p, ok := c.Sites[domain]
+	if !ok {
+		return c.Defaults
+	}
+	if p.Scheme == "" {
+		p.Scheme = c.Defaults.Scheme
+	}
+	if p.Path == "" {
+		p.Path = c.Defaults.Path
+	}
+	if p.Status == 0 {
+		p.Status = c.Defaults.Status
+	}
+	if p.Match == "" {
+		p.Match = c.Defaults.Match
+	}
+	p.Insecure = p.Insecure || c.Defaults.Insecure
+	return p
+}
+
+func configFile() string {
+	return filepath.Join(config.ConfigDir, "monitor.yaml")
+}
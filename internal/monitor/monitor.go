@@ -0,0 +1,225 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/notify"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// States of a site
+const (
+	Unknown = "unknown"
+	Up      = "up"
+	Down    = "down"
+)
+
+const (
+	// probes run at once
+	parallel = 8
+	// latency samples and state changes kept per site
+	keepLatencies = 60
+	keepChanges   = 20
+)
+
+// Status is the monitoring state of a site
+type Status struct {
+	Domain  string    `json:"domain"`
+	URL     string    `json:"url"`
+	State   string    `json:"state"`
+	Since   time.Time `json:"since"`
+	Checked time.Time `json:"checked"`
+	// Failures counts the failed probes in a row
+	Failures  int      `json:"failures"`
+	Code      int      `json:"status_code,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	LatencyMS int64    `json:"latency_ms"`
+	Latencies []int64  `json:"latencies_ms"`
+	Changes   []Change `json:"changes"`
+}
+
+// Change is a transition between states
+type Change struct {
+	Time  time.Time `json:"time"`
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	Error string    `json:"error,omitempty"`
+}
+
+// AverageMS returns the mean of the recent latencies
+func (s Status) AverageMS() int64 {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	var sum int64
+	for _, l := range s.Latencies {
+		sum += l
+	}
+	return sum / int64(len(s.Latencies))
+}
+
+// Check probes every monitored site once, records the results and reports
+// sites going down or coming back through the notification channels. It
+// returns the state of every monitored site.
+func Check() ([]Status, error) {
+	c, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sites, err := site.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	for _, s := range sites {
+		if !c.probe(s.Domain).Disabled {
+			domains = append(domains, s.Domain)
+		}
+	}
+
+	results := make([]Result, len(domains))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	for i, domain := range domains {
+		wg.Add(1)
+		go func(i int, domain string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = c.probeSite(domain)
+		}(i, domain)
+	}
+	wg.Wait()
+
+	statuses, err := record(c, results)
+	if err != nil {
+		return nil, err
+	}
+
+	var down []notify.Event
+	for _, s := range statuses {
+		if s.State == Down {
+			down = append(down, notify.Event{Name: notify.SiteDown, Subject: s.Domain,
+				Message: fmt.Sprintf("%s: %s", s.URL, s.Error)})
+		}
+	}
+	return statuses, notify.Reconcile([]string{notify.SiteDown}, down)
+}
+
+// record folds the results into the saved state. Sites that are no longer
+// probed are dropped.
+func record(c Config, results []Result) ([]Status, error) {
+	release, err := lock.Acquire(lock.Monitor)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	previous, err := load()
+	if err != nil {
+		return nil, err
+	}
+	byDomain := map[string]Status{}
+	for _, s := range previous {
+		byDomain[s.Domain] = s
+	}
+
+	now := time.Now().UTC()
+	statuses := make([]Status, 0, len(results))
+	for _, r := range results {
+		s, ok := byDomain[r.Domain]
+		if !ok {
+			s = Status{Domain: r.Domain, State: Unknown, Since: now, Changes: []Change{}}
+		}
+
+		s.URL, s.Checked, s.Code, s.Error = r.URL, now, r.Code, r.Error
+		s.LatencyMS = r.Latency.Milliseconds()
+		s.Latencies = append(s.Latencies, s.LatencyMS)
+		if len(s.Latencies) > keepLatencies {
+			s.Latencies = s.Latencies[len(s.Latencies)-keepLatencies:]
+		}
+
+		state := s.State
+		if r.OK {
+			s.Failures = 0
+			state = Up
+		} else if s.Failures++; s.Failures >= c.Failures {
+			state = Down
+		}
+
+		if state != s.State {
+			s.Changes = append(s.Changes, Change{Time: now, From: s.State, To: state, Error: r.Error})
+			if len(s.Changes) > keepChanges {
+				s.Changes = s.Changes[len(s.Changes)-keepChanges:]
+			}
+			s.State, s.Since = state, now
+		}
+		statuses = append(statuses, s)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Domain < statuses[j].Domain })
+	return statuses, save(statuses)
+}
+
+// Statuses returns the state recorded by the last check, sorted by domain
+func Statuses() ([]Status, error) {
+	return load()
+}
+
+// Run checks the sites every interval until the process is stopped
+func Run(report func([]Status, error)) error {
+	c, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	for {
+		report(Check())
+		time.Sleep(c.IntervalDuration())
+	}
+}
+
+func load() ([]Status, error) {
+	data, err := os.ReadFile(stateFile())
+	if os.IsNotExist(err) {
+		return []Status{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read monitor state: %v", err)
+	}
+
+	var statuses []Status
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to parse monitor state: %v", err)
+	}
+	return statuses, nil
+}
+
+func save(statuses []Status) error {
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(stateFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write monitor state: %v", err)
+	}
+	return nil
+}
+
+func stateFile() string {
+	return filepath.Join(config.StateDir, "monitor.json")
+}
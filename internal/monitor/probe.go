@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// Result is the outcome of one probe
+type Result struct {
+	Domain  string        `json:"domain"`
+	URL     string        `json:"url"`
+	OK      bool          `json:"ok"`
+	Code    int           `json:"status_code,omitempty"`
+	Latency time.Duration `json:"-"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// probeSite requests a site from the local server. The URL carries the domain,
+// so the Host header and TLS server name are those of the site, while the
+// connection always goes to the configured address.
+func (c Config) probeSite(domain string) Result {
+	p := c.probe(domain)
+	url := p.Scheme + "://" + domain + p.Path
+	r := Result{Domain: domain, URL: url}
+
+	dialer := &net.Dialer{Timeout: c.timeout()}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if _, _, err := net.SplitHostPort(c.Address); err == nil {
+				return dialer.DialContext(ctx, network, c.Address)
+			}
+			_, port, _ := net.SplitHostPort(addr)
+			return dialer.DialContext(ctx, network, net.JoinHostPort(c.Address, port))
+		},
+		TLSClientConfig:   &tls.Config{ServerName: domain, InsecureSkipVerify: p.Insecure},
+		DisableKeepAlives: true,
+		Proxy:             nil,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   c.timeout(),
+		// A redirect is an answer from the site, judge it by its status
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		r.Latency = time.Since(start)
+		r.Error = err.Error()
+		return r
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	r.Latency = time.Since(start)
+	r.Code = resp.StatusCode
+	if err != nil {
+		r.Error = fmt.Sprintf("failed to read response: %v", err)
+		return r
+	}
+
+	switch {
+	case p.Status != 0 && resp.StatusCode != p.Status:
+		r.Error = fmt.Sprintf("status %d, expected %d", resp.StatusCode, p.Status)
+	case p.Status == 0 && resp.StatusCode >= 400:
+		r.Error = fmt.Sprintf("status %d", resp.StatusCode)
+	case p.Match != "" && !regexp.MustCompile(p.Match).Match(body):
+		r.Error = fmt.Sprintf("response does not match %q", p.Match)
+	default:
+		r.OK = true
+	}
+	return r
+}
@@ -0,0 +1,43 @@
+package monitor
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// DefaultSchedule probes the sites every minute
+const DefaultSchedule = "* * * * *"
+
+// Schedule installs a cron job checking the sites on schedule, for servers
+// that do not run monitor run as a service
+func Schedule(schedule string) error {
+	job := site.CronJob{Schedule: schedule, Command: config.GetBinaryPath() + " monitor check --quiet"}
+	if err := job.Validate(); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("# CLIBoard site monitoring\n%s root %s\n", job.Schedule, job.Command)
+	if err := os.MkdirAll(config.CronDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cron directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(cronPath(), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to create monitoring cron job: %v", err)
+	}
+	return nil
+}
+
+// Unschedule removes the cron job checking the sites
+func Unschedule() error {
+	if err := os.Remove(cronPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove monitoring cron job: %v", err)
+	}
+	return nil
+}
+
+func cronPath() string {
+	return config.GetCronPath("cliboard-monitor")
+}
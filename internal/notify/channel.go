@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Channel types
+const (
+	Webhook  = "webhook"
+	Slack    = "slack"
+	SMTP     = "smtp"
+	Telegram = "telegram"
+	Ntfy     = "ntfy"
+)
+
+// Channel is a destination for notifications
+type Channel struct {
+	Type string `yaml:"type"`
+
+	// URL of a webhook, a Slack-compatible incoming webhook or an ntfy
+	// topic such as https://ntfy.sh/my-server
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// SMTP server and envelope
+	Host     string   `yaml:"host,omitempty"`
+	Port     int      `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+
+	// Telegram bot token and chat. APIURL replaces https://api.telegram.org.
+	Token  string `yaml:"token,omitempty"`
+	ChatID string `yaml:"chat_id,omitempty"`
+	APIURL string `yaml:"api_url,omitempty"`
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func (c Channel) validate() error {
+	switch c.Type {
+	case Webhook, Slack, Ntfy:
+		if c.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+	case SMTP:
+		if c.Host == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("host, from and to are required")
+		}
+	case Telegram:
+		if c.Token == "" || c.ChatID == "" {
+			return fmt.Errorf("token and chat_id are required")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (use %s, %s, %s, %s or %s)", c.Type, Webhook, Slack, SMTP, Telegram, Ntfy)
+	}
+	return nil
+}
+
+func (c Channel) deliver(e Event) error {
+	switch c.Type {
+	case Webhook:
+		body, _ := json.Marshal(e)
+		return c.post(c.URL, "application/json", body, nil)
+	case Slack:
+		body, _ := json.Marshal(map[string]string{"text": text(e)})
+		return c.post(c.URL, "application/json", body, nil)
+	case Ntfy:
+		headers := map[string]string{"Title": header(e.Title())}
+		if !e.Resolved {
+			headers["Priority"] = "high"
+			headers["Tags"] = "warning"
+		}
+		return c.post(c.URL, "text/plain", []byte(e.Message), headers)
+	case Telegram:
+		api := strings.TrimRight(c.APIURL, "/")
+		if api == "" {
+			api = "https://api.telegram.org"
+		}
+		body, _ := json.Marshal(map[string]string{"chat_id": c.ChatID, "text": text(e)})
+		return c.post(api+"/bot"+c.Token+"/sendMessage", "application/json", body, nil)
+	case SMTP:
+		return c.mail(e)
+	}
+	return c.validate()
+}
+
+// post sends a request and treats any non-2xx response as a failure
+func (c Channel) post(target, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "cliboard")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// Do not leak tokens embedded in the URL into logs
+		if uerr, ok := err.(*url.Error); ok {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// mail sends the event as a plain text email. STARTTLS is used when the
+// server offers it; credentials are only sent over TLS or to localhost.
+func (c Channel) mail(e Event) error {
+	port := c.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", header(c.From))
+	fmt.Fprintf(&msg, "To: %s\r\n", header(strings.Join(c.To, ", ")))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", header(e.Title())))
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(text(e), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return smtp.SendMail(addr, auth, c.From, c.To, []byte(msg.String()))
+}
+
+// header folds a value onto one line so that a subject or address given on
+// the command line can't add headers of its own
+func header(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+}
+
+// text renders an event for chat and email channels
+func text(e Event) string {
+	return fmt.Sprintf("%s\n%s\n%s", e.Title(), e.Message, e.Time.Format(time.RFC3339))
+}
@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var event = Event{
+	Name:    BackupFailed,
+	Subject: "example.com",
+	Message: "daily backup failed",
+	Host:    "web1",
+	Time:    time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC),
+}
+
+func TestWebhook(t *testing.T) {
+	var got Event
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	c := Channel{Type: Webhook, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if err := c.deliver(event); err != nil {
+		t.Fatalf("delivery failed: %v", err)
+	}
+	if got != event {
+		t.Errorf("received %+v, want %+v", got, event)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization header %q, want the configured one", auth)
+	}
+}
+
+func TestWebhookRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Channel{Type: Webhook, URL: server.URL}.deliver(event)
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid token") {
+		t.Fatalf("got %v, want the status and body of the response", err)
+	}
+}
+
+// smtpServer accepts one message and returns its recipients and data
+func smtpServer(t *testing.T) (port int, result <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	ch := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var got []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				got = append(got, strings.TrimSpace(line))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				got = append(got, data.String())
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				ch <- got
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, ch
+}
+
+func TestSMTP(t *testing.T) {
+	port, result := smtpServer(t)
+	c := Channel{Type: SMTP, Host: "127.0.0.1", Port: port, From: "cliboard@example.com", To: []string{"ops@example.com"}}
+
+	if err := c.deliver(event); err != nil {
+		t.Fatalf("delivery failed: %v", err)
+	}
+
+	got := <-result
+	if len(got) != 2 || got[0] != "RCPT TO:<ops@example.com>" {
+		t.Fatalf("received %q, want one message to ops@example.com", got)
+	}
+	if !strings.Contains(got[1], "Subject: [web1] backup failed example.com\r\n") {
+		t.Errorf("message has no subject line:\n%s", got[1])
+	}
+	if !strings.Contains(got[1], "\r\n\r\n[web1] backup failed example.com\r\ndaily backup failed\r\n") {
+		t.Errorf("message has no body:\n%s", got[1])
+	}
+}
+
+func TestSMTPSubjectInjection(t *testing.T) {
+	port, result := smtpServer(t)
+	c := Channel{Type: SMTP, Host: "127.0.0.1", Port: port, From: "cliboard@example.com", To: []string{"ops@example.com"}}
+
+	e := event
+	e.Subject = "example.com\r\nBcc: victim@example.org"
+	if err := c.deliver(e); err != nil {
+		t.Fatalf("delivery failed: %v", err)
+	}
+
+	got := <-result
+	headers, _, _ := strings.Cut(got[1], "\r\n\r\n")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Fatalf("subject added a header:\n%s", headers)
+		}
+	}
+	if !strings.Contains(headers, "Subject: [web1] backup failed example.com Bcc: victim@example.org\r\n") {
+		t.Errorf("subject not folded onto one line:\n%s", headers)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":         "plain",
+		"a\r\nb":        "a b",
+		"a\nb\rc":       "a b c",
+		"\r\nTo: x\r\n": "To: x",
+		"":              "",
+	} {
+		if got := header(in); got != want {
+			t.Errorf("header(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package notify delivers alerts about backups, certificates, disk space
+// and services to webhooks, Slack, email, Telegram and ntfy.
+//
+// Channels and the routing of events to them are read from notify.yaml in
+// the configuration directory:
+//
+//	channels:
+//	  ops:
+//	    type: slack
+//	    url: https://hooks.slack.com/services/...
+//	routes:
+//	  - events: [backup_failed, backup_stale]
+//	    channels: [ops]
+package notify
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Events that can be routed to channels
+const (
+	BackupFailed        = "backup_failed"
+	BackupStale         = "backup_stale"
+	DiskFull            = "disk_full"
+	CertificateExpiring = "certificate_expiring"
+	SiteDown            = "site_down"
+	PHPFPMDown          = "phpfpm_down"
+	ReloadFailed        = "reload_failed"
+)
+
+// Events lists every event name
+var Events = []string{BackupFailed, BackupStale, DiskFull, CertificateExpiring, SiteDown, PHPFPMDown, ReloadFailed}
+
+// Event is something worth telling an administrator about
+type Event struct {
+	Name string `json:"event"`
+	// Subject is what the event is about, e.g. a domain or a path
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+	// Resolved is set when a condition reported earlier has cleared
+	Resolved bool      `json:"resolved,omitempty"`
+	Host     string    `json:"host"`
+	Time     time.Time `json:"time"`
+}
+
+// Title is a one-line summary of the event
+func (e Event) Title() string {
+	title := strings.ReplaceAll(e.Name, "_", " ")
+	if e.Resolved {
+		title = "resolved: " + title
+	}
+	if e.Subject != "" {
+		title += " " + e.Subject
+	}
+	return fmt.Sprintf("[%s] %s", e.Host, title)
+}
+
+// Config is the content of notify.yaml
+type Config struct {
+	Channels map[string]Channel `yaml:"channels"`
+	Routes   []Route            `yaml:"routes"`
+
+	// Thresholds of the periodic checks
+	Thresholds struct {
+		// DiskPercent is the disk usage that counts as almost full
+		DiskPercent int `yaml:"disk_percent"`
+		// CertificateDays is how long before expiry to warn
+		CertificateDays int `yaml:"certificate_days"`
+		// BackupMaxAge is how old the newest backup may get
+		BackupMaxAge string `yaml:"backup_max_age"`
+		// Repeat is how often a condition that persists is reported again
+		Repeat string `yaml:"repeat"`
+	} `yaml:"thresholds"`
+}
+
+// Route sends events to channels. The event name * matches every event.
+type Route struct {
+	Events   []string `yaml:"events"`
+	Channels []string `yaml:"channels"`
+}
+
+// LoadConfig reads notify.yaml. A missing file is an empty configuration.
+func LoadConfig() (Config, error) {
+	var c Config
+	data, err := os.ReadFile(configFile())
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return c, fmt.Errorf("failed to parse %s: %v", configFile(), err)
+		}
+	case !os.IsNotExist(err):
+		return c, fmt.Errorf("failed to read notification settings: %v", err)
+	}
+
+	if c.Thresholds.DiskPercent == 0 {
+		c.Thresholds.DiskPercent = 90
+	}
+	if c.Thresholds.CertificateDays == 0 {
+		c.Thresholds.CertificateDays = 14
+	}
+	if c.Thresholds.BackupMaxAge == "" {
+		c.Thresholds.BackupMaxAge = "48h"
+	}
+	if c.Thresholds.Repeat == "" {
+		c.Thresholds.Repeat = "24h"
+	}
+
+	return c, c.validate()
+}
+
+func (c Config) validate() error {
+	for name, ch := range c.Channels {
+		if err := ch.validate(); err != nil {
+			return fmt.Errorf("channel %s: %v", name, err)
+		}
+	}
+	for i, r := range c.Routes {
+		for _, event := range r.Events {
+			if event != "*" && !Known(event) {
+				return fmt.Errorf("route %d: unknown event %s", i+1, event)
+			}
+		}
+		for _, name := range r.Channels {
+			if _, ok := c.Channels[name]; !ok {
+				return fmt.Errorf("route %d: unknown channel %s", i+1, name)
+			}
+		}
+	}
+	for _, s := range []string{c.Thresholds.BackupMaxAge, c.Thresholds.Repeat} {
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid duration %q in thresholds", s)
+		}
+	}
+	return nil
+}
+
+// BackupMaxAge returns the configured backup age threshold
+func (c Config) BackupMaxAge() time.Duration {
+	d, _ := time.ParseDuration(c.Thresholds.BackupMaxAge)
+	return d
+}
+
+// RepeatInterval returns how often persisting conditions are reported
+func (c Config) RepeatInterval() time.Duration {
+	d, _ := time.ParseDuration(c.Thresholds.Repeat)
+	return d
+}
+
+// ChannelNames returns the configured channels, sorted
+func (c Config) ChannelNames() []string {
+	names := make([]string, 0, len(c.Channels))
+	for name := range c.Channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// channelsFor returns the channels an event is routed to
+func (c Config) channelsFor(event string) []string {
+	var names []string
+	for _, r := range c.Routes {
+		if !contains(r.Events, event) && !contains(r.Events, "*") {
+			continue
+		}
+		for _, name := range r.Channels {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Send delivers an event to every channel it is routed to. Nothing is sent
+// when notifications are not configured.
+func Send(e Event) error {
+	c, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	return c.Send(e)
+}
+
+// Send delivers an event to every channel it is routed to
+func (c Config) Send(e Event) error {
+	if e.Host == "" {
+		e.Host, _ = os.Hostname()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	var failed []string
+	for _, name := range c.channelsFor(e.Name) {
+		if err := c.Channels[name].deliver(e); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to notify %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Test sends a test message to one channel, ignoring the routes
+func Test(channel string) error {
+	c, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	ch, ok := c.Channels[channel]
+	if !ok {
+		return fmt.Errorf("channel %s is not configured in %s", channel, configFile())
+	}
+
+	host, _ := os.Hostname()
+	return ch.deliver(Event{
+		Name:    "test",
+		Message: "Test notification from cliboard, channel " + channel + " works",
+		Host:    host,
+		Time:    time.Now().UTC(),
+	})
+}
+
+// Known reports whether name is an event
+func Known(name string) bool {
+	return contains(Events, name)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func configFile() string {
+	return filepath.Join(config.ConfigDir, "notify.yaml")
+}
@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// alert is a condition that has been reported and not yet resolved
+type alert struct {
+	Event   string    `json:"event"`
+	Subject string    `json:"subject"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+	Sent    time.Time `json:"sent"`
+}
+
+func (a alert) key() string { return a.Event + " " + a.Subject }
+
+// Reconcile reports the conditions a check currently finds. New conditions
+// are sent at once, conditions that persist are sent again after the repeat
+// interval, and conditions of the given events that are no longer found are
+// sent as resolved. Events a check does not cover are left alone, so several
+// checks can share the state.
+func Reconcile(events []string, current []Event) error {
+	c, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	release, err := lock.Acquire(lock.Notify)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	alerts, err := loadAlerts()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var failed error
+	send := func(e Event) {
+		e.Time = now
+		if err := c.Send(e); err != nil && failed == nil {
+			failed = err
+		}
+	}
+
+	found := map[string]bool{}
+	for _, e := range current {
+		a := alert{Event: e.Name, Subject: e.Subject, Message: e.Message, Since: now}
+		found[a.key()] = true
+
+		if prev, ok := alerts[a.key()]; ok {
+			a.Since, a.Sent = prev.Since, prev.Sent
+			if now.Sub(prev.Sent) < c.RepeatInterval() {
+				alerts[a.key()] = a
+				continue
+			}
+		}
+		send(e)
+		a.Sent = now
+		alerts[a.key()] = a
+	}
+
+	for key, a := range alerts {
+		if found[key] || !contains(events, a.Event) {
+			continue
+		}
+		send(Event{
+			Name:     a.Event,
+			Subject:  a.Subject,
+			Message:  fmt.Sprintf("%s (since %s)", a.Message, a.Since.Format(time.RFC3339)),
+			Resolved: true,
+		})
+		delete(alerts, key)
+	}
+
+	if err := saveAlerts(alerts); err != nil {
+		return err
+	}
+	return failed
+}
+
+// Active returns the conditions that are currently reported, oldest first
+func Active() ([]Event, error) {
+	alerts, err := loadAlerts()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(alerts))
+	for _, a := range alerts {
+		events = append(events, Event{Name: a.Event, Subject: a.Subject, Message: a.Message, Time: a.Since})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+func loadAlerts() (map[string]alert, error) {
+	alerts := map[string]alert{}
+
+	data, err := os.ReadFile(stateFile())
+	if os.IsNotExist(err) {
+		return alerts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification state: %v", err)
+	}
+
+	var list []alert
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse notification state: %v", err)
+	}
+	for _, a := range list {
+		alerts[a.key()] = a
+	}
+	return alerts, nil
+}
+
+func saveAlerts(alerts map[string]alert) error {
+	list := make([]alert, 0, len(alerts))
+	for _, a := range alerts {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].key() < list[j].key() })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(stateFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write notification state: %v", err)
+	}
+	return nil
+}
+
+func stateFile() string {
+	return filepath.Join(config.StateDir, "notify-state.json")
+}
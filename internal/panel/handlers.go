@@ -0,0 +1,205 @@
+package panel
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+)
+
+// logLines is how many access log entries the site page shows
+const logLines = 50
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.login(w, r)
+		return
+	}
+
+	// Double-submit token, as there is no session yet to bind one to
+	token, err := randomToken()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	setCookie(w, loginCSRFCookie, token, 600)
+	s.render(w, "login", map[string]interface{}{"CSRF": token, "Error": r.URL.Query().Get("failed") != ""})
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(loginCSRFCookie)
+	token := r.PostFormValue("csrf")
+	if err != nil || token == "" || token != cookie.Value {
+		http.Error(w, "invalid CSRF token", http.StatusForbidden)
+		return
+	}
+	setCookie(w, loginCSRFCookie, "", -1)
+
+	client := clientAddress(r)
+	if s.sessions.blocked(client) {
+		http.Error(w, "too many failed logins, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user := r.PostFormValue("user")
+	if !authenticate(user, r.PostFormValue("password"), r.PostFormValue("code")) {
+		s.sessions.fail(client)
+		logf("failed login for %q from %s", user, client)
+		http.Redirect(w, r, "/login?failed=1", http.StatusSeeOther)
+		return
+	}
+
+	if _, err := s.sessions.create(w, user); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	logf("%s logged in from %s", user, client)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request, sess *session) {
+	data := s.pageData(sess)
+
+	sites, err := site.List()
+	if err != nil {
+		data["Flash"], data["Error"] = err.Error(), true
+	}
+	data["Sites"] = sites
+	data["PHPVersions"] = php.InstalledVersions()
+
+	s.render(w, "dashboard", data)
+}
+
+func (s *Server) handleCreateSite(w http.ResponseWriter, r *http.Request, sess *session) {
+	domain := strings.ToLower(strings.TrimSpace(r.PostFormValue("domain")))
+	if !site.ValidDomain(domain) {
+		s.act(w, r, sess, "/", "create-site", "", func() error {
+			return fmt.Errorf("invalid domain %q", domain)
+		})
+		return
+	}
+
+	s.act(w, r, sess, "/sites/"+domain, "create-site "+domain, "Site "+domain+" created", func() error {
+		if _, err := os.Stat(config.GetSiteConfigPath(domain)); err == nil {
+			return fmt.Errorf("site %s already exists", domain)
+		}
+		return site.Create(domain)
+	})
+}
+
+// handleSite serves /sites/<domain> and the actions below it
+func (s *Server) handleSite(w http.ResponseWriter, r *http.Request, sess *session, rest string) {
+	domain, action, _ := strings.Cut(rest, "/")
+	if !site.ValidDomain(domain) {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := os.Stat(config.GetSiteConfigPath(domain)); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	back := "/sites/" + domain
+	switch {
+	case action == "" && r.Method != http.MethodPost:
+		s.siteDetail(w, sess, domain)
+
+	case action == "delete" && r.Method == http.MethodPost:
+		if r.PostFormValue("confirm") != domain {
+			s.act(w, r, sess, back, "delete-site "+domain, "", func() error {
+				return fmt.Errorf("type the domain to confirm deleting the site")
+			})
+			return
+		}
+		s.act(w, r, sess, "/", "delete-site "+domain, "Site "+domain+" deleted", func() error {
+			return site.Remove(domain)
+		})
+
+	case action == "modules" && r.Method == http.MethodPost:
+		name := r.PostFormValue("module")
+		if r.PostFormValue("enabled") == "true" {
+			s.act(w, r, sess, back, "add-module "+domain+" "+name, "Module "+name+" enabled", func() error {
+				return module.Add(domain, name)
+			})
+		} else {
+			s.act(w, r, sess, back, "remove-module "+domain+" "+name, "Module "+name+" disabled", func() error {
+				return module.Remove(domain, name)
+			})
+		}
+
+	case action == "php" && r.Method == http.MethodPost:
+		version := r.PostFormValue("version")
+		if version == "" {
+			s.act(w, r, sess, back, "disable-php "+domain, "PHP disabled", func() error {
+				return php.Disable(domain)
+			})
+		} else {
+			s.act(w, r, sess, back, "enable-php "+domain+" "+version, "PHP "+version+" enabled", func() error {
+				return php.Enable(domain, version)
+			})
+		}
+
+	case action == "backup" && r.Method == http.MethodPost:
+		switch r.PostFormValue("do") {
+		case "run":
+			s.act(w, r, sess, back, "backup-run "+domain, "Backup taken", func() error {
+				_, err := backup.RunSite(domain)
+				return err
+			})
+		case "enable":
+			s.act(w, r, sess, back, "enable-backup "+domain, "Automatic backups enabled", func() error {
+				return backup.EnableSite(domain)
+			})
+		case "disable":
+			s.act(w, r, sess, back, "disable-backup "+domain, "Automatic backups disabled", func() error {
+				return backup.DisableSite(domain)
+			})
+		case "restore":
+			kind, name := r.PostFormValue("kind"), r.PostFormValue("name")
+			s.act(w, r, sess, back, "backup-restore "+domain+" "+kind+" "+name, "Site restored from "+kind+" backup "+name, func() error {
+				if r.PostFormValue("confirm") != "yes" {
+					return fmt.Errorf("confirm the restore, it overwrites the site's files")
+				}
+				return backup.RestoreSite(domain, kind, name)
+			})
+		default:
+			http.Error(w, "unknown backup action", http.StatusBadRequest)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) siteDetail(w http.ResponseWriter, sess *session, domain string) {
+	data := s.pageData(sess)
+
+	info, err := site.Get(domain)
+	if err != nil {
+		data["Flash"], data["Error"] = err.Error(), true
+	}
+	data["Site"] = info
+
+	modules, err := module.Available()
+	if err != nil {
+		data["Flash"], data["Error"] = err.Error(), true
+	}
+	data["Modules"] = modules
+	data["PHPVersions"] = php.InstalledVersions()
+
+	snapshots, err := backup.Snapshots(domain)
+	if err != nil {
+		data["Flash"], data["Error"] = err.Error(), true
+	}
+	data["Snapshots"] = snapshots
+	data["Log"] = caddy.AccessLog(domain, logLines)
+
+	s.render(w, "site", data)
+}
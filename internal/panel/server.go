@@ -0,0 +1,198 @@
+// Package panel serves the cliboard web control panel. It is meant to run on
+// a loopback address behind a Caddy site set up with Setup, and calls the
+// same internal packages as the command line.
+package panel
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doko89/cliboard/internal/audit"
+)
+
+//go:embed templates/*.html static/*
+var assets embed.FS
+
+// DefaultListen is the address the panel listens on when none is given
+const DefaultListen = "127.0.0.1:8421"
+
+// Server is the panel's HTTP handler
+type Server struct {
+	sessions  *sessions
+	templates map[string]*template.Template
+	static    http.Handler
+
+	// changes serializes actions that modify the configuration
+	changes sync.Mutex
+}
+
+// NewServer parses the embedded templates and returns a panel handler
+func NewServer() (*Server, error) {
+	s := &Server{sessions: newSessions(), templates: map[string]*template.Template{}}
+
+	funcs := template.FuncMap{
+		"contains": func(list []string, item string) bool {
+			for _, v := range list {
+				if v == item {
+					return true
+				}
+			}
+			return false
+		},
+		"date": func(t time.Time) string { return t.Format("2006-01-02") },
+	}
+
+	for _, page := range []string{"login", "dashboard", "site"} {
+		t, err := template.New("layout.html").Funcs(funcs).ParseFS(assets, "templates/layout.html", "templates/"+page+".html")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse panel templates: %v", err)
+		}
+		s.templates[page] = t
+	}
+
+	static, err := fs.Sub(assets, "static")
+	if err != nil {
+		return nil, err
+	}
+	s.static = http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+
+	return s, nil
+}
+
+// Serve runs the panel on addr until it fails
+func Serve(addr string) error {
+	users, err := Users()
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("no panel users, add one with: cliboard panel user add <name>")
+	}
+
+	s, err := NewServer()
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// Backups and reloads can take a while
+		WriteTimeout: 10 * time.Minute,
+	}
+
+	fmt.Printf("Serving the cliboard panel on http://%s\n", addr)
+	return server.ListenAndServe()
+}
+
+// ServeHTTP routes a request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'self'; img-src 'self'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "same-origin")
+	h.Set("Cache-Control", "no-store")
+
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/static/"):
+		s.static.ServeHTTP(w, r)
+		return
+	case path == "/login":
+		s.handleLogin(w, r)
+		return
+	}
+
+	sess := s.sessions.get(r)
+	if sess == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Every state-changing request must carry the session's CSRF token
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sess.validCSRF(r) {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+	}
+
+	switch {
+	case path == "/" && r.Method != http.MethodPost:
+		s.handleDashboard(w, r, sess)
+	case path == "/logout" && r.Method == http.MethodPost:
+		s.sessions.destroy(w, r)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	case path == "/sites" && r.Method == http.MethodPost:
+		s.handleCreateSite(w, r, sess)
+	case strings.HasPrefix(path, "/sites/"):
+		s.handleSite(w, r, sess, strings.TrimPrefix(path, "/sites/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// render executes a page template
+func (s *Server) render(w http.ResponseWriter, page string, data map[string]interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates[page].Execute(w, data); err != nil {
+		logf("failed to render %s: %v", page, err)
+	}
+}
+
+// pageData returns the data every logged-in page needs and takes the flash
+// message left by the previous action
+func (s *Server) pageData(sess *session) map[string]interface{} {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	data := map[string]interface{}{
+		"User":  sess.user,
+		"CSRF":  sess.csrf,
+		"Flash": sess.flash,
+		"Error": sess.flashError,
+	}
+	sess.flash, sess.flashError = "", false
+	return data
+}
+
+// act runs a change on behalf of a user, records it in the audit log and the
+// configuration history and redirects back with the outcome
+func (s *Server) act(w http.ResponseWriter, r *http.Request, sess *session, redirect, command, done string, fn func() error) {
+	s.changes.Lock()
+	err, record := audit.Run(audit.SourcePanel, sess.user, command, fn)
+	if record != nil {
+		logf("%v", record)
+	}
+	s.changes.Unlock()
+
+	s.sessions.mu.Lock()
+	if err != nil {
+		logf("%s %s: %v", sess.user, command, err)
+		sess.flash, sess.flashError = err.Error(), true
+	} else {
+		logf("%s %s", sess.user, command)
+		sess.flash, sess.flashError = done, false
+	}
+	s.sessions.mu.Unlock()
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func logf(format string, args ...interface{}) {
+	log.Printf("panel: "+format, args...)
+}
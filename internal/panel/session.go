@@ -0,0 +1,179 @@
+package panel
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie   = "cliboard_session"
+	loginCSRFCookie = "cliboard_login"
+	sessionTTL      = 8 * time.Hour
+	idleTimeout     = 30 * time.Minute
+
+	// Failed logins allowed per client address within loginWindow
+	maxLoginFailures = 5
+	loginWindow      = 15 * time.Minute
+)
+
+type session struct {
+	user     string
+	csrf     string
+	created  time.Time
+	lastSeen time.Time
+
+	// flash is the outcome of the last action, shown once
+	flash      string
+	flashError bool
+}
+
+// sessions keeps logged-in sessions in memory; restarting the panel logs
+// everyone out
+type sessions struct {
+	mu       sync.Mutex
+	byID     map[string]*session
+	failures map[string][]time.Time
+}
+
+func newSessions() *sessions {
+	return &sessions{byID: map[string]*session{}, failures: map[string][]time.Time{}}
+}
+
+// create starts a session for user and sets its cookie
+func (s *sessions) create(w http.ResponseWriter, user string) (*session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sess := &session{user: user, csrf: csrf, created: now, lastSeen: now}
+
+	s.mu.Lock()
+	s.byID[id] = sess
+	s.mu.Unlock()
+
+	setCookie(w, sessionCookie, id, int(sessionTTL/time.Second))
+	return sess, nil
+}
+
+// get returns the session of a request, or nil
+func (s *sessions) get(r *http.Request) *session {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.byID[cookie.Value]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Sub(sess.created) > sessionTTL || now.Sub(sess.lastSeen) > idleTimeout {
+		delete(s.byID, cookie.Value)
+		return nil
+	}
+	sess.lastSeen = now
+	return sess
+}
+
+// destroy ends the session of a request and clears its cookie
+func (s *sessions) destroy(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		s.mu.Lock()
+		delete(s.byID, cookie.Value)
+		s.mu.Unlock()
+	}
+	setCookie(w, sessionCookie, "", -1)
+}
+
+// blocked reports whether a client has failed to log in too often
+func (s *sessions) blocked(client string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.recentFailures(client)) >= maxLoginFailures
+}
+
+// fail records a failed login of a client
+func (s *sessions) fail(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[client] = append(s.recentFailures(client), time.Now())
+}
+
+func (s *sessions) recentFailures(client string) []time.Time {
+	var recent []time.Time
+	for _, t := range s.failures[client] {
+		if time.Since(t) < loginWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(s.failures, client)
+	} else {
+		s.failures[client] = recent
+	}
+	return recent
+}
+
+// validCSRF checks the token submitted with a form against the session's
+func (sess *session) validCSRF(r *http.Request) bool {
+	token := r.PostFormValue("csrf")
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(sess.csrf)) == 1
+}
+
+// clientAddress returns the address of the client. X-Forwarded-For is only
+// trusted from the local Caddy front end.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			// Caddy appends the address it saw last
+			if i := strings.LastIndex(forwarded, ","); i >= 0 {
+				forwarded = forwarded[i+1:]
+			}
+			if ip := net.ParseIP(strings.TrimSpace(forwarded)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return host
+}
+
+func setCookie(w http.ResponseWriter, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,118 @@
+package panel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Setup makes Caddy serve the panel on domain, with automatic HTTPS, as a
+// reverse proxy to the address the panel listens on. The site lives in its
+// own file next to the main Caddyfile so it is not listed as a site.
+func Setup(domain, listen string) error {
+	if !site.ValidDomain(domain) {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	if _, _, err := net.SplitHostPort(listen); err != nil {
+		return fmt.Errorf("invalid listen address %q: %v", listen, err)
+	}
+
+	siteBlock := caddyfile.NewBlock(domain)
+	siteBlock.Comments = []string{"# cliboard web panel, managed by cliboard panel setup"}
+	if utils.FileExists(config.GetModulePath("security")) {
+		siteBlock.Append(caddyfile.NewDirective("import", "security"))
+	}
+	siteBlock.Append(caddyfile.NewDirective("reverse_proxy", listen))
+
+	panelConfig := &caddyfile.File{Items: []*caddyfile.Directive{siteBlock}}
+	if err := panelConfig.WriteFile(panelConfigPath()); err != nil {
+		return fmt.Errorf("failed to write panel site configuration: %v", err)
+	}
+
+	// Import the panel site from the main Caddyfile
+	release, err := lock.Acquire(lock.Caddyfile)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	mainPath := filepath.Join(config.CaddyRootDir, "Caddyfile")
+	main, err := caddyfile.ParseFile(mainPath)
+	if err != nil {
+		return fmt.Errorf("failed to read Caddy configuration: %v", err)
+	}
+
+	target := config.Target(panelConfigPath())
+	imported := false
+	for _, d := range main.Items {
+		if d.Name == "import" && len(d.Args) > 0 && caddyfile.Unquote(d.Args[0]) == target {
+			imported = true
+		}
+	}
+	if !imported {
+		main.Items = append(main.Items, caddyfile.NewDirective("import", caddyfile.Quote(target)))
+		if err := main.WriteFile(mainPath); err != nil {
+			return fmt.Errorf("failed to update Caddy configuration: %v", err)
+		}
+	}
+
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+
+	fmt.Printf("Panel site %s set up, proxying to %s\n", domain, listen)
+	return nil
+}
+
+// Teardown removes the panel's Caddy site
+func Teardown() error {
+	release, err := lock.Acquire(lock.Caddyfile)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	mainPath := filepath.Join(config.CaddyRootDir, "Caddyfile")
+	main, err := caddyfile.ParseFile(mainPath)
+	if err != nil {
+		return fmt.Errorf("failed to read Caddy configuration: %v", err)
+	}
+
+	target := config.Target(panelConfigPath())
+	items := main.Items[:0]
+	for _, d := range main.Items {
+		if d.Name == "import" && len(d.Args) > 0 && caddyfile.Unquote(d.Args[0]) == target {
+			continue
+		}
+		items = append(items, d)
+	}
+	if len(items) != len(main.Items) {
+		main.Items = items
+		if err := main.WriteFile(mainPath); err != nil {
+			return fmt.Errorf("failed to update Caddy configuration: %v", err)
+		}
+	}
+
+	if err := os.Remove(panelConfigPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove panel site configuration: %v", err)
+	}
+
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+
+	fmt.Println("Panel site removed")
+	return nil
+}
+
+func panelConfigPath() string {
+	return filepath.Join(config.CaddyRootDir, "cliboard-panel.caddy")
+}
@@ -0,0 +1,77 @@
+package panel
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfcVectors are the RFC 6238 appendix B SHA-1 values, cut to six digits
+var rfcVectors = []struct {
+	time int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, v := range rfcVectors {
+		if got := totpCode([]byte("12345678901234567890"), uint64(v.time/totpPeriod)); got != v.code {
+			t.Errorf("totpCode at %d = %s, want %s", v.time, got, v.code)
+		}
+	}
+}
+
+func TestValidTOTP(t *testing.T) {
+	for _, v := range rfcVectors {
+		at := time.Unix(v.time, 0)
+		step, ok := validTOTP(rfcSecret, v.code, at, 0)
+		if !ok || step != v.time/totpPeriod {
+			t.Errorf("code %s at %d: step %d, %v", v.code, v.time, step, ok)
+		}
+
+		// Within the skew either way, but not beyond it
+		for _, d := range []time.Duration{-totpPeriod * time.Second, totpPeriod * time.Second} {
+			if _, ok := validTOTP(rfcSecret, v.code, at.Add(d), 0); !ok {
+				t.Errorf("code %s rejected %v from %d", v.code, d, v.time)
+			}
+		}
+		if _, ok := validTOTP(rfcSecret, v.code, at.Add(3*totpPeriod*time.Second), 0); ok {
+			t.Errorf("code %s accepted three steps after %d", v.code, v.time)
+		}
+	}
+
+	at := time.Unix(1111111111, 0)
+	for _, code := range []string{"", "05047", "0504711", "000000", "abcdef"} {
+		if _, ok := validTOTP(rfcSecret, code, at, 0); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+	if _, ok := validTOTP("not base32!", "050471", at, 0); ok {
+		t.Error("code accepted with an invalid secret")
+	}
+}
+
+func TestValidTOTPReplay(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step, ok := validTOTP(rfcSecret, "050471", at, 0)
+	if !ok {
+		t.Fatal("code rejected")
+	}
+
+	// Once used, neither the code nor an older one is accepted, not even
+	// later within the skew window
+	if _, ok := validTOTP(rfcSecret, "050471", at.Add(totpPeriod*time.Second), step); ok {
+		t.Error("replayed code accepted")
+	}
+	if _, ok := validTOTP(rfcSecret, "081804", at, step); ok {
+		t.Error("code of an earlier step accepted")
+	}
+}
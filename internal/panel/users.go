@@ -0,0 +1,167 @@
+package panel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// User is an account allowed to log in to the panel
+type User struct {
+	Name         string `json:"name"`
+	PasswordHash string `json:"password_hash"`
+	TOTPSecret   string `json:"totp_secret"`
+	// LastTOTPStep is the time step of the last code accepted
+	LastTOTPStep int64 `json:"last_totp_step,omitempty"`
+}
+
+var userNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// dummyHash is compared against for unknown users so that they take as long
+// to reject as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("cliboard"), bcrypt.DefaultCost)
+
+// Users returns the panel accounts sorted by name
+func Users() ([]User, error) {
+	data, err := os.ReadFile(usersFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read panel users: %v", err)
+	}
+
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", usersFile(), err)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// AddUser creates a panel account with a fresh TOTP secret and returns the
+// otpauth URI to enroll it in an authenticator app
+func AddUser(name, password string) (string, error) {
+	if !userNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid user name %q", name)
+	}
+	if len(password) < 10 {
+		return "", fmt.Errorf("password must be at least 10 characters")
+	}
+
+	release, err := lock.Acquire(lock.Users)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	users, err := Users()
+	if err != nil {
+		return "", err
+	}
+	for _, u := range users {
+		if u.Name == name {
+			return "", fmt.Errorf("panel user %s already exists", name)
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %v", err)
+	}
+
+	users = append(users, User{Name: name, PasswordHash: string(hash), TOTPSecret: secret})
+	if err := writeUsers(users); err != nil {
+		return "", err
+	}
+	return totpURI(name, secret), nil
+}
+
+// RemoveUser deletes a panel account
+func RemoveUser(name string) error {
+	release, err := lock.Acquire(lock.Users)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	users, err := Users()
+	if err != nil {
+		return err
+	}
+
+	for i, u := range users {
+		if u.Name == name {
+			return writeUsers(append(users[:i], users[i+1:]...))
+		}
+	}
+	return fmt.Errorf("panel user %s does not exist", name)
+}
+
+// authenticate checks a password and TOTP code, and records the code's time
+// step so that it can't be used again
+func authenticate(name, password, code string) bool {
+	release, err := lock.Acquire(lock.Users)
+	if err != nil {
+		return false
+	}
+	defer release()
+
+	users, err := Users()
+	if err != nil {
+		return false
+	}
+
+	for i, u := range users {
+		if u.Name != name {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+			return false
+		}
+		step, ok := validTOTP(u.TOTPSecret, code, time.Now(), u.LastTOTPStep)
+		if !ok {
+			return false
+		}
+		users[i].LastTOTPStep = step
+		return writeUsers(users) == nil
+	}
+
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+	return false
+}
+
+func writeUsers(users []User) error {
+	if err := os.MkdirAll(config.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The file holds password hashes and TOTP secrets
+	if err := utils.WriteFileAtomic(usersFile(), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write panel users: %v", err)
+	}
+	return os.Chmod(usersFile(), 0600)
+}
+
+func usersFile() string {
+	return filepath.Join(config.ConfigDir, "panel-users.json")
+}
@@ -0,0 +1,133 @@
+package panel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+const testPassword = "correct horse battery"
+
+// testUser creates a panel user in a temporary configuration directory and
+// returns its TOTP secret
+func testUser(t *testing.T) string {
+	t.Helper()
+	configDir, runDir := config.ConfigDir, config.RunDir
+	config.ConfigDir, config.RunDir = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { config.ConfigDir, config.RunDir = configDir, runDir })
+
+	if _, err := AddUser("admin", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	users, err := Users()
+	if err != nil || len(users) != 1 {
+		t.Fatalf("users %v, %v", users, err)
+	}
+	return users[0].TOTPSecret
+}
+
+// currentCode returns the TOTP code for secret right now
+func currentCode(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, uint64(time.Now().Unix()/totpPeriod))
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret := testUser(t)
+	code := currentCode(t, secret)
+
+	if authenticate("admin", "wrong password", code) {
+		t.Error("wrong password accepted")
+	}
+	if authenticate("nobody", testPassword, code) {
+		t.Error("unknown user accepted")
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if authenticate("admin", testPassword, wrong) {
+		t.Error("wrong code accepted")
+	}
+
+	if !authenticate("admin", testPassword, code) {
+		t.Fatal("valid login rejected")
+	}
+	if authenticate("admin", testPassword, code) {
+		t.Error("replayed code accepted")
+	}
+
+	users, _ := Users()
+	if users[0].LastTOTPStep == 0 {
+		t.Error("accepted time step not recorded")
+	}
+}
+
+func TestLoginCSRF(t *testing.T) {
+	secret := testUser(t)
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(cookie, token, code string) *httptest.ResponseRecorder {
+		form := url.Values{"csrf": {token}, "user": {"admin"}, "password": {testPassword}, "code": {code}}
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: loginCSRFCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	code := currentCode(t, secret)
+	if w := login("token-a", "token-b", code); w.Code != http.StatusForbidden {
+		t.Errorf("mismatched CSRF token: status %d", w.Code)
+	}
+	if w := login("", "token-a", code); w.Code != http.StatusForbidden {
+		t.Errorf("missing CSRF cookie: status %d", w.Code)
+	}
+	if w := login("token-a", "", code); w.Code != http.StatusForbidden {
+		t.Errorf("missing CSRF token: status %d", w.Code)
+	}
+
+	w := login("token-a", "token-a", code)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Fatalf("valid login: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie && c.Value != "" {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("no session cookie")
+	}
+
+	// Actions of a session need the session's token
+	r := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader("csrf=wrong"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(session)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("action with a wrong CSRF token: status %d", w.Code)
+	}
+
+	// The code that logged in can't log in again
+	if w := login("token-c", "token-c", code); w.Header().Get("Location") != "/login?failed=1" {
+		t.Errorf("replayed code: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -4,19 +4,48 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
 	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/pkgmgr"
+	"github.com/doko89/cliboard/internal/service"
+	"github.com/doko89/cliboard/internal/utils"
 )
 
 // Enable enables PHP for a site with the specified version
 func Enable(domain, version string) error {
+	return hook.Around(hook.PHPChange, domain, map[string]string{"php_version": version}, func() error {
+		release, err := lock.Site(domain)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return enable(domain, version)
+	})
+}
+
+// enable switches a site to a PHP version with the site lock held
+func enable(domain, version string) error {
+	if err := ValidVersion(version); err != nil {
+		return err
+	}
+
 	// Check if site exists
 	siteConfigPath := config.GetSiteConfigPath(domain)
 	if _, err := os.Stat(siteConfigPath); os.IsNotExist(err) {
 		return fmt.Errorf("site %s does not exist", domain)
 	}
+	if _, err := os.Stat(config.GetSuspendedSiteConfigPath(domain)); err == nil {
+		return fmt.Errorf("site %s is suspended, resume it first", domain)
+	}
 
 	// Check if PHP version is installed
 	if !isVersionInstalled(version) {
@@ -28,56 +57,22 @@ func Enable(domain, version string) error {
 	phpConfigPath := config.GetPHPConfigPath(version)
 	if _, err := os.Stat(phpConfigPath); os.IsNotExist(err) {
 		// Create PHP configuration if it doesn't exist
-		phpConfig := fmt.Sprintf(`(php%s_config) {
-    php_fastcgi unix//run/php/php%s-fpm.sock
-}
-`, version, version)
-		if err := os.MkdirAll(config.CaddyPHPDir, 0755); err != nil {
-			return fmt.Errorf("failed to create PHP configuration directory: %v", err)
-		}
-		if err := os.WriteFile(phpConfigPath, []byte(phpConfig), 0644); err != nil {
-			return fmt.Errorf("failed to create PHP configuration: %v", err)
+		if err := writeCaddyConfig(version, Names(version)); err != nil {
+			return err
 		}
 	}
 
-	// Read site configuration
-	siteConfig, err := os.ReadFile(siteConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
-	}
-
-	// Check if any PHP version is already enabled
-	for _, ver := range getInstalledVersions() {
-		phpImport := fmt.Sprintf("import php%s_config", ver)
-		if strings.Contains(string(siteConfig), phpImport) {
-			// Replace the existing PHP version with the new one
-			updatedConfig := strings.Replace(string(siteConfig),
-				phpImport,
-				fmt.Sprintf("import %s", phpConfigName),
-				1)
-			
-			if err := os.WriteFile(siteConfigPath, []byte(updatedConfig), 0644); err != nil {
-				return fmt.Errorf("failed to update site configuration: %v", err)
-			}
-			
-			// Reload Caddy
-			if err := caddy.Reload(); err != nil {
-				return fmt.Errorf("failed to reload Caddy: %v", err)
-			}
-			
-			fmt.Printf("PHP version updated to %s for site %s\n", version, domain)
+	// Point the site at the new PHP version, replacing any enabled version
+	err := caddyfile.EditSite(siteConfigPath, domain, func(site *caddyfile.Directive) error {
+		if current := phpImport(site); current != nil {
+			current.Args[0] = phpConfigName
 			return nil
 		}
-	}
-
-	// PHP not yet enabled, add it to site configuration
-	updatedConfig := strings.Replace(string(siteConfig),
-		fmt.Sprintf("%s {", domain),
-		fmt.Sprintf("%s {\n    import %s", domain, phpConfigName),
-		1)
-
-	if err := os.WriteFile(siteConfigPath, []byte(updatedConfig), 0644); err != nil {
-		return fmt.Errorf("failed to update site configuration: %v", err)
+		site.AddImport(phpConfigName)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Reload Caddy
@@ -85,45 +80,39 @@ func Enable(domain, version string) error {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
-	fmt.Printf("PHP version %s enabled for site %s\n", version, domain)
 	return nil
 }
 
 // Disable disables PHP for a site
 func Disable(domain string) error {
+	return hook.Around(hook.PHPChange, domain, map[string]string{"php_version": ""}, func() error { return disable(domain) })
+}
+
+func disable(domain string) error {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check if site exists
 	siteConfigPath := config.GetSiteConfigPath(domain)
 	if _, err := os.Stat(siteConfigPath); os.IsNotExist(err) {
 		return fmt.Errorf("site %s does not exist", domain)
 	}
-
-	// Read site configuration
-	siteConfig, err := os.ReadFile(siteConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
-	}
-
-	// Check if any PHP version is enabled
-	phpEnabled := false
-	updatedConfig := string(siteConfig)
-	
-	for _, ver := range getInstalledVersions() {
-		phpImport := fmt.Sprintf("import php%s_config", ver)
-		if strings.Contains(updatedConfig, phpImport) {
-			updatedConfig = strings.Replace(updatedConfig,
-				fmt.Sprintf("    %s\n", phpImport),
-				"",
-				1)
-			phpEnabled = true
-		}
+	if _, err := os.Stat(config.GetSuspendedSiteConfigPath(domain)); err == nil {
+		return fmt.Errorf("site %s is suspended, resume it first", domain)
 	}
 
-	if !phpEnabled {
-		return fmt.Errorf("PHP is not enabled for site %s", domain)
-	}
-
-	if err := os.WriteFile(siteConfigPath, []byte(updatedConfig), 0644); err != nil {
-		return fmt.Errorf("failed to update site configuration: %v", err)
+	// Remove the PHP import from site configuration
+	err = caddyfile.EditSite(siteConfigPath, domain, func(site *caddyfile.Directive) error {
+		if site.RemoveWhere(isPHPImport) == 0 {
+			return fmt.Errorf("PHP is not enabled for site %s", domain)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Reload Caddy
@@ -131,12 +120,17 @@ func Disable(domain string) error {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
-	fmt.Printf("PHP disabled for site %s\n", domain)
 	return nil
 }
 
 // Update updates PHP configuration for a site
 func Update(domain string) error {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check if site exists
 	siteConfigPath := config.GetSiteConfigPath(domain)
 	if _, err := os.Stat(siteConfigPath); os.IsNotExist(err) {
@@ -144,253 +138,396 @@ func Update(domain string) error {
 	}
 
 	// Read site configuration
-	siteConfig, err := os.ReadFile(siteConfigPath)
+	site, err := caddyfile.ReadSite(siteConfigPath, domain)
 	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
+		return err
 	}
 
 	// Check which PHP version is enabled
-	var currentVersion string
-	for _, ver := range getInstalledVersions() {
-		phpImport := fmt.Sprintf("import php%s_config", ver)
-		if strings.Contains(string(siteConfig), phpImport) {
-			currentVersion = ver
-			break
-		}
-	}
-
-	if currentVersion == "" {
+	current := phpImport(site)
+	if current == nil {
 		return fmt.Errorf("PHP is not enabled for site %s", domain)
 	}
+	currentVersion, _ := ImportVersion(current.Args[0])
 
 	// Re-enable the current version (will recreate the PHP configuration if needed)
-	return Enable(domain, currentVersion)
+	return enable(domain, currentVersion)
 }
 
 // Install installs a specific PHP version
 func Install(version string) error {
+	if err := ValidVersion(version); err != nil {
+		return err
+	}
+
 	// Check if already installed
 	if isVersionInstalled(version) {
 		return fmt.Errorf("PHP version %s is already installed", version)
 	}
-	
-	fmt.Printf("Installing PHP %s...\n", version)
-	
+
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return err
+	}
+	names := pm.PHP(version)
+
+	event.Progress("Installing PHP %s...", version)
+
+	// Make sure the version can be installed
+	if err := pm.EnsurePHPRepository(version); err != nil {
+		return fmt.Errorf("failed to set up PHP %s repository: %v", version, err)
+	}
+
 	// Install PHP packages
-	cmd := exec.Command("apt-get", "install", "-y", 
-		fmt.Sprintf("php%s", version),
-		fmt.Sprintf("php%s-fpm", version),
-		fmt.Sprintf("php%s-common", version),
-		fmt.Sprintf("php%s-cli", version))
-	
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	
-	if err := cmd.Run(); err != nil {
+	if err := pm.Install(names.Packages...); err != nil {
 		return fmt.Errorf("failed to install PHP %s: %v", version, err)
 	}
-	
-	// Enable the PHP-FPM service
-	cmd = exec.Command("systemctl", "enable", fmt.Sprintf("php%s-fpm", version))
-	if err := cmd.Run(); err != nil {
+
+	// Make the pool listen where Caddy will look for it
+	if names.PoolConfig != "" {
+		if err := configurePool(names); err != nil {
+			return err
+		}
+	}
+
+	// Enable and start the PHP-FPM service
+	services := service.Detect()
+	if err := services.Enable(names.Service); err != nil {
 		return fmt.Errorf("failed to enable PHP-FPM service: %v", err)
 	}
-	
-	// Start the PHP-FPM service
-	cmd = exec.Command("systemctl", "start", fmt.Sprintf("php%s-fpm", version))
-	if err := cmd.Run(); err != nil {
+	if err := services.Start(names.Service); err != nil {
 		return fmt.Errorf("failed to start PHP-FPM service: %v", err)
 	}
-	
-	// Create PHP configuration for Caddy
-	phpConfig := fmt.Sprintf(`(php%s_config) {
-    php_fastcgi unix//run/php/php%s-fpm.sock
-}
-`, version, version)
 
-	if err := os.MkdirAll(config.CaddyPHPDir, 0755); err != nil {
-		return fmt.Errorf("failed to create PHP configuration directory: %v", err)
+	// Create PHP configuration for Caddy
+	if err := writeCaddyConfig(version, names); err != nil {
+		return err
 	}
 
-	phpConfigPath := config.GetPHPConfigPath(version)
-	if err := os.WriteFile(phpConfigPath, []byte(phpConfig), 0644); err != nil {
-		return fmt.Errorf("failed to create PHP configuration: %v", err)
-	}
-	
-	fmt.Printf("PHP %s installed successfully\n", version)
 	return nil
 }
 
 // Uninstall uninstalls a specific PHP version
 func Uninstall(version string) error {
+	if err := ValidVersion(version); err != nil {
+		return err
+	}
+
 	// Check if installed
 	if !isVersionInstalled(version) {
 		return fmt.Errorf("PHP version %s is not installed", version)
 	}
-	
-	fmt.Printf("Uninstalling PHP %s...\n", version)
-	
-	// Stop and disable the PHP-FPM service
-	exec.Command("systemctl", "stop", fmt.Sprintf("php%s-fpm", version)).Run()
-	exec.Command("systemctl", "disable", fmt.Sprintf("php%s-fpm", version)).Run()
-	
+
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return err
+	}
+	names := pm.PHP(version)
+
+	event.Progress("Uninstalling PHP %s...", version)
+
+	// Stop and disable the PHP-FPM service. A service that is already
+	// stopped or missing must not keep the version from being removed.
+	services := service.Detect()
+	if services.IsActive(names.Service) {
+		if err := services.Stop(names.Service); err != nil {
+			event.Progress("Warning: failed to stop PHP-FPM service: %v", err)
+		}
+	}
+	if err := services.Disable(names.Service); err != nil {
+		event.Progress("Warning: failed to disable PHP-FPM service: %v", err)
+	}
+
 	// Remove PHP packages
-	cmd := exec.Command("apt-get", "remove", "-y", 
-		fmt.Sprintf("php%s", version),
-		fmt.Sprintf("php%s-fpm", version),
-		fmt.Sprintf("php%s-common", version))
-	
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	
-	if err := cmd.Run(); err != nil {
+	if err := pm.Remove(names.Packages...); err != nil {
 		return fmt.Errorf("failed to uninstall PHP %s: %v", version, err)
 	}
-	
+
 	// Remove PHP configuration for Caddy
 	phpConfigPath := config.GetPHPConfigPath(version)
 	if err := os.Remove(phpConfigPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove PHP configuration: %v", err)
 	}
-	
-	fmt.Printf("PHP %s uninstalled successfully\n", version)
+
 	return nil
 }
 
-// ListInstalled lists installed PHP versions
-func ListInstalled() error {
-	versions := getInstalledVersions()
-	
-	if len(versions) == 0 {
-		fmt.Println("No PHP versions installed")
-		return nil
+// AvailableModules returns the extensions that can be installed for a PHP version
+func AvailableModules(version string) ([]pkgmgr.Package, error) {
+	if !isVersionInstalled(version) {
+		return nil, fmt.Errorf("PHP version %s is not installed", version)
 	}
-	
-	fmt.Println("Installed PHP versions:")
-	for _, version := range versions {
-		fmt.Printf("- %s\n", version)
+
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return nil, err
 	}
-	
-	return nil
+	names := pm.PHP(version)
+
+	packages, err := pm.Search(names.ExtensionPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available modules: %v", err)
+	}
+
+	var modules []pkgmgr.Package
+	for _, p := range packages {
+		if name, ok := names.Extension(p.Name); ok && name != "" {
+			p.Name = name
+			modules = append(modules, p)
+		}
+	}
+	return modules, nil
 }
 
-// ListAvailableModules lists available modules for a PHP version
-func ListAvailableModules(version string) error {
-	// Check if PHP version is installed
-	if !isVersionInstalled(version) {
-		return fmt.Errorf("PHP version %s is not installed", version)
+// InstalledModules returns the names of the extensions installed for a PHP
+// version
+func InstalledModules(version string) ([]string, error) {
+	available, err := AvailableModules(version)
+	if err != nil {
+		return nil, err
 	}
-	
-	cmd := exec.Command("apt-cache", "search", fmt.Sprintf("php%s-", version))
-	output, err := cmd.Output()
+
+	pm, err := pkgmgr.Detect()
 	if err != nil {
-		return fmt.Errorf("failed to list available modules: %v", err)
-	}
-	
-	modules := []string{}
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, fmt.Sprintf("php%s-", version)) {
-			parts := strings.SplitN(line, " - ", 2)
-			if len(parts) > 0 {
-				moduleName := strings.TrimPrefix(parts[0], fmt.Sprintf("php%s-", version))
-				modules = append(modules, moduleName)
-			}
+		return nil, err
+	}
+	names := pm.PHP(version)
+
+	var modules []string
+	for _, p := range available {
+		if pm.IsInstalled(names.ExtensionPackage(p.Name)) {
+			modules = append(modules, p.Name)
 		}
 	}
-	
-	if len(modules) == 0 {
-		fmt.Printf("No available modules found for PHP %s\n", version)
-		return nil
+	return modules, nil
+}
+
+// ModuleInstalled reports whether an extension is installed for a PHP version
+func ModuleInstalled(version, module string) bool {
+	if ValidExtension(module) != nil {
+		return false
 	}
-	
-	fmt.Printf("Available modules for PHP %s:\n", version)
-	for _, module := range modules {
-		fmt.Printf("- %s\n", module)
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return false
 	}
-	
-	return nil
+	return pm.IsInstalled(pm.PHP(version).ExtensionPackage(module))
+}
+
+// Installed reports whether a PHP version is installed
+func Installed(version string) bool {
+	return isVersionInstalled(version)
 }
 
 // AddModule adds a module to a PHP version
 func AddModule(version, module string) error {
+	if err := ValidVersion(version); err != nil {
+		return err
+	}
+	if err := ValidExtension(module); err != nil {
+		return err
+	}
+
 	// Check if PHP version is installed
 	if !isVersionInstalled(version) {
 		return fmt.Errorf("PHP version %s is not installed", version)
 	}
-	
+
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return err
+	}
+	names := pm.PHP(version)
+
 	// Install PHP module
-	packageName := fmt.Sprintf("php%s-%s", version, module)
-	fmt.Printf("Installing PHP module %s...\n", packageName)
-	
-	cmd := exec.Command("apt-get", "install", "-y", packageName)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	
-	if err := cmd.Run(); err != nil {
+	packageName := names.ExtensionPackage(module)
+	event.Progress("Installing PHP module %s...", packageName)
+
+	if err := pm.Install(packageName); err != nil {
 		return fmt.Errorf("failed to install PHP module %s: %v", module, err)
 	}
-	
+
 	// Restart PHP-FPM
-	cmd = exec.Command("systemctl", "restart", fmt.Sprintf("php%s-fpm", version))
-	if err := cmd.Run(); err != nil {
+	if err := service.Detect().Restart(names.Service); err != nil {
 		return fmt.Errorf("failed to restart PHP-FPM: %v", err)
 	}
-	
-	fmt.Printf("PHP module %s installed successfully for PHP %s\n", module, version)
+
 	return nil
 }
 
 // RemoveModule removes a module from a PHP version
 func RemoveModule(version, module string) error {
+	if err := ValidVersion(version); err != nil {
+		return err
+	}
+	if err := ValidExtension(module); err != nil {
+		return err
+	}
+
 	// Check if PHP version is installed
 	if !isVersionInstalled(version) {
 		return fmt.Errorf("PHP version %s is not installed", version)
 	}
-	
+
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		return err
+	}
+	names := pm.PHP(version)
+
 	// Remove PHP module
-	packageName := fmt.Sprintf("php%s-%s", version, module)
-	fmt.Printf("Removing PHP module %s...\n", packageName)
-	
-	cmd := exec.Command("apt-get", "remove", "-y", packageName)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	
-	if err := cmd.Run(); err != nil {
+	packageName := names.ExtensionPackage(module)
+	event.Progress("Removing PHP module %s...", packageName)
+
+	if err := pm.Remove(packageName); err != nil {
 		return fmt.Errorf("failed to remove PHP module %s: %v", module, err)
 	}
-	
+
 	// Restart PHP-FPM
-	cmd = exec.Command("systemctl", "restart", fmt.Sprintf("php%s-fpm", version))
-	if err := cmd.Run(); err != nil {
+	if err := service.Detect().Restart(names.Service); err != nil {
 		return fmt.Errorf("failed to restart PHP-FPM: %v", err)
 	}
-	
-	fmt.Printf("PHP module %s removed successfully from PHP %s\n", module, version)
+
 	return nil
 }
 
 // Helper functions
 
+// configurePool points the PHP-FPM pool at the version's FastCGI socket,
+// which Caddy may connect to
+func configurePool(names pkgmgr.PHPNames) error {
+	path := filepath.Join(config.Root, names.PoolConfig)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read PHP-FPM pool configuration: %v", err)
+	}
+
+	pool := string(data)
+	for _, setting := range [][2]string{
+		{"listen", strings.TrimPrefix(names.FastCGI, "unix/")},
+		{"listen.owner", "caddy"},
+		{"listen.group", "caddy"},
+		{"listen.mode", "0660"},
+	} {
+		// Replace the setting, or the commented-out default, or add it
+		line := setting[0] + " = " + setting[1]
+		pattern := regexp.MustCompile(`(?m)^;?[ \t]*` + regexp.QuoteMeta(setting[0]) + `[ \t]*=.*$`)
+		if loc := pattern.FindStringIndex(pool); loc != nil {
+			pool = pool[:loc[0]] + line + pool[loc[1]:]
+		} else {
+			pool = strings.TrimRight(pool, "\n") + "\n" + line + "\n"
+		}
+	}
+
+	if err := utils.WriteFileAtomic(path, []byte(pool), 0644); err != nil {
+		return fmt.Errorf("failed to update PHP-FPM pool configuration: %v", err)
+	}
+	return nil
+}
+
+// phpImportPattern matches the name of a PHP configuration snippet
+var phpImportPattern = regexp.MustCompile(`^php([0-9]+\.[0-9]+)_config$`)
+
+// ImportVersion returns the PHP version of a PHP configuration snippet name
+func ImportVersion(name string) (string, bool) {
+	m := phpImportPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// ValidVersion checks that version looks like a PHP version, e.g. 8.2, as it
+// ends up in file and package names
+func ValidVersion(version string) error {
+	if _, ok := ImportVersion("php" + version + "_config"); !ok {
+		return fmt.Errorf("invalid PHP version %q", version)
+	}
+	return nil
+}
+
+// extensionPattern limits extension names to what is safe to pass on to
+// the package manager as part of a package name
+var extensionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidExtension checks that name looks like a PHP extension, e.g. mysql
+func ValidExtension(name string) error {
+	if !extensionPattern.MatchString(name) {
+		return fmt.Errorf("invalid PHP extension name %q", name)
+	}
+	return nil
+}
+
+// isPHPImport reports whether d imports a PHP configuration snippet
+func isPHPImport(d *caddyfile.Directive) bool {
+	return d.Name == "import" && len(d.Args) > 0 && phpImportPattern.MatchString(d.Args[0])
+}
+
+// phpImport returns the PHP configuration import of a site block, or nil
+func phpImport(site *caddyfile.Directive) *caddyfile.Directive {
+	for _, d := range site.Block {
+		if isPHPImport(d) {
+			return d
+		}
+	}
+	return nil
+}
+
+// Names returns the distribution specific names for a PHP version,
+// assuming Debian naming when no package manager is found
+func Names(version string) pkgmgr.PHPNames {
+	if pm, err := pkgmgr.Detect(); err == nil {
+		return pm.PHP(version)
+	}
+	return pkgmgr.Apt{}.PHP(version)
+}
+
+// writeCaddyConfig writes the Caddy snippet that routes PHP to PHP-FPM
+func writeCaddyConfig(version string, names pkgmgr.PHPNames) error {
+	snippet := caddyfile.NewBlock(fmt.Sprintf("(php%s_config)", version))
+	snippet.Append(caddyfile.NewDirective("php_fastcgi", names.FastCGI))
+	phpConfig := &caddyfile.File{Items: []*caddyfile.Directive{snippet}}
+
+	if err := os.MkdirAll(config.CaddyPHPDir, 0755); err != nil {
+		return fmt.Errorf("failed to create PHP configuration directory: %v", err)
+	}
+
+	if err := phpConfig.WriteFile(config.GetPHPConfigPath(version)); err != nil {
+		return fmt.Errorf("failed to create PHP configuration: %v", err)
+	}
+	return nil
+}
+
 // isVersionInstalled checks if a PHP version is installed
 func isVersionInstalled(version string) bool {
-	cmd := exec.Command("which", fmt.Sprintf("php%s", version))
-	if err := cmd.Run(); err == nil {
-		return true
-	}
-	return false
+	_, err := exec.LookPath(Names(version).Binary)
+	return err == nil
 }
 
-// getInstalledVersions returns a list of installed PHP versions
-func getInstalledVersions() []string {
+// SupportedVersions lists the PHP versions cliboard looks for and offers
+var SupportedVersions = pkgmgr.PHPVersions
+
+// InstalledVersions returns the installed PHP versions
+func InstalledVersions() []string {
 	var versions []string
-	
+
 	// Check common PHP versions
-	for _, ver := range []string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"} {
+	for _, ver := range SupportedVersions {
 		if isVersionInstalled(ver) {
 			versions = append(versions, ver)
 		}
 	}
-	
+
+	return versions
+}
+
+// InstallableVersions returns the supported PHP versions not installed yet
+func InstallableVersions() []string {
+	var versions []string
+	for _, ver := range SupportedVersions {
+		if !isVersionInstalled(ver) {
+			versions = append(versions, ver)
+		}
+	}
 	return versions
 }
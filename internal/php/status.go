@@ -0,0 +1,165 @@
+package php
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// StatusPath is the pm.status_path PoolStatus requests. It must be set in
+// the PHP-FPM pool configuration for the status to be available.
+const StatusPath = "/status"
+
+// PoolStatus is the status page of a PHP-FPM pool
+type PoolStatus struct {
+	Pool               string `json:"pool"`
+	ProcessManager     string `json:"process manager"`
+	StartSince         int64  `json:"start since"`
+	AcceptedConn       int64  `json:"accepted conn"`
+	ListenQueue        int64  `json:"listen queue"`
+	MaxListenQueue     int64  `json:"max listen queue"`
+	IdleProcesses      int64  `json:"idle processes"`
+	ActiveProcesses    int64  `json:"active processes"`
+	TotalProcesses     int64  `json:"total processes"`
+	MaxActiveProcesses int64  `json:"max active processes"`
+	MaxChildrenReached int64  `json:"max children reached"`
+	SlowRequests       int64  `json:"slow requests"`
+}
+
+// Socket returns the PHP-FPM socket of a version as a path on this system
+func Socket(version string) (string, bool) {
+	addr, ok := strings.CutPrefix(Names(version).FastCGI, "unix/")
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(config.Root, addr), true
+}
+
+// Status reads the status page of the PHP-FPM pool of a version over
+// FastCGI, the way a web server would request it
+func Status(version string) (PoolStatus, error) {
+	var status PoolStatus
+
+	socket, ok := Socket(version)
+	if !ok {
+		return status, fmt.Errorf("PHP %s does not listen on a unix socket", version)
+	}
+
+	body, err := fastcgiGet(socket, StatusPath, "json")
+	if err != nil {
+		return status, fmt.Errorf("failed to read PHP-FPM %s status: %v", version, err)
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return status, fmt.Errorf("failed to read PHP-FPM %s status, is pm.status_path = %s set? %v", version, StatusPath, err)
+	}
+	return status, nil
+}
+
+// FastCGI record types, see the FastCGI specification
+const (
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+	fcgiResponder    = 1
+)
+
+// fastcgiGet makes a GET request for script over a FastCGI socket and
+// returns the response body
+func fastcgiGet(socket, script, query string) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var req bytes.Buffer
+	writeRecord(&req, fcgiBeginRequest, []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0})
+
+	var params bytes.Buffer
+	for _, kv := range [][2]string{
+		{"GATEWAY_INTERFACE", "CGI/1.1"},
+		{"REQUEST_METHOD", "GET"},
+		{"SCRIPT_NAME", script},
+		{"SCRIPT_FILENAME", script},
+		{"REQUEST_URI", script + "?" + query},
+		{"QUERY_STRING", query},
+		{"SERVER_PROTOCOL", "HTTP/1.1"},
+	} {
+		writeLength(&params, len(kv[0]))
+		writeLength(&params, len(kv[1]))
+		params.WriteString(kv[0] + kv[1])
+	}
+	writeRecord(&req, fcgiParams, params.Bytes())
+	writeRecord(&req, fcgiParams, nil)
+	writeRecord(&req, fcgiStdin, nil)
+
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		content := make([]byte, length+int(header[6]))
+		if _, err := io.ReadFull(conn, content); err != nil {
+			return nil, err
+		}
+
+		switch header[1] {
+		case fcgiStdout:
+			stdout.Write(content[:length])
+		case fcgiStderr:
+			stderr.Write(content[:length])
+		}
+		if header[1] == fcgiEndRequest {
+			break
+		}
+	}
+
+	// The response is CGI style, headers followed by the body
+	reader := bufio.NewReader(&stdout)
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+
+	if status := headers.Get("Status"); status != "" && !strings.HasPrefix(status, "200") {
+		return nil, fmt.Errorf("%s %s", status, strings.TrimSpace(stderr.String()))
+	}
+	return body, nil
+}
+
+func writeRecord(w *bytes.Buffer, kind byte, content []byte) {
+	w.Write([]byte{1, kind, 0, 1})
+	binary.Write(w, binary.BigEndian, uint16(len(content)))
+	w.Write([]byte{0, 0})
+	w.Write(content)
+}
+
+// writeLength writes a FastCGI name or value length
+func writeLength(w *bytes.Buffer, n int) {
+	if n < 128 {
+		w.WriteByte(byte(n))
+		return
+	}
+	binary.Write(w, binary.BigEndian, uint32(n)|1<<31)
+}
@@ -0,0 +1,78 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Apk manages packages on Alpine Linux
+type Apk struct{}
+
+// apkNameVersion splits "php82-gd-8.2.10-r0" into name and version
+var apkNameVersion = regexp.MustCompile(`^(.+)-([0-9][^-]*-r[0-9]+)$`)
+
+func (Apk) Name() string { return "apk" }
+
+func (Apk) Install(packages ...string) error {
+	return run(nil, "apk", append([]string{"add", "--no-progress"}, packages...)...)
+}
+
+func (Apk) Remove(packages ...string) error {
+	return run(nil, "apk", append([]string{"del", "--no-progress"}, packages...)...)
+}
+
+func (Apk) Search(query string) ([]Package, error) {
+	out, err := output(nil, "apk", "search", "-v", query+"*")
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []Package
+	for _, line := range strings.Split(string(out), "\n") {
+		nameVersion, description, _ := strings.Cut(line, " - ")
+		m := apkNameVersion.FindStringSubmatch(strings.TrimSpace(nameVersion))
+		if m == nil || !strings.HasPrefix(m[1], query) {
+			continue
+		}
+		packages = append(packages, Package{Name: m[1], Version: m[2], Description: strings.TrimSpace(description)})
+	}
+	return packages, nil
+}
+
+func (Apk) IsInstalled(pkg string) bool {
+	return run(nil, "apk", "info", "-e", pkg) == nil
+}
+
+func (Apk) PHP(version string) PHPNames {
+	short := compactVersion(version)
+	return PHPNames{
+		Packages: []string{
+			"php" + short,
+			"php" + short + "-fpm",
+			"php" + short + "-common",
+		},
+		ExtensionPrefix: "php" + short + "-",
+		Service:         "php-fpm" + short,
+		Binary:          "php" + short,
+		FPMBinary:       "php-fpm" + short,
+		// Every version listens on 127.0.0.1:9000 by default, so each gets
+		// its own socket instead
+		FastCGI:    "unix//run/php-fpm" + short + ".sock",
+		PoolConfig: "/etc/php" + short + "/php-fpm.d/www.conf",
+	}
+}
+
+func (a Apk) EnsurePHPRepository(version string) error {
+	out, err := output(nil, "apk", "search", "-e", a.PHP(version).Packages[1])
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(out)) == "" {
+		return fmt.Errorf("PHP %s is not available in the configured Alpine repositories", version)
+	}
+	return nil
+}
+
+// AddCaddyRepository does nothing: Caddy ships in Alpine's community repository
+func (Apk) AddCaddyRepository() error { return nil }
@@ -0,0 +1,142 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Apt manages packages on Debian and Ubuntu. PHP versions the distribution
+// does not ship come from the Ondřej Surý repository (a PPA on Ubuntu).
+type Apt struct{}
+
+var aptEnv = []string{"DEBIAN_FRONTEND=noninteractive"}
+
+func (Apt) Name() string { return "apt" }
+
+func (Apt) Install(packages ...string) error {
+	return run(aptEnv, "apt-get", append([]string{"install", "-y", "-q"}, packages...)...)
+}
+
+func (Apt) Remove(packages ...string) error {
+	return run(aptEnv, "apt-get", append([]string{"remove", "-y", "-q"}, packages...)...)
+}
+
+func (Apt) Search(query string) ([]Package, error) {
+	out, err := output(nil, "apt-cache", "search", "--names-only", "^"+query)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []Package
+	for _, line := range strings.Split(string(out), "\n") {
+		name, description, ok := strings.Cut(line, " - ")
+		if !ok {
+			continue
+		}
+		packages = append(packages, Package{Name: strings.TrimSpace(name), Description: strings.TrimSpace(description)})
+	}
+	return packages, nil
+}
+
+func (Apt) IsInstalled(pkg string) bool {
+	out, err := output(nil, "dpkg-query", "-W", "-f=${Status}", pkg)
+	return err == nil && strings.Contains(string(out), "install ok installed")
+}
+
+func (Apt) PHP(version string) PHPNames {
+	return PHPNames{
+		Packages: []string{
+			"php" + version,
+			"php" + version + "-fpm",
+			"php" + version + "-common",
+			"php" + version + "-cli",
+		},
+		ExtensionPrefix: "php" + version + "-",
+		Service:         "php" + version + "-fpm",
+		Binary:          "php" + version,
+		FPMBinary:       "php-fpm" + version,
+		FastCGI:         "unix//run/php/php" + version + "-fpm.sock",
+	}
+}
+
+func (a Apt) EnsurePHPRepository(version string) error {
+	if a.hasCandidate("php" + version + "-fpm") {
+		return nil
+	}
+
+	release := osRelease()
+	if release["ID"] == "ubuntu" {
+		if err := a.Install("software-properties-common"); err != nil {
+			return err
+		}
+		if err := run(aptEnv, "add-apt-repository", "-y", "ppa:ondrej/php"); err != nil {
+			return fmt.Errorf("failed to add the ondrej/php PPA: %v", err)
+		}
+	} else {
+		if err := a.addSuryRepository(release["VERSION_CODENAME"]); err != nil {
+			return err
+		}
+	}
+
+	if err := run(aptEnv, "apt-get", "update", "-q"); err != nil {
+		return err
+	}
+
+	if !a.hasCandidate("php" + version + "-fpm") {
+		return fmt.Errorf("PHP %s is not available for this distribution", version)
+	}
+	return nil
+}
+
+func (a Apt) AddCaddyRepository() error {
+	if err := a.Install("debian-keyring", "debian-archive-keyring", "apt-transport-https", "curl", "gnupg"); err != nil {
+		return err
+	}
+
+	script := `curl -1sLf 'https://dl.cloudsmith.io/public/caddy/stable/gpg.key' | gpg --dearmor --yes -o /usr/share/keyrings/caddy-stable-archive-keyring.gpg
+curl -1sLf 'https://dl.cloudsmith.io/public/caddy/stable/debian.deb.txt' > /etc/apt/sources.list.d/caddy-stable.list`
+	if err := run(nil, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to add the Caddy repository: %v", err)
+	}
+
+	return run(aptEnv, "apt-get", "update", "-q")
+}
+
+// addSuryRepository adds the packages.sury.org PHP repository on Debian
+func (a Apt) addSuryRepository(codename string) error {
+	if codename == "" {
+		return fmt.Errorf("cannot determine the Debian release codename")
+	}
+
+	if err := a.Install("ca-certificates", "curl"); err != nil {
+		return err
+	}
+
+	keyring := "/usr/share/keyrings/deb.sury.org-php.gpg"
+	if err := run(nil, "curl", "-sSLo", keyring, "https://packages.sury.org/php/apt.gpg"); err != nil {
+		return fmt.Errorf("failed to download the Sury repository key: %v", err)
+	}
+
+	source := fmt.Sprintf("deb [signed-by=%s] https://packages.sury.org/php/ %s main\n", keyring, codename)
+	if err := utils.WriteFileAtomic("/etc/apt/sources.list.d/php.list", []byte(source), 0644); err != nil {
+		return fmt.Errorf("failed to add the Sury repository: %v", err)
+	}
+	return nil
+}
+
+// hasCandidate reports whether apt can install a package
+func (Apt) hasCandidate(pkg string) bool {
+	out, err := output(nil, "apt-cache", "policy", pkg)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Candidate:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Candidate:")) != "(none)"
+		}
+	}
+	return false
+}
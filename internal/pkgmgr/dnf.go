@@ -0,0 +1,111 @@
+package pkgmgr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dnf manages packages on Fedora and RHEL derivatives. PHP comes from the
+// Remi repository as software collections, e.g. php82-php-fpm.
+type Dnf struct{}
+
+func (Dnf) Name() string { return "dnf" }
+
+func (Dnf) Install(packages ...string) error {
+	return run(nil, "dnf", append([]string{"install", "-y", "-q"}, packages...)...)
+}
+
+func (Dnf) Remove(packages ...string) error {
+	return run(nil, "dnf", append([]string{"remove", "-y", "-q"}, packages...)...)
+}
+
+func (Dnf) Search(query string) ([]Package, error) {
+	out, err := output(nil, "dnf", "-q", "search", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []Package
+	for _, line := range strings.Split(string(out), "\n") {
+		nameArch, description, ok := strings.Cut(line, " : ")
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(nameArch)
+		if i := strings.LastIndex(name, "."); i > 0 {
+			name = name[:i]
+		}
+		if !strings.HasPrefix(name, query) {
+			continue
+		}
+		packages = append(packages, Package{Name: name, Description: strings.TrimSpace(description)})
+	}
+	return packages, nil
+}
+
+func (Dnf) IsInstalled(pkg string) bool {
+	return run(nil, "rpm", "-q", pkg) == nil
+}
+
+func (Dnf) PHP(version string) PHPNames {
+	scl := "php" + compactVersion(version)
+	return PHPNames{
+		Packages: []string{
+			scl + "-php",
+			scl + "-php-fpm",
+			scl + "-php-common",
+			scl + "-php-cli",
+		},
+		ExtensionPrefix: scl + "-php-",
+		Service:         scl + "-php-fpm",
+		Binary:          scl,
+		FPMBinary:       "/opt/remi/" + scl + "/root/usr/sbin/php-fpm",
+		FastCGI:         "unix//var/opt/remi/" + scl + "/run/php-fpm/www.sock",
+	}
+}
+
+func (d Dnf) EnsurePHPRepository(version string) error {
+	pkg := d.PHP(version).Service
+	if d.available(pkg) {
+		return nil
+	}
+
+	release := osRelease()
+	var url string
+	if release["ID"] == "fedora" {
+		url = "https://rpms.remirepo.net/fedora/remi-release-" + release["VERSION_ID"] + ".rpm"
+	} else {
+		major, _, _ := strings.Cut(release["VERSION_ID"], ".")
+		if major == "" {
+			return fmt.Errorf("cannot determine the distribution release")
+		}
+		if err := d.Install("epel-release"); err != nil {
+			return err
+		}
+		url = "https://rpms.remirepo.net/enterprise/remi-release-" + major + ".rpm"
+	}
+
+	if err := d.Install(url); err != nil {
+		return fmt.Errorf("failed to add the Remi repository: %v", err)
+	}
+
+	if !d.available(pkg) {
+		return fmt.Errorf("PHP %s is not available for this distribution", version)
+	}
+	return nil
+}
+
+func (d Dnf) AddCaddyRepository() error {
+	if err := d.Install("dnf-command(copr)"); err != nil {
+		return err
+	}
+	if err := run(nil, "dnf", "copr", "enable", "-y", "@caddy/caddy"); err != nil {
+		return fmt.Errorf("failed to add the Caddy repository: %v", err)
+	}
+	return nil
+}
+
+// available reports whether dnf can install a package
+func (Dnf) available(pkg string) bool {
+	return run(nil, "dnf", "-q", "list", "--available", pkg) == nil || run(nil, "rpm", "-q", pkg) == nil
+}
@@ -0,0 +1,175 @@
+// Package pkgmgr installs and queries distribution packages through apt,
+// dnf or apk, and maps PHP versions and extensions to each distribution's
+// package names.
+package pkgmgr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PackageManager installs, removes and searches distribution packages
+type PackageManager interface {
+	// Name returns the name of the package manager
+	Name() string
+
+	Install(packages ...string) error
+	Remove(packages ...string) error
+	Search(query string) ([]Package, error)
+	IsInstalled(pkg string) bool
+
+	// PHP returns the distribution specific names for a PHP version
+	PHP(version string) PHPNames
+
+	// EnsurePHPRepository makes a PHP version installable, adding a
+	// third-party repository if the distribution does not ship it
+	EnsurePHPRepository(version string) error
+
+	// AddCaddyRepository configures the repository Caddy is installed from
+	AddCaddyRepository() error
+}
+
+// Package is a package found by a search
+type Package struct {
+	Name        string
+	Version     string
+	Description string
+}
+
+// PHPNames holds the distribution specific names for a PHP version
+type PHPNames struct {
+	// Packages are the core packages installed for the version
+	Packages []string
+
+	// ExtensionPrefix is prepended to an extension name to get its package
+	ExtensionPrefix string
+
+	// Service is the name of the PHP-FPM service
+	Service string
+
+	// Binary is the name of the PHP CLI binary
+	Binary string
+
+	// FPMBinary is the PHP-FPM daemon, used to run it without an init system
+	FPMBinary string
+
+	// FastCGI is the upstream address used with php_fastcgi
+	FastCGI string
+
+	// PoolConfig is set when the default PHP-FPM pool doesn't listen on
+	// FastCGI, and is the pool configuration to point at it on install
+	PoolConfig string
+}
+
+// PHPVersions lists the PHP versions cliboard looks for and offers
+var PHPVersions = []string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"}
+
+// PHPService returns the names of the PHP version whose PHP-FPM service is
+// called service, if any
+func PHPService(pm PackageManager, service string) (PHPNames, bool) {
+	for _, version := range PHPVersions {
+		if names := pm.PHP(version); names.Service == service {
+			return names, true
+		}
+	}
+	return PHPNames{}, false
+}
+
+// ExtensionPackage returns the package that provides a PHP extension
+func (n PHPNames) ExtensionPackage(extension string) string {
+	return n.ExtensionPrefix + extension
+}
+
+// Extension returns the extension provided by a package, if it is one
+func (n PHPNames) Extension(pkg string) (string, bool) {
+	if !strings.HasPrefix(pkg, n.ExtensionPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(pkg, n.ExtensionPrefix), true
+}
+
+// Detect returns the package manager available on this host
+func Detect() (PackageManager, error) {
+	switch {
+	case hasCommand("apt-get"):
+		return Apt{}, nil
+	case hasCommand("dnf"):
+		return Dnf{}, nil
+	case hasCommand("apk"):
+		return Apk{}, nil
+	default:
+		return nil, fmt.Errorf("no supported package manager found (apt, dnf or apk)")
+	}
+}
+
+// run runs a command and includes its output in the returned error
+func run(env []string, name string, args ...string) error {
+	_, err := output(env, name, args...)
+	return err
+}
+
+// output runs a command and returns its standard output
+func output(env []string, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	if err := cmd.Run(); err != nil {
+		command := strings.Join(append([]string{name}, args...), " ")
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = lastLines(stdout.String(), 10)
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("%s: %v: %s", command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %v", command, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// osRelease returns the fields of /etc/os-release
+func osRelease() map[string]string {
+	fields := map[string]string{}
+
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return fields
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		fields[key] = strings.Trim(value, `"'`)
+	}
+	return fields
+}
+
+// compactVersion turns "8.2" into "82"
+func compactVersion(version string) string {
+	return strings.ReplaceAll(version, ".", "")
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
@@ -0,0 +1,168 @@
+// Package plugin finds and runs executables named cliboard-<name>, which
+// extend cliboard with custom subcommands the way git and kubectl plugins
+// do. Plugins are looked up in the plugins directory of the configuration
+// directory first and then on PATH.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Prefix starts the file name of every plugin
+const Prefix = "cliboard-"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Plugin is an executable providing the subcommand Name
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Installed is set for plugins in the plugins directory
+	Installed bool `json:"installed"`
+}
+
+// Dir returns the directory plugins are installed to
+func Dir() string {
+	return filepath.Join(config.ConfigDir, "plugins")
+}
+
+// List returns the plugins found, sorted by name. A plugin in the plugins
+// directory shadows one of the same name on PATH, and earlier PATH entries
+// shadow later ones.
+func List() []Plugin {
+	found := map[string]Plugin{}
+
+	dirs := []string{Dir()}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	for i, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), Prefix)
+			if !ok || !namePattern.MatchString(name) {
+				continue
+			}
+			if _, seen := found[name]; seen {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !executable(path) {
+				continue
+			}
+			found[name] = Plugin{Name: name, Path: path, Installed: i == 0}
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(found))
+	for _, p := range found {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// Install copies an executable to the plugins directory. The name defaults
+// to the file name without the cliboard- prefix.
+func Install(source, name string) (Plugin, error) {
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Base(source), Prefix)
+	}
+	if !namePattern.MatchString(name) {
+		return Plugin{}, fmt.Errorf("invalid plugin name %q, use lowercase letters, digits, - and _", name)
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return Plugin{}, fmt.Errorf("failed to read plugin: %v", err)
+	}
+
+	if err := os.MkdirAll(Dir(), 0755); err != nil {
+		return Plugin{}, fmt.Errorf("failed to create plugins directory: %v", err)
+	}
+	path := filepath.Join(Dir(), Prefix+name)
+	if err := utils.WriteFileAtomic(path, data, 0755); err != nil {
+		return Plugin{}, fmt.Errorf("failed to install plugin %s: %v", name, err)
+	}
+	// WriteFileAtomic keeps the mode of a file it replaces
+	if err := os.Chmod(path, 0755); err != nil {
+		return Plugin{}, fmt.Errorf("failed to install plugin %s: %v", name, err)
+	}
+
+	return Plugin{Name: name, Path: path, Installed: true}, nil
+}
+
+// Remove deletes a plugin from the plugins directory. Plugins elsewhere on
+// PATH belong to whoever put them there.
+func Remove(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid plugin name %q", name)
+	}
+
+	path := filepath.Join(Dir(), Prefix+name)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("plugin %s is not installed in %s", name, Dir())
+		}
+		return fmt.Errorf("failed to remove plugin %s: %v", name, err)
+	}
+	return nil
+}
+
+// Command returns the command running a plugin with args. The environment
+// describes the configuration to the plugin, see Env.
+func (p Plugin) Command(args []string, self string) *exec.Cmd {
+	cmd := exec.Command(p.Path, args...)
+	cmd.Env = append(os.Environ(), p.Env(args, self)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd
+}
+
+// Env returns the variables a plugin runs with:
+//
+//   - CLIBOARD_BIN, the running cliboard binary, self
+//   - CLIBOARD_PLUGIN, the plugin name
+//   - CLIBOARD_ROOT and every setting as its environment variable, e.g.
+//     CLIBOARD_SITES_ROOT, so cliboard run by the plugin sees the same
+//     configuration
+//   - CLIBOARD_SITE and CLIBOARD_SITE_JSON when the first argument is a
+//     site, with the site as list-sites --json describes it
+//   - CLIBOARD_API_SOCKET when the API is listening on its default socket
+func (p Plugin) Env(args []string, self string) []string {
+	env := []string{
+		"CLIBOARD_BIN=" + self,
+		"CLIBOARD_PLUGIN=" + p.Name,
+		"CLIBOARD_ROOT=" + config.Root,
+	}
+	env = append(env, config.Current().Environment()...)
+
+	if len(args) > 0 && site.ValidDomain(args[0]) {
+		if info, err := site.Get(args[0]); err == nil {
+			data, _ := json.Marshal(info)
+			env = append(env, "CLIBOARD_SITE="+info.Domain, "CLIBOARD_SITE_JSON="+string(data))
+		}
+	}
+
+	socket := filepath.Join(config.RunDir, "api.sock")
+	if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+		env = append(env, "CLIBOARD_API_SOCKET="+socket)
+	}
+	return env
+}
+
+func executable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
+}
@@ -0,0 +1,19 @@
+package service
+
+import "os/exec"
+
+// OpenRC manages services with rc-service and rc-update
+type OpenRC struct{}
+
+func (OpenRC) Name() string { return "openrc" }
+
+func (OpenRC) Start(service string) error   { return run("rc-service", service, "start") }
+func (OpenRC) Stop(service string) error    { return run("rc-service", service, "stop") }
+func (OpenRC) Restart(service string) error { return run("rc-service", service, "restart") }
+func (OpenRC) Reload(service string) error  { return run("rc-service", service, "reload") }
+func (OpenRC) Enable(service string) error  { return run("rc-update", "add", service, "default") }
+func (OpenRC) Disable(service string) error { return run("rc-update", "del", service, "default") }
+
+func (OpenRC) IsActive(service string) bool {
+	return exec.Command("rc-service", service, "status").Run() == nil
+}
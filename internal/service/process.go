@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/pkgmgr"
+)
+
+// Process supervises services as plain background processes tracked by
+// pid files. It is used on hosts without an init system, such as containers.
+type Process struct {
+	PidDir string
+	LogDir string
+}
+
+// processDefinition describes how to run a service in the foreground and
+// how to reload it: with a command if set, otherwise with a signal
+type processDefinition struct {
+	command       []string
+	reloadCommand []string
+	reloadSignal  syscall.Signal
+}
+
+// NewProcess returns a process supervisor using cliboard's runtime directories
+func NewProcess() Process {
+	return Process{PidDir: config.RunDir, LogDir: config.LogDir}
+}
+
+func (p Process) Name() string { return "none" }
+
+func (p Process) Start(service string) error {
+	if p.IsActive(service) {
+		return nil
+	}
+
+	def, err := p.definition(service)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{p.PidDir, p.LogDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", dir, err)
+		}
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(p.LogDir, service+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open log for %s: %v", service, err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(def.command[0], def.command[1:]...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", service, err)
+	}
+
+	if err := os.WriteFile(p.pidFile(service), []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write pid file for %s: %v", service, err)
+	}
+
+	// Give the process a moment to fail on bad configuration
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		os.Remove(p.pidFile(service))
+		return fmt.Errorf("%s exited right after starting (%v), see %s", service, err, logFile.Name())
+	case <-time.After(500 * time.Millisecond):
+		return nil
+	}
+}
+
+func (p Process) Stop(service string) error {
+	pid, ok := p.pid(service)
+	if !ok {
+		return nil
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("failed to stop %s: %v", service, err)
+	}
+
+	for i := 0; i < 50 && processAlive(pid); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if processAlive(pid) {
+		return fmt.Errorf("%s (pid %d) did not stop", service, pid)
+	}
+
+	os.Remove(p.pidFile(service))
+	return nil
+}
+
+func (p Process) Restart(service string) error {
+	if err := p.Stop(service); err != nil {
+		return err
+	}
+	return p.Start(service)
+}
+
+func (p Process) Reload(service string) error {
+	pid, ok := p.pid(service)
+	if !ok {
+		return fmt.Errorf("%s is not running", service)
+	}
+
+	def, err := p.definition(service)
+	if err != nil {
+		return err
+	}
+
+	if len(def.reloadCommand) > 0 {
+		out, err := exec.Command(def.reloadCommand[0], def.reloadCommand[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to reload %s: %v: %s", service, err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	if err := syscall.Kill(pid, def.reloadSignal); err != nil {
+		return fmt.Errorf("failed to reload %s: %v", service, err)
+	}
+	return nil
+}
+
+// Enable does nothing: without an init system there is no boot sequence
+func (p Process) Enable(service string) error { return nil }
+
+// Disable does nothing: without an init system there is no boot sequence
+func (p Process) Disable(service string) error { return nil }
+
+func (p Process) IsActive(service string) bool {
+	pid, ok := p.pid(service)
+	return ok && processAlive(pid)
+}
+
+func (p Process) definition(service string) (processDefinition, error) {
+	if service == "caddy" {
+		// Caddy ignores signals for reloading; it loads new configuration
+		// through its admin endpoint, which caddy reload finds in the config
+		caddyfile := filepath.Join(config.CaddyRootDir, "Caddyfile")
+		return processDefinition{
+			command:       []string{"caddy", "run", "--config", caddyfile, "--adapter", "caddyfile"},
+			reloadCommand: []string{"caddy", "reload", "--config", caddyfile, "--adapter", "caddyfile"},
+		}, nil
+	}
+
+	// PHP-FPM services are named differently by every distribution
+	pm, err := pkgmgr.Detect()
+	if err != nil {
+		pm = pkgmgr.Apt{}
+	}
+	if names, ok := pkgmgr.PHPService(pm, service); ok {
+		return processDefinition{
+			command:      []string{names.FPMBinary, "--nodaemonize"},
+			reloadSignal: syscall.SIGUSR2,
+		}, nil
+	}
+
+	return processDefinition{}, fmt.Errorf("don't know how to run service %s without an init system", service)
+}
+
+func (p Process) pidFile(service string) string {
+	return filepath.Join(p.PidDir, service+".pid")
+}
+
+func (p Process) pid(service string) (int, bool) {
+	data, err := os.ReadFile(p.pidFile(service))
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// processAlive reports whether a process with the given pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Runit manages services with sv. Services are enabled by linking their
+// definition from SvDir into ServiceDir.
+type Runit struct {
+	SvDir      string
+	ServiceDir string
+}
+
+// NewRunit returns a runit manager using the directories present on this host
+func NewRunit() Runit {
+	r := Runit{SvDir: "/etc/sv", ServiceDir: "/etc/service"}
+	for _, dir := range []string{"/etc/service", "/var/service", "/etc/runit/runsvdir/default"} {
+		if isDir(dir) {
+			r.ServiceDir = dir
+			break
+		}
+	}
+	return r
+}
+
+func (r Runit) Name() string { return "runit" }
+
+func (r Runit) Start(service string) error   { return run("sv", "start", service) }
+func (r Runit) Stop(service string) error    { return run("sv", "stop", service) }
+func (r Runit) Restart(service string) error { return run("sv", "restart", service) }
+func (r Runit) Reload(service string) error  { return run("sv", "reload", service) }
+
+func (r Runit) Enable(service string) error {
+	definition := filepath.Join(r.SvDir, service)
+	if !isDir(definition) {
+		return fmt.Errorf("runit service %s does not exist in %s", service, r.SvDir)
+	}
+
+	link := filepath.Join(r.ServiceDir, service)
+	if _, err := os.Lstat(link); err == nil {
+		return nil
+	}
+	if err := os.Symlink(definition, link); err != nil {
+		return fmt.Errorf("failed to enable runit service %s: %v", service, err)
+	}
+	return nil
+}
+
+func (r Runit) Disable(service string) error {
+	link := filepath.Join(r.ServiceDir, service)
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to disable runit service %s: %v", service, err)
+	}
+	return nil
+}
+
+func (r Runit) IsActive(service string) bool {
+	output, err := exec.Command("sv", "status", service).Output()
+	return err == nil && strings.HasPrefix(string(output), "run:")
+}
@@ -0,0 +1,88 @@
+// Package service starts, stops and reloads system services through
+// whichever init system the host runs.
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ServiceManager controls system services
+type ServiceManager interface {
+	// Name returns the name of the init system
+	Name() string
+
+	Start(service string) error
+	Stop(service string) error
+	Restart(service string) error
+	Reload(service string) error
+
+	// Enable and Disable control whether a service starts at boot
+	Enable(service string) error
+	Disable(service string) error
+
+	// IsActive reports whether a service is running
+	IsActive(service string) bool
+}
+
+// Detect returns the service manager for the init system of this host
+func Detect() ServiceManager {
+	switch {
+	case isDir("/run/systemd/system"):
+		return Systemd{}
+	case isDir("/run/openrc") && hasCommand("rc-service"):
+		return OpenRC{}
+	case hasCommand("sv") && (isDir("/etc/runit") || isDir("/run/runit")):
+		return NewRunit()
+	default:
+		return NewProcess()
+	}
+}
+
+// ByName returns the service manager with the given name
+func ByName(name string) (ServiceManager, error) {
+	switch name {
+	case "", "auto":
+		return Detect(), nil
+	case "systemd":
+		return Systemd{}, nil
+	case "openrc":
+		return OpenRC{}, nil
+	case "runit":
+		return NewRunit(), nil
+	case "none", "process":
+		return NewProcess(), nil
+	default:
+		return nil, fmt.Errorf("unknown service manager %s", name)
+	}
+}
+
+// run runs a command and includes its output in the returned error
+func run(name string, args ...string) error {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		command := strings.Join(append([]string{name}, args...), " ")
+		if msg := strings.TrimSpace(output.String()); msg != "" {
+			return fmt.Errorf("%s: %v: %s", command, err, msg)
+		}
+		return fmt.Errorf("%s: %v", command, err)
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
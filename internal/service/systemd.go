@@ -0,0 +1,19 @@
+package service
+
+import "os/exec"
+
+// Systemd manages services with systemctl
+type Systemd struct{}
+
+func (Systemd) Name() string { return "systemd" }
+
+func (Systemd) Start(service string) error   { return run("systemctl", "start", service) }
+func (Systemd) Stop(service string) error    { return run("systemctl", "stop", service) }
+func (Systemd) Restart(service string) error { return run("systemctl", "restart", service) }
+func (Systemd) Reload(service string) error  { return run("systemctl", "reload", service) }
+func (Systemd) Enable(service string) error  { return run("systemctl", "enable", service) }
+func (Systemd) Disable(service string) error { return run("systemctl", "disable", service) }
+
+func (Systemd) IsActive(service string) bool {
+	return exec.Command("systemctl", "is-active", "--quiet", service).Run() == nil
+}
@@ -0,0 +1,75 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SetBasicAuth requires a user name and password for every request to a
+// site, replacing any user set before. The password is stored as a bcrypt
+// hash.
+func SetBasicAuth(domain, username, password string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	if username == "" || strings.ContainsAny(username, " \t\"{}#") {
+		return fmt.Errorf("invalid user name %q", username)
+	}
+	if password == "" {
+		return fmt.Errorf("the password must not be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	configPath := config.GetSiteConfigPath(domain)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return fmt.Errorf("site %s does not exist", domain)
+	}
+	if err := checkNotSuspended(domain); err != nil {
+		return err
+	}
+
+	// basic_auth {
+	//     <user> <hash>
+	// }
+	err = caddyfile.EditSite(configPath, domain, func(site *caddyfile.Directive) error {
+		auth := caddyfile.NewBlock("basic_auth")
+		auth.Append(caddyfile.NewDirective(username, string(hash)))
+		if current := site.First("basic_auth"); current != nil {
+			current.Args, current.HasBlock, current.Block = nil, true, auth.Block
+			return nil
+		}
+
+		i := 0
+		for i < len(site.Block) && site.Block[i].Name == "import" {
+			i++
+		}
+		site.Insert(i, auth)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reload Caddy to apply changes
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+var (
+	envNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	cronUserPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$`)
+)
+
+// CronJob is a command run on a schedule from the site directory
+type CronJob struct {
+	Schedule string `json:"schedule" yaml:"schedule"`
+	Command  string `json:"command" yaml:"command"`
+	// User defaults to root
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+}
+
+// Validate checks that the job can be written to a cron file
+func (j CronJob) Validate() error {
+	fields := strings.Fields(j.Schedule)
+	switch {
+	case len(fields) == 1 && strings.HasPrefix(fields[0], "@"):
+	case len(fields) == 5:
+	default:
+		return fmt.Errorf("invalid cron schedule %q, expected five fields or an @keyword", j.Schedule)
+	}
+	if strings.TrimSpace(j.Command) == "" || strings.ContainsAny(j.Command, "\r\n") {
+		return fmt.Errorf("invalid cron command %q", j.Command)
+	}
+	if j.User != "" && !cronUserPattern.MatchString(j.User) {
+		return fmt.Errorf("invalid cron user %q", j.User)
+	}
+	return nil
+}
+
+// ValidateEnv checks that variables can be written to a cron file
+func ValidateEnv(env map[string]string) error {
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("environment variable %s must not contain line breaks", name)
+		}
+	}
+	return nil
+}
+
+// Cron returns the cron jobs of a site and the environment they run with
+func Cron(domain string) ([]CronJob, map[string]string, error) {
+	if err := checkDomain(domain); err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(cronPath(domain))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read cron jobs: %v", err)
+	}
+
+	var jobs []CronJob
+	env := map[string]string{}
+	prefix := cronPrefix(domain)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if name, value, ok := strings.Cut(line, "="); ok && envNamePattern.MatchString(name) {
+			env[name] = value
+			continue
+		}
+
+		fields := strings.Fields(line)
+		n := 5
+		if strings.HasPrefix(fields[0], "@") {
+			n = 1
+		}
+		if len(fields) <= n+1 {
+			continue
+		}
+		rest := strings.TrimSpace(strings.SplitN(line, " ", n+1)[n])
+		user, command, _ := strings.Cut(rest, " ")
+		command = strings.TrimPrefix(strings.TrimSpace(command), prefix)
+		if user == "root" {
+			user = ""
+		}
+		jobs = append(jobs, CronJob{
+			Schedule: strings.Join(fields[:n], " "),
+			Command:  strings.ReplaceAll(command, `\%`, "%"),
+			User:     user,
+		})
+	}
+
+	if len(env) == 0 {
+		env = nil
+	}
+	return jobs, env, nil
+}
+
+// SetCron replaces the cron jobs of a site and their environment. The file
+// is removed when both are empty.
+func SetCron(domain string, jobs []CronJob, env map[string]string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err := job.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := ValidateEnv(env); err != nil {
+		return err
+	}
+
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if _, err := os.Stat(config.GetSiteConfigPath(domain)); os.IsNotExist(err) {
+		return fmt.Errorf("site %s does not exist", domain)
+	}
+
+	path := cronPath(domain)
+	if len(jobs) == 0 && len(env) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cron jobs: %v", err)
+		}
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# CLIBoard cron jobs for %s\n", domain)
+
+	// Variables at the top of a cron file apply to every job in it
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, env[name])
+	}
+
+	prefix := cronPrefix(domain)
+	for _, job := range jobs {
+		user := job.User
+		if user == "" {
+			user = "root"
+		}
+		// cron turns unescaped % into newlines
+		command := strings.ReplaceAll(job.Command, "%", `\%`)
+		fmt.Fprintf(&b, "%s %s %s%s\n", strings.Join(strings.Fields(job.Schedule), " "), user, prefix, command)
+	}
+
+	if err := os.MkdirAll(config.CronDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cron directory: %v", err)
+	}
+	if err := utils.WriteFileAtomic(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write cron jobs: %v", err)
+	}
+	return nil
+}
+
+// cronPath returns the cron file holding the jobs of a site
+func cronPath(domain string) string {
+	return config.GetCronPath("cliboard-site-" + domain)
+}
+
+// cronPrefix is put in front of every command so jobs run in the site
+// directory
+func cronPrefix(domain string) string {
+	return "cd " + config.Target(config.GetSiteDirectory(domain)) + " && "
+}
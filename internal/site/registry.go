@@ -0,0 +1,128 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/php"
+)
+
+var domainPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+// Info describes a site as configured on disk
+type Info struct {
+	Domain     string   `json:"domain"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Template   string   `json:"template"`
+	Directory  string   `json:"directory"`
+	Webroot    string   `json:"webroot"`
+	PHPVersion string   `json:"php_version,omitempty"`
+	Modules    []string `json:"modules"`
+	Backup     bool     `json:"backup"`
+	Suspended  bool     `json:"suspended,omitempty"`
+}
+
+// ValidDomain reports whether domain is safe to use as a site name, which
+// also becomes a directory and file name
+func ValidDomain(domain string) bool {
+	return len(domain) <= 253 && domainPattern.MatchString(domain)
+}
+
+// checkDomain returns an error for domains ValidDomain rejects, so that no
+// mutator builds paths from them
+func checkDomain(domain string) error {
+	if !ValidDomain(domain) {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	return nil
+}
+
+// List returns every site that has a Caddy configuration, sorted by domain
+func List() ([]Info, error) {
+	entries, err := os.ReadDir(config.CaddySitesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read sites directory: %v", err)
+	}
+
+	var domains []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".caddy") {
+			continue
+		}
+
+		// A file added by hand, such as a wildcard site, is served by Caddy
+		// but can't be managed here, and mustn't hide the other sites
+		if domain := strings.TrimSuffix(name, ".caddy"); ValidDomain(domain) {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+
+	sites := make([]Info, 0, len(domains))
+	for _, domain := range domains {
+		info, err := Get(domain)
+		if err != nil {
+			return nil, err
+		}
+		sites = append(sites, info)
+	}
+	return sites, nil
+}
+
+// Get returns the configuration of a single site
+func Get(domain string) (Info, error) {
+	if err := checkDomain(domain); err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		Domain:    domain,
+		Directory: config.GetSiteDirectory(domain),
+		Modules:   []string{},
+		Backup:    backup.SiteEnabled(domain),
+	}
+
+	if _, err := os.Stat(config.GetSiteConfigPath(domain)); os.IsNotExist(err) {
+		return info, fmt.Errorf("site %s does not exist", domain)
+	}
+
+	// A suspended site is described by the configuration set aside for it
+	configPath, suspended := activeConfigPath(domain)
+	site, err := caddyfile.ReadSite(configPath, domain)
+	if err != nil {
+		return info, err
+	}
+
+	for _, key := range site.Keys() {
+		if key != domain {
+			info.Aliases = append(info.Aliases, key)
+		}
+	}
+	info.Template = siteTemplate(site)
+	info.Suspended = suspended
+
+	if root := site.First("root"); root != nil && len(root.Args) > 0 {
+		info.Webroot = filepath.Join(config.Root, caddyfile.Unquote(root.Args[len(root.Args)-1]))
+	}
+
+	for _, name := range site.Imports() {
+		if version, ok := php.ImportVersion(name); ok {
+			info.PHPVersion = version
+			continue
+		}
+		info.Modules = append(info.Modules, name)
+	}
+
+	return info, nil
+}
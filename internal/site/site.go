@@ -7,34 +7,77 @@ import (
 	"strings"
 
 	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
 	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/hook"
+	"github.com/doko89/cliboard/internal/lock"
 	"github.com/doko89/cliboard/internal/utils"
 )
 
-// Create creates a new site with the given domain
+// Create creates a new site with the given domain from the default template
 func Create(domain string) error {
+	return CreateFromTemplate(domain, DefaultTemplate)
+}
+
+// CreateFromTemplate creates a new site with the given domain, starting from
+// a template
+func CreateFromTemplate(domain, template string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	tmpl, err := GetTemplate(template)
+	if err != nil {
+		return err
+	}
+	for _, name := range tmpl.Modules {
+		if _, err := os.Stat(config.GetModulePath(name)); os.IsNotExist(err) {
+			return fmt.Errorf("template %s needs module %s, which does not exist", tmpl.Name, name)
+		}
+	}
+
+	return hook.Around(hook.CreateSite, domain, map[string]string{"template": tmpl.Name}, func() error {
+		return create(domain, tmpl)
+	})
+}
+
+// create creates a site from a template
+func create(domain string, tmpl Template) error {
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Refuse to overwrite an existing site
+	configPath := config.GetSiteConfigPath(domain)
+	if _, err := os.Stat(configPath); err == nil {
+		return fmt.Errorf("site %s already exists", domain)
+	}
+
 	// Create site directory
 	siteDir := config.GetSiteDirectory(domain)
 	if err := os.MkdirAll(siteDir, 0755); err != nil {
 		return fmt.Errorf("failed to create site directory: %v", err)
 	}
 
-	// Create a sample index.html
-	indexPath := filepath.Join(siteDir, "index.html")
-	indexContent := fmt.Sprintf("<html><body><h1>Welcome to %s</h1><p>Site created with CLIBoard</p></body></html>", domain)
-	if err := os.WriteFile(indexPath, []byte(indexContent), 0644); err != nil {
-		return fmt.Errorf("failed to create index.html: %v", err)
+	// Create a sample index page
+	indexPath := filepath.Join(siteDir, tmpl.index)
+	indexContent := fmt.Sprintf(tmpl.content, domain)
+	if err := utils.WriteFileAtomic(indexPath, []byte(indexContent), 0644); err != nil {
+		return fmt.Errorf("failed to create %s: %v", tmpl.index, err)
 	}
 
 	// Create Caddy configuration
-	caddyConfig := fmt.Sprintf(`%s {
-    root * %s
-    file_server
-}
-`, domain, siteDir)
+	siteBlock := caddyfile.NewBlock(domain)
+	siteBlock.Comments = []string{templatePrefix + " " + tmpl.Name}
+	for _, name := range tmpl.Modules {
+		siteBlock.AddImport(name)
+	}
+	siteBlock.Append(caddyfile.NewDirective("root", "*", caddyfile.Quote(config.Target(siteDir))))
+	siteBlock.Append(caddyfile.NewDirective("file_server"))
 
-	configPath := config.GetSiteConfigPath(domain)
-	if err := os.WriteFile(configPath, []byte(caddyConfig), 0644); err != nil {
+	caddyConfig := &caddyfile.File{Items: []*caddyfile.Directive{siteBlock}}
+	if err := caddyConfig.WriteFile(configPath); err != nil {
 		return fmt.Errorf("failed to create site configuration: %v", err)
 	}
 
@@ -43,34 +86,45 @@ func Create(domain string) error {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
-	fmt.Printf("Site %s created successfully\n", domain)
 	return nil
 }
 
-// Delete removes an existing site
-func Delete(domain string) error {
+// Remove deletes a site, its files and its configuration
+func Remove(domain string) error {
+	return hook.Around(hook.DeleteSite, domain, nil, func() error { return remove(domain) })
+}
+
+func remove(domain string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check if site exists
 	siteDir := config.GetSiteDirectory(domain)
 	if _, err := os.Stat(siteDir); os.IsNotExist(err) {
 		return fmt.Errorf("site %s does not exist", domain)
 	}
 
-	// Ask for confirmation
-	confirmed := utils.AskForConfirmation(fmt.Sprintf("Are you sure you want to delete site %s?", domain))
-	if !confirmed {
-		fmt.Println("Site deletion cancelled")
-		return nil
-	}
-
 	// Remove site directory
 	if err := os.RemoveAll(siteDir); err != nil {
 		return fmt.Errorf("failed to remove site directory: %v", err)
 	}
 
-	// Remove Caddy configuration
-	configPath := config.GetSiteConfigPath(domain)
-	if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove site configuration: %v", err)
+	// Remove Caddy configuration, including any set aside by Suspend
+	for _, configPath := range []string{config.GetSiteConfigPath(domain), config.GetSuspendedSiteConfigPath(domain)} {
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove site configuration: %v", err)
+		}
+	}
+
+	// Remove the site's cron jobs
+	if err := os.Remove(cronPath(domain)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cron jobs: %v", err)
 	}
 
 	// Reload Caddy to apply changes
@@ -78,17 +132,28 @@ func Delete(domain string) error {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
-	fmt.Printf("Site %s deleted successfully\n", domain)
 	return nil
 }
 
 // UpdateWebroot updates the webroot path for a site
 func UpdateWebroot(domain, path string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check if site exists
 	siteDir := config.GetSiteDirectory(domain)
 	if _, err := os.Stat(siteDir); os.IsNotExist(err) {
 		return fmt.Errorf("site %s does not exist", domain)
 	}
+	if err := checkNotSuspended(domain); err != nil {
+		return err
+	}
 
 	// Make sure path starts with a slash
 	if !strings.HasPrefix(path, "/") {
@@ -101,21 +166,79 @@ func UpdateWebroot(domain, path string) error {
 		return fmt.Errorf("failed to create webroot directory: %v", err)
 	}
 
-	// Update Caddy configuration
+	// Update the root directive
 	configPath := config.GetSiteConfigPath(domain)
-	config, err := os.ReadFile(configPath)
+	webroot := caddyfile.Quote(config.Target(newWebroot))
+	err = caddyfile.EditSite(configPath, domain, func(site *caddyfile.Directive) error {
+		root := site.First("root")
+		switch {
+		case root == nil:
+			site.Append(caddyfile.NewDirective("root", "*", webroot))
+		case len(root.Args) == 0:
+			root.Args = []string{"*", webroot}
+		default:
+			// The path is always the last argument, after any matcher
+			root.Args[len(root.Args)-1] = webroot
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read site configuration: %v", err)
+		return err
 	}
 
-	// Update the root directive
-	updatedConfig := strings.Replace(string(config), 
-		fmt.Sprintf("root * %s", siteDir), 
-		fmt.Sprintf("root * %s", newWebroot), 
-		1)
+	// Reload Caddy to apply changes
+	if err := caddy.Reload(); err != nil {
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
 
-	if err := os.WriteFile(configPath, []byte(updatedConfig), 0644); err != nil {
-		return fmt.Errorf("failed to update site configuration: %v", err)
+	return nil
+}
+
+// SetAliases replaces the additional addresses a site is served on
+func SetAliases(domain string, aliases []string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		if !ValidDomain(alias) {
+			return fmt.Errorf("invalid alias %q", alias)
+		}
+		if alias == domain {
+			return fmt.Errorf("alias %s is the domain of the site", alias)
+		}
+	}
+
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	configPath := config.GetSiteConfigPath(domain)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return fmt.Errorf("site %s does not exist", domain)
+	}
+	if err := checkNotSuspended(domain); err != nil {
+		return err
+	}
+
+	// Addresses are separated by commas: example.com, www.example.com {
+	err = caddyfile.EditSite(configPath, domain, func(site *caddyfile.Directive) error {
+		if len(aliases) == 0 {
+			site.Name, site.Args = domain, nil
+			return nil
+		}
+		site.Name, site.Args = domain+",", nil
+		for i, alias := range aliases {
+			if i < len(aliases)-1 {
+				alias += ","
+			}
+			site.Args = append(site.Args, alias)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Reload Caddy to apply changes
@@ -123,6 +246,5 @@ func UpdateWebroot(domain, path string) error {
 		return fmt.Errorf("failed to reload Caddy: %v", err)
 	}
 
-	fmt.Printf("Webroot for site %s updated to %s successfully\n", domain, newWebroot)
 	return nil
 }
@@ -0,0 +1,148 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/caddyfile"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/lock"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// suspendedComment marks the placeholder block that serves a suspended site:
+//
+//	# cliboard:suspended
+//	example.com {
+//	    handle {
+//	        respond "Site suspended" 503
+//	    }
+//	}
+const suspendedComment = "# cliboard:suspended"
+
+// Suspend makes a site answer every request with 503 Service Unavailable,
+// keeping its files and configuration. The site's configuration is moved
+// out of sites.d and replaced by a block that does nothing but respond, so
+// none of its handlers, routes or imported modules stay in service.
+func Suspend(domain string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	configPath := config.GetSiteConfigPath(domain)
+	suspendedPath := config.GetSuspendedSiteConfigPath(domain)
+	if utils.FileExists(suspendedPath) {
+		return fmt.Errorf("site %s is already suspended", domain)
+	}
+
+	original, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("site %s does not exist", domain)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read site configuration: %v", err)
+	}
+	site, err := caddyfile.ReadSite(configPath, domain)
+	if err != nil {
+		return err
+	}
+
+	// Answer on the same addresses, with nothing but the 503
+	placeholder := caddyfile.NewBlock(site.Name, site.Args...)
+	placeholder.Comments = []string{suspendedComment}
+	handle := caddyfile.NewBlock("handle")
+	handle.Append(caddyfile.NewDirective("respond", caddyfile.Quote("Site suspended"), "503"))
+	placeholder.Append(handle)
+
+	if err := os.MkdirAll(filepath.Dir(suspendedPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for suspended sites: %v", err)
+	}
+	if err := utils.WriteFileAtomic(suspendedPath, original, 0644); err != nil {
+		return fmt.Errorf("failed to keep site configuration: %v", err)
+	}
+	placeholderFile := &caddyfile.File{Items: []*caddyfile.Directive{placeholder}}
+	if err := placeholderFile.WriteFile(configPath); err != nil {
+		os.Remove(suspendedPath)
+		return fmt.Errorf("failed to update site configuration: %v", err)
+	}
+
+	if err := caddy.Reload(); err != nil {
+		utils.WriteFileAtomic(configPath, original, 0644)
+		os.Remove(suspendedPath)
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+	return nil
+}
+
+// Resume serves a suspended site again from the configuration it had
+func Resume(domain string) error {
+	if err := checkDomain(domain); err != nil {
+		return err
+	}
+
+	release, err := lock.Site(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	configPath := config.GetSiteConfigPath(domain)
+	suspendedPath := config.GetSuspendedSiteConfigPath(domain)
+	if !utils.FileExists(configPath) {
+		return fmt.Errorf("site %s does not exist", domain)
+	}
+
+	original, err := os.ReadFile(suspendedPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("site %s is not suspended", domain)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read suspended site configuration: %v", err)
+	}
+	placeholder, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read site configuration: %v", err)
+	}
+
+	if err := utils.WriteFileAtomic(configPath, original, 0644); err != nil {
+		return fmt.Errorf("failed to restore site configuration: %v", err)
+	}
+	if err := os.Remove(suspendedPath); err != nil {
+		utils.WriteFileAtomic(configPath, placeholder, 0644)
+		return fmt.Errorf("failed to remove suspended site configuration: %v", err)
+	}
+
+	// Stay suspended if Caddy no longer accepts the original configuration
+	if err := caddy.Reload(); err != nil {
+		utils.WriteFileAtomic(suspendedPath, original, 0644)
+		utils.WriteFileAtomic(configPath, placeholder, 0644)
+		return fmt.Errorf("failed to reload Caddy: %v", err)
+	}
+	return nil
+}
+
+// activeConfigPath returns the path holding the site's own configuration,
+// which is set aside while the site is suspended
+func activeConfigPath(domain string) (path string, suspended bool) {
+	if path := config.GetSuspendedSiteConfigPath(domain); utils.FileExists(path) {
+		return path, true
+	}
+	return config.GetSiteConfigPath(domain), false
+}
+
+// checkNotSuspended refuses changes to a suspended site, whose configuration
+// isn't in sites.d
+func checkNotSuspended(domain string) error {
+	if utils.FileExists(config.GetSuspendedSiteConfigPath(domain)) {
+		return fmt.Errorf("site %s is suspended, resume it first", domain)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/caddyfile"
+)
+
+// DefaultTemplate is used for sites created without a template and for
+// sites created before templates existed
+const DefaultTemplate = "static"
+
+// templatePrefix marks the template a site was created from in the comments
+// above its site block:
+//
+//	# cliboard:template <name>
+const templatePrefix = "# cliboard:template"
+
+// Template is a starting point for a new site: the sample page it gets and
+// the modules its configuration imports
+type Template struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Modules are imported by the site configuration
+	Modules []string `json:"modules,omitempty"`
+
+	index   string
+	content string
+}
+
+var templates = []Template{
+	{
+		Name:        "static",
+		Description: "static files served as they are",
+		index:       "index.html",
+		content:     "<html><body><h1>Welcome to %s</h1><p>Site created with CLIBoard</p></body></html>",
+	},
+	{
+		Name:        "spa",
+		Description: "single-page application, unknown paths serve index.html",
+		index:       "index.html",
+		content:     "<html><body><h1>Welcome to %s</h1><p>Single-page application created with CLIBoard</p></body></html>",
+		Modules:     []string{"spa"},
+	},
+	{
+		Name:        "php",
+		Description: "PHP application with an index.php entry point",
+		index:       "index.php",
+		content:     "<?php\necho '<h1>Welcome to %s</h1><p>PHP ' . PHP_VERSION . ' site created with CLIBoard</p>';\n",
+	},
+}
+
+// Templates returns the templates sites can be created from
+func Templates() []Template {
+	return append([]Template(nil), templates...)
+}
+
+// GetTemplate returns the template with the given name
+func GetTemplate(name string) (Template, error) {
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.Name
+	}
+	return Template{}, fmt.Errorf("unknown template %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// siteTemplate returns the template recorded above a site block
+func siteTemplate(site *caddyfile.Directive) string {
+	for _, c := range site.Comments {
+		if fields := strings.Fields(strings.TrimPrefix(c, templatePrefix)); strings.HasPrefix(c, templatePrefix) && len(fields) == 1 {
+			return fields[0]
+		}
+	}
+	return DefaultTemplate
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"path/filepath"
+
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+)
+
+// Export describes the sites currently configured on the server
+func Export() (File, error) {
+	infos, err := site.List()
+	if err != nil {
+		return File{}, err
+	}
+
+	// Extensions are per PHP version, so look each version up once
+	extensions := map[string][]string{}
+
+	f := File{Sites: []Site{}}
+	for _, info := range infos {
+		s := Site{
+			Domain:   info.Domain,
+			Aliases:  info.Aliases,
+			Template: info.Template,
+			Modules:  info.Modules,
+			Backup:   info.Backup,
+		}
+
+		if rel, err := filepath.Rel(info.Directory, info.Webroot); err == nil && rel != "." {
+			s.Webroot = "/" + filepath.ToSlash(rel)
+		}
+
+		if info.PHPVersion != "" {
+			exts, ok := extensions[info.PHPVersion]
+			if !ok {
+				exts, _ = php.InstalledModules(info.PHPVersion)
+				extensions[info.PHPVersion] = exts
+			}
+			s.PHP = &PHP{Version: info.PHPVersion, Extensions: exts}
+		}
+
+		if s.Cron, s.Env, err = site.Cron(info.Domain); err != nil {
+			return File{}, err
+		}
+
+		f.Sites = append(f.Sites, s)
+	}
+	return f, nil
+}
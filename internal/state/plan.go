@@ -0,0 +1,503 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/utils"
+)
+
+// Kind says whether a change adds, updates or removes something
+type Kind string
+
+const (
+	Add    Kind = "add"
+	Update Kind = "update"
+	Remove Kind = "remove"
+)
+
+// Change is one step towards the desired state
+type Change struct {
+	// Site is empty for changes to the whole server, such as installing PHP
+	Site        string `json:"site,omitempty"`
+	Kind        Kind   `json:"kind"`
+	Description string `json:"description"`
+
+	apply func() error
+	// undo reverts the change if a later one fails. It is nil for changes
+	// that need no undoing, or that can't be undone.
+	undo func() error
+	// destructive changes delete data and only run once the new
+	// configuration has been validated
+	destructive bool
+}
+
+// Plan is the list of changes that reconcile the server with a state file
+type Plan struct {
+	Changes []Change `json:"changes"`
+	// Unmanaged sites exist on the server but not in the file. They are only
+	// deleted when pruning.
+	Unmanaged []string `json:"unmanaged,omitempty"`
+	// Notes are differences that apply does not change
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Empty reports whether the server already matches the file
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Add appends a change that is not part of the state file, such as a step of
+// creating a site that the file does not describe. It runs after the changes
+// before it and before Caddy is reloaded. undo, if set, reverts it when a
+// later change fails.
+func (p *Plan) Add(site string, kind Kind, description string, apply, undo func() error) {
+	p.Changes = append(p.Changes, Change{Site: site, Kind: kind, Description: description, apply: apply, undo: undo})
+}
+
+// NewPlan compares the desired state with the server. Sites missing from the
+// file are deleted, with their files, only when prune is set.
+func NewPlan(desired File, prune bool) (*Plan, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	infos, err := site.List()
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]site.Info{}
+	for _, info := range infos {
+		current[info.Domain] = info
+	}
+
+	available, err := module.Available()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{Changes: []Change{}}
+	wanted := map[string]bool{}
+	for _, s := range desired.Sites {
+		wanted[s.Domain] = true
+	}
+
+	// A new alias must not steal the address of a site that stays
+	for _, info := range infos {
+		if wanted[info.Domain] || prune {
+			continue
+		}
+		p.Unmanaged = append(p.Unmanaged, info.Domain)
+		for _, s := range desired.Sites {
+			for _, alias := range s.Aliases {
+				if alias == info.Domain || contains(info.Aliases, alias) {
+					return nil, fmt.Errorf("alias %s of site %s is served by site %s, which is not in the file", alias, s.Domain, info.Domain)
+				}
+			}
+		}
+	}
+
+	p.planPHP(desired)
+
+	for _, s := range desired.Sites {
+		if err := p.planSite(s, current, available); err != nil {
+			return nil, err
+		}
+	}
+
+	if prune {
+		for _, info := range infos {
+			if wanted[info.Domain] {
+				continue
+			}
+			domain := info.Domain
+			p.Changes = append(p.Changes, Change{
+				Site: domain, Kind: Remove, Description: "delete site and its files",
+				apply: func() error {
+					// The backups themselves are kept
+					if err := backup.DisableSite(domain); err != nil {
+						return err
+					}
+					return site.Remove(domain)
+				},
+				destructive: true,
+			})
+		}
+	}
+
+	return p, nil
+}
+
+// planPHP installs the PHP versions and extensions the sites need. Nothing is
+// uninstalled, since other software may rely on it.
+func (p *Plan) planPHP(desired File) {
+	var versions []string
+	extensions := map[string][]string{}
+	for _, s := range desired.Sites {
+		if s.PHP == nil {
+			continue
+		}
+		if _, ok := extensions[s.PHP.Version]; !ok {
+			versions = append(versions, s.PHP.Version)
+			extensions[s.PHP.Version] = []string{}
+		}
+		for _, ext := range s.PHP.Extensions {
+			if !contains(extensions[s.PHP.Version], ext) {
+				extensions[s.PHP.Version] = append(extensions[s.PHP.Version], ext)
+			}
+		}
+	}
+
+	for _, version := range versions {
+		version := version
+		installed := php.Installed(version)
+		if !installed {
+			p.Changes = append(p.Changes, Change{
+				Kind: Add, Description: "install PHP " + version,
+				apply: func() error { return php.Install(version) },
+				undo:  func() error { return php.Uninstall(version) },
+			})
+		}
+		for _, ext := range extensions[version] {
+			ext := ext
+			if installed && php.ModuleInstalled(version, ext) {
+				continue
+			}
+			p.Changes = append(p.Changes, Change{
+				Kind: Add, Description: fmt.Sprintf("install PHP %s extension %s", version, ext),
+				apply: func() error { return php.AddModule(version, ext) },
+				undo:  func() error { return php.RemoveModule(version, ext) },
+			})
+		}
+	}
+}
+
+func (p *Plan) planSite(s Site, current map[string]site.Info, available []string) error {
+	domain := s.Domain
+	add := func(kind Kind, description string, apply, undo func() error) {
+		p.Changes = append(p.Changes, Change{Site: domain, Kind: kind, Description: description, apply: apply, undo: undo})
+	}
+
+	template := s.Template
+	if template == "" {
+		template = site.DefaultTemplate
+	}
+
+	info, exists := current[domain]
+	if !exists {
+		tmpl, err := site.GetTemplate(template)
+		if err != nil {
+			return err
+		}
+		add(Add, "create site from template "+template, func() error {
+			return site.CreateFromTemplate(domain, template)
+		}, func() error {
+			return site.Remove(domain)
+		})
+
+		// Compare the rest with what the template creates
+		info = site.Info{
+			Domain:    domain,
+			Template:  template,
+			Directory: config.GetSiteDirectory(domain),
+			Webroot:   config.GetSiteDirectory(domain),
+			Modules:   tmpl.Modules,
+		}
+	} else if s.Template != "" && s.Template != info.Template {
+		p.Notes = append(p.Notes, fmt.Sprintf("%s: created from template %s, templates only apply to new sites", domain, info.Template))
+	}
+
+	if !sameSet(s.Aliases, info.Aliases) {
+		aliases := s.Aliases
+		description := "remove aliases"
+		if len(aliases) > 0 {
+			description = "set aliases " + strings.Join(aliases, ", ")
+		}
+		previous := info.Aliases
+		add(Update, description,
+			func() error { return site.SetAliases(domain, aliases) },
+			func() error { return site.SetAliases(domain, previous) })
+	}
+
+	want := webroot(s.Webroot)
+	have := "/"
+	if rel, err := filepath.Rel(info.Directory, info.Webroot); err == nil {
+		have = webroot(filepath.ToSlash(rel))
+	}
+	if want != have {
+		add(Update, "set webroot "+want,
+			func() error { return site.UpdateWebroot(domain, want) },
+			func() error { return site.UpdateWebroot(domain, have) })
+	}
+
+	previousPHP := info.PHPVersion
+	switch {
+	case s.PHP == nil && previousPHP != "":
+		add(Remove, "disable PHP",
+			func() error { return php.Disable(domain) },
+			func() error { return php.Enable(domain, previousPHP) })
+	case s.PHP != nil && s.PHP.Version != previousPHP:
+		version := s.PHP.Version
+		add(Update, "enable PHP "+version, func() error { return php.Enable(domain, version) }, func() error {
+			if previousPHP == "" {
+				return php.Disable(domain)
+			}
+			return php.Enable(domain, previousPHP)
+		})
+	}
+
+	for _, name := range s.Modules {
+		name := name
+		if contains(info.Modules, name) {
+			continue
+		}
+		if !contains(available, name) {
+			return fmt.Errorf("site %s: module %s does not exist", domain, name)
+		}
+		add(Add, "add module "+name,
+			func() error { return module.Add(domain, name) },
+			func() error { return module.Remove(domain, name) })
+	}
+	for _, name := range info.Modules {
+		name := name
+		if !contains(s.Modules, name) {
+			add(Remove, "remove module "+name,
+				func() error { return module.Remove(domain, name) },
+				func() error { return module.Add(domain, name) })
+		}
+	}
+
+	if s.Backup != info.Backup {
+		if s.Backup {
+			add(Add, "enable backup",
+				func() error { return backup.EnableSite(domain) },
+				func() error { return backup.DisableSite(domain) })
+		} else {
+			add(Remove, "disable backup",
+				func() error { return backup.DisableSite(domain) },
+				func() error { return backup.EnableSite(domain) })
+		}
+	}
+
+	var jobs []site.CronJob
+	var env map[string]string
+	if exists {
+		var err error
+		if jobs, env, err = site.Cron(domain); err != nil {
+			return err
+		}
+	}
+	if !sameCron(s.Cron, jobs) || !sameEnv(s.Env, env) {
+		wantJobs, wantEnv := s.Cron, s.Env
+		add(Update, fmt.Sprintf("set %d cron job(s) and %d environment variable(s)", len(wantJobs), len(wantEnv)), func() error {
+			return site.SetCron(domain, wantJobs, wantEnv)
+		}, func() error {
+			return site.SetCron(domain, jobs, env)
+		})
+	}
+
+	return nil
+}
+
+// Apply makes the changes of the plan as one transaction. Caddy is reloaded
+// once, after the new configuration has been validated. If a change fails or
+// the configuration is invalid, the changes made so far are undone in
+// reverse order, the Caddy configuration is restored and sites are not
+// deleted, so Caddy keeps serving what it served before. progress is called
+// before each change.
+func (p *Plan) Apply(progress func(Change)) error {
+	saved, err := saveCaddyConfig()
+	if err != nil {
+		return err
+	}
+
+	release := caddy.Hold()
+
+	applied, err := p.run(false, progress)
+	if err == nil {
+		err = caddy.Validate()
+	}
+	if err != nil {
+		if uerr := rollback(applied, saved); uerr != nil {
+			err = fmt.Errorf("%v, and undoing the changes failed: %v", err, uerr)
+		} else {
+			err = fmt.Errorf("%v; all changes were undone", err)
+		}
+		// Only reloads for changes made outside of the plan meanwhile, as
+		// the restored configuration is the one Caddy already serves
+		release()
+		return err
+	}
+
+	// Deleted sites can't be restored, so a failure here keeps the other
+	// changes, which are valid, and loads what is left
+	if _, derr := p.run(true, progress); derr != nil {
+		err = fmt.Errorf("%v; the other changes were applied", derr)
+	}
+
+	if rerr := release(); rerr != nil && err == nil {
+		err = fmt.Errorf("failed to reload Caddy: %v", rerr)
+	}
+	return err
+}
+
+// run applies the destructive or the other changes, stopping at the first
+// failure. It returns the changes that were applied.
+func (p *Plan) run(destructive bool, progress func(Change)) ([]Change, error) {
+	var applied []Change
+	for _, c := range p.Changes {
+		if c.destructive != destructive {
+			continue
+		}
+		if progress != nil {
+			progress(c)
+		}
+		if err := c.apply(); err != nil {
+			return applied, fmt.Errorf("%s: %v", c.label(), err)
+		}
+		applied = append(applied, c)
+	}
+	return applied, nil
+}
+
+// rollback undoes applied changes, newest first, and puts back the saved
+// Caddy configuration in case an undo left it different
+func rollback(applied []Change, saved map[string][]byte) error {
+	var failed []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		c := applied[i]
+		if c.undo == nil {
+			continue
+		}
+		if err := c.undo(); err != nil {
+			failed = append(failed, fmt.Sprintf("undo %s: %v", c.label(), err))
+		}
+	}
+	if err := restoreCaddyConfig(saved); err != nil {
+		failed = append(failed, fmt.Sprintf("restore Caddy configuration: %v", err))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// label names a change in messages
+func (c Change) label() string {
+	if c.Site != "" {
+		return c.Site + ": " + c.Description
+	}
+	return c.Description
+}
+
+// saveCaddyConfig reads the site and PHP configurations apply may change
+func saveCaddyConfig() (map[string][]byte, error) {
+	saved := map[string][]byte{}
+	for _, dir := range []string{config.CaddySitesDir, config.CaddyPHPDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", path, err)
+			}
+			saved[path] = data
+		}
+	}
+	return saved, nil
+}
+
+// restoreCaddyConfig puts back the saved configurations and removes any
+// created since
+func restoreCaddyConfig(saved map[string][]byte) error {
+	for _, dir := range []string{config.CaddySitesDir, config.CaddyPHPDir} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if _, ok := saved[path]; !ok && entry.Type().IsRegular() {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for path, data := range saved {
+		if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameCron(a, b []site.CronJob) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.User == "root" {
+			x.User = ""
+		}
+		if y.User == "root" {
+			y.User = ""
+		}
+		if strings.Join(strings.Fields(x.Schedule), " ") != strings.Join(strings.Fields(y.Schedule), " ") ||
+			x.Command != y.Command || x.User != y.User {
+			return false
+		}
+	}
+	return true
+}
+
+func sameEnv(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
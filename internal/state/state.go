@@ -0,0 +1,179 @@
+// Package state describes all sites of a server in one file, so servers can
+// be managed from version control. Export reads the file from the current
+// configuration, Plan compares a file with it and Apply reconciles the
+// difference with a single validated Caddy reload.
+package state
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed sites.schema.json
+var schema []byte
+
+// File is the desired state of every site on a server
+type File struct {
+	Sites []Site `json:"sites" yaml:"sites"`
+}
+
+// Site is the desired state of one site
+type Site struct {
+	Domain  string   `json:"domain" yaml:"domain"`
+	Aliases []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	// Template only applies when the site is created
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Webroot is relative to the site directory
+	Webroot string         `json:"webroot,omitempty" yaml:"webroot,omitempty"`
+	PHP     *PHP           `json:"php,omitempty" yaml:"php,omitempty"`
+	Modules []string       `json:"modules,omitempty" yaml:"modules,omitempty"`
+	Backup  bool           `json:"backup,omitempty" yaml:"backup,omitempty"`
+	Cron    []site.CronJob `json:"cron,omitempty" yaml:"cron,omitempty"`
+	// Env is set for the site's cron jobs
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+}
+
+// PHP is the PHP version a site runs on. Extensions are installed for the
+// version and shared by every site using it.
+type PHP struct {
+	Version    string   `json:"version" yaml:"version"`
+	Extensions []string `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+}
+
+// Schema returns the JSON Schema of the state file
+func Schema() []byte {
+	return schema
+}
+
+// Load reads a state file in YAML or JSON. Unknown fields are rejected so
+// typos don't silently leave settings out.
+func Load(file string) (File, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("failed to read state file: %v", err)
+	}
+
+	var f File
+	if strings.HasSuffix(file, ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&f)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// An empty file describes a server without sites
+		if err = decoder.Decode(&f); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("failed to parse state file %s: %v", file, err)
+	}
+
+	if err := f.Validate(); err != nil {
+		return File{}, fmt.Errorf("invalid state file %s: %v", file, err)
+	}
+	return f, nil
+}
+
+// Validate checks the file without looking at the server
+func (f File) Validate() error {
+	addresses := map[string]string{}
+	claim := func(address, domain string) error {
+		if other, ok := addresses[address]; ok {
+			if other == domain {
+				return fmt.Errorf("site %s lists %s twice", domain, address)
+			}
+			return fmt.Errorf("%s is used by both %s and %s", address, other, domain)
+		}
+		addresses[address] = domain
+		return nil
+	}
+
+	for _, s := range f.Sites {
+		if !site.ValidDomain(s.Domain) {
+			return fmt.Errorf("invalid domain %q", s.Domain)
+		}
+		if err := claim(s.Domain, s.Domain); err != nil {
+			return err
+		}
+		for _, alias := range s.Aliases {
+			if !site.ValidDomain(alias) {
+				return fmt.Errorf("site %s: invalid alias %q", s.Domain, alias)
+			}
+			if err := claim(alias, s.Domain); err != nil {
+				return err
+			}
+		}
+
+		if s.Template != "" {
+			if _, err := site.GetTemplate(s.Template); err != nil {
+				return fmt.Errorf("site %s: %v", s.Domain, err)
+			}
+		}
+		for _, part := range strings.Split(s.Webroot, "/") {
+			if part == ".." {
+				return fmt.Errorf("site %s: webroot %q leaves the site directory", s.Domain, s.Webroot)
+			}
+		}
+
+		if s.PHP != nil {
+			if _, ok := php.ImportVersion("php" + s.PHP.Version + "_config"); !ok {
+				return fmt.Errorf("site %s: invalid PHP version %q", s.Domain, s.PHP.Version)
+			}
+			for _, ext := range s.PHP.Extensions {
+				if err := php.ValidExtension(ext); err != nil {
+					return fmt.Errorf("site %s: %v", s.Domain, err)
+				}
+			}
+		}
+
+		for _, job := range s.Cron {
+			if err := job.Validate(); err != nil {
+				return fmt.Errorf("site %s: %v", s.Domain, err)
+			}
+		}
+		if err := site.ValidateEnv(s.Env); err != nil {
+			return fmt.Errorf("site %s: %v", s.Domain, err)
+		}
+	}
+	return nil
+}
+
+// Write encodes the file as YAML, or as JSON when asJSON is set
+func (f File) Write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(f)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// webroot normalizes a webroot to a clean path starting with a slash
+func webroot(p string) string {
+	return path.Clean("/" + p)
+}
@@ -0,0 +1,410 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/backup"
+	"github.com/doko89/cliboard/internal/caddy"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/monitor"
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+)
+
+// siteRow is a site with what the sites view shows about it
+type siteRow struct {
+	site.Info
+	// state is the monitor state, empty when the site is not monitored
+	state      string
+	lastBackup time.Time
+}
+
+// status is the first thing to know about a site
+func (r siteRow) status() string {
+	switch {
+	case r.Suspended:
+		return "suspended"
+	case r.state == "":
+		return "-"
+	}
+	return r.state
+}
+
+// backupAge tells how old the latest backup is
+func (r siteRow) backupAge() string {
+	switch {
+	case !r.Backup:
+		return "off"
+	case r.lastBackup.IsZero():
+		return "never"
+	}
+	return age(time.Since(r.lastBackup))
+}
+
+// sitesView lists the sites and is where the interface starts
+type sitesView struct {
+	list
+	sites []siteRow
+}
+
+func newSitesView() *sitesView {
+	return &sitesView{}
+}
+
+func (v *sitesView) title() string { return "Sites" }
+
+func (v *sitesView) help() string {
+	return "enter modules  p php  b backup  l logs  s suspend  r refresh  ? help  q quit"
+}
+
+func (v *sitesView) load() error {
+	infos, err := site.List()
+	if err != nil {
+		return err
+	}
+
+	// Sites are only in the monitor's state once it checked them
+	states := map[string]string{}
+	statuses, _ := monitor.Statuses()
+	for _, s := range statuses {
+		states[s.Domain] = s.State
+	}
+
+	v.sites = v.sites[:0]
+	for _, info := range infos {
+		row := siteRow{Info: info, state: states[info.Domain]}
+		row.lastBackup, _ = backup.LastSiteBackup(info.Domain)
+		v.sites = append(v.sites, row)
+	}
+	return nil
+}
+
+func (v *sitesView) lines(width, height int) []line {
+	if len(v.sites) == 0 {
+		return []line{{}, {text: "  No sites found. Create one with: cliboard create-site <domain>"}}
+	}
+
+	domainWidth := len("DOMAIN")
+	for _, s := range v.sites {
+		if n := len(s.Domain); n > domainWidth {
+			domainWidth = n
+		}
+	}
+	if domainWidth > 40 {
+		domainWidth = 40
+	}
+	row := func(domain, status, php, backup, modules string) string {
+		return fmt.Sprintf(" %-*s  %-9s  %-5s  %-9s  %s", domainWidth, fit(domain, domainWidth), status, php, backup, modules)
+	}
+
+	lines := []line{{text: row("DOMAIN", "STATUS", "PHP", "BACKUP", "MODULES"), style: styleBold}}
+	start, end := v.window(len(v.sites), height-1)
+	for i := start; i < end; i++ {
+		s := v.sites[i]
+		phpVersion := s.PHPVersion
+		if phpVersion == "" {
+			phpVersion = "-"
+		}
+		modules := strings.Join(s.Modules, ", ")
+		if modules == "" {
+			modules = "-"
+		}
+
+		l := line{text: row(s.Domain, s.status(), phpVersion, s.backupAge(), modules)}
+		switch {
+		case i == v.cursor:
+			l.style = styleInverse
+		case s.state == monitor.Down:
+			l.style = styleRed
+		case s.Suspended:
+			l.style = styleYellow
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func (v *sitesView) key(a *app, key string) {
+	_, height := a.term.size()
+	if v.move(key, len(v.sites), height-4) {
+		return
+	}
+
+	switch key {
+	case "q", keyEscape:
+		a.quit = true
+		return
+	case "r":
+		if err := v.load(); err != nil {
+			a.setStatus(err.Error(), true)
+		} else {
+			a.setStatus("Refreshed", false)
+		}
+		return
+	}
+
+	if len(v.sites) == 0 {
+		return
+	}
+	s := v.sites[v.cursor]
+	domain := s.Domain
+
+	switch key {
+	case keyEnter, "m":
+		a.push(&modulesView{domain: domain})
+	case "p":
+		a.push(&phpView{domain: domain})
+	case "l":
+		a.push(&logsView{domain: domain})
+	case "b":
+		a.run("backup-run "+domain, "Backup of "+domain+" taken", func() error {
+			_, err := backup.RunSite(domain)
+			return err
+		})
+	case "s":
+		if s.Suspended {
+			a.run("resume-site "+domain, "Site "+domain+" resumed", func() error {
+				return site.Resume(domain)
+			})
+			return
+		}
+		a.confirm("Suspend "+domain+"?", []string{
+			"Every request to the site is answered with 503 Service Unavailable",
+			"until it is resumed. Its files and configuration are kept.",
+		}, "suspend-site "+domain, "Site "+domain+" suspended", func() error {
+			return site.Suspend(domain)
+		})
+	}
+}
+
+// modulesView toggles the modules a site imports
+type modulesView struct {
+	list
+	domain    string
+	available []string
+	enabled   map[string]bool
+}
+
+func (v *modulesView) title() string { return "Modules of " + v.domain }
+func (v *modulesView) help() string  { return "enter/space toggle  esc back  ? help" }
+
+func (v *modulesView) load() error {
+	info, err := site.Get(v.domain)
+	if err != nil {
+		return err
+	}
+	available, err := module.Available()
+	if err != nil {
+		return err
+	}
+
+	v.available = available
+	v.enabled = map[string]bool{}
+	for _, name := range info.Modules {
+		v.enabled[name] = true
+	}
+	return nil
+}
+
+func (v *modulesView) lines(width, height int) []line {
+	if len(v.available) == 0 {
+		return []line{{}, {text: "  No modules found in " + config.CaddyModulesDir}}
+	}
+
+	lines := []line{{text: " Modules imported by the site are checked.", style: styleDim}}
+	start, end := v.window(len(v.available), height-1)
+	for i := start; i < end; i++ {
+		name := v.available[i]
+		mark := "[ ]"
+		if v.enabled[name] {
+			mark = "[x]"
+		}
+		l := line{text: " " + mark + " " + name}
+		if i == v.cursor {
+			l.style = styleInverse
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func (v *modulesView) key(a *app, key string) {
+	_, height := a.term.size()
+	if v.move(key, len(v.available), height-5) {
+		return
+	}
+
+	switch key {
+	case keyEscape, "q":
+		a.pop()
+	case keyEnter, keySpace:
+		if len(v.available) == 0 {
+			return
+		}
+		name, domain := v.available[v.cursor], v.domain
+		if !v.enabled[name] {
+			a.run("add-module "+domain+" "+name, "Module "+name+" added to "+domain, func() error {
+				return module.Add(domain, name)
+			})
+			return
+		}
+		a.confirm("Remove module "+name+" from "+domain+"?", []string{
+			"The site stops importing the module once Caddy is reloaded.",
+		}, "remove-module "+domain+" "+name, "Module "+name+" removed from "+domain, func() error {
+			return module.Remove(domain, name)
+		})
+	}
+}
+
+// phpView switches the PHP version of a site or disables PHP
+type phpView struct {
+	list
+	domain  string
+	current string
+	// versions are the installed versions, after "" for no PHP
+	versions []string
+}
+
+func (v *phpView) title() string { return "PHP of " + v.domain }
+func (v *phpView) help() string  { return "enter use  esc back  ? help" }
+
+func (v *phpView) load() error {
+	info, err := site.Get(v.domain)
+	if err != nil {
+		return err
+	}
+	v.current = info.PHPVersion
+	v.versions = append([]string{""}, php.InstalledVersions()...)
+	return nil
+}
+
+func (v *phpView) lines(width, height int) []line {
+	lines := []line{{text: " The site's current setting is marked.", style: styleDim}}
+	start, end := v.window(len(v.versions), height-1)
+	for i := start; i < end; i++ {
+		version := v.versions[i]
+		mark := "( )"
+		if version == v.current {
+			mark = "(*)"
+		}
+		label := "PHP " + version
+		if version == "" {
+			label = "no PHP"
+		}
+		l := line{text: " " + mark + " " + label}
+		if i == v.cursor {
+			l.style = styleInverse
+		}
+		lines = append(lines, l)
+	}
+	if len(v.versions) == 1 {
+		lines = append(lines, line{}, line{text: " No PHP versions are installed. Install one with: cliboard php install <version>", style: styleDim})
+	}
+	return lines
+}
+
+func (v *phpView) key(a *app, key string) {
+	_, height := a.term.size()
+	if v.move(key, len(v.versions), height-5) {
+		return
+	}
+
+	switch key {
+	case keyEscape, "q":
+		a.pop()
+	case keyEnter, keySpace:
+		version, domain := v.versions[v.cursor], v.domain
+		switch {
+		case version == v.current:
+			a.setStatus("Nothing to change", false)
+		case version == "":
+			a.confirm("Disable PHP for "+domain+"?", []string{
+				"Requests are no longer passed to PHP " + v.current + "; .php files stop being executed.",
+			}, "disable-php "+domain, "PHP disabled for "+domain, func() error {
+				return php.Disable(domain)
+			})
+		case v.current == "":
+			a.run("enable-php "+domain+" "+version, "PHP "+version+" enabled for "+domain, func() error {
+				return php.Enable(domain, version)
+			})
+		default:
+			a.confirm("Switch "+domain+" from PHP "+v.current+" to PHP "+version+"?", []string{
+				"The site is served by the PHP " + version + " FPM pool once Caddy is reloaded.",
+				"Extensions installed for PHP " + v.current + " may be missing from PHP " + version + ".",
+			}, "enable-php "+domain+" "+version, "PHP "+version+" enabled for "+domain, func() error {
+				return php.Enable(domain, version)
+			})
+		}
+	}
+}
+
+// logsView follows the access log of a site, newest request first
+type logsView struct {
+	domain  string
+	entries []caddy.LogEntry
+}
+
+// logLines is how many requests the logs view reads
+const logLines = 200
+
+func (v *logsView) title() string { return "Access log of " + v.domain }
+func (v *logsView) help() string  { return "esc back  ? help  (follows new requests)" }
+
+func (v *logsView) load() error {
+	v.entries = caddy.AccessLog(v.domain, logLines)
+	return nil
+}
+
+func (v *logsView) tick() {
+	v.load()
+}
+
+func (v *logsView) lines(width, height int) []line {
+	if len(v.entries) == 0 {
+		return []line{{}, {text: "  No requests logged yet"}}
+	}
+
+	lines := []line{{text: fmt.Sprintf(" %-19s  %-15s  %-6s  %-3s  %s", "TIME", "REMOTE", "METHOD", "ST", "URI"), style: styleBold}}
+	for _, e := range v.entries {
+		if len(lines) >= height {
+			break
+		}
+		if e.Raw != "" {
+			lines = append(lines, line{text: " " + e.Raw, style: styleDim})
+			continue
+		}
+
+		l := line{text: fmt.Sprintf(" %-19s  %-15s  %-6s  %3d  %s", e.Time, e.Remote, e.Method, e.Status, e.URI)}
+		switch {
+		case e.Status >= 500:
+			l.style = styleRed
+		case e.Status >= 400:
+			l.style = styleYellow
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+func (v *logsView) key(a *app, key string) {
+	switch key {
+	case keyEscape, "q":
+		a.pop()
+	}
+}
+
+// age formats how long ago something happened
+func age(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+}
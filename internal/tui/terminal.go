@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Key names for the keys that are not a single printable character
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyLeft      = "left"
+	keyRight     = "right"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdn"
+	keyEnter     = "enter"
+	keyEscape    = "esc"
+	keySpace     = "space"
+	keyTab       = "tab"
+	keyBackspace = "backspace"
+	keyInterrupt = "ctrl-c"
+)
+
+// Line styles, as SGR escape sequences
+const (
+	styleNormal  = ""
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleInverse = "\x1b[7m"
+	styleRed     = "\x1b[31m"
+	styleYellow  = "\x1b[33m"
+)
+
+// line is one row of the screen
+type line struct {
+	text  string
+	style string
+}
+
+// terminal is the controlling terminal in raw mode, showing the alternate
+// screen so the shell's scrollback is left as it was
+type terminal struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+	keys  chan string
+}
+
+func openTerminal() (*terminal, error) {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, fmt.Errorf("the TUI needs an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the terminal: %v", err)
+	}
+
+	t := &terminal{in: in, out: out, state: state, keys: make(chan string, 16)}
+	// Switch to the alternate screen and hide the cursor
+	out.WriteString("\x1b[?1049h\x1b[?25l")
+	go t.read()
+	return t, nil
+}
+
+// close restores the screen and the terminal mode
+func (t *terminal) close() {
+	t.out.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l")
+	term.Restore(int(t.in.Fd()), t.state)
+}
+
+// size returns the width and height of the terminal
+func (t *terminal) size() (int, int) {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// draw replaces the screen with lines, cut or padded to its size
+func (t *terminal) draw(lines []line) {
+	width, height := t.size()
+
+	var b bytes.Buffer
+	b.WriteString("\x1b[H")
+	for i := 0; i < height; i++ {
+		var l line
+		if i < len(lines) {
+			l = lines[i]
+		}
+
+		text := fit(l.text, width)
+		if l.style == styleInverse {
+			// Bars span the whole width
+			text += strings.Repeat(" ", width-utf8.RuneCountInString(text))
+		}
+		if l.style != styleNormal {
+			b.WriteString(l.style + text + "\x1b[0m")
+		} else {
+			b.WriteString(text)
+		}
+		b.WriteString("\x1b[K")
+		if i < height-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	t.out.Write(b.Bytes())
+}
+
+// read passes key presses on until the input is closed
+func (t *terminal) read() {
+	buf := make([]byte, 256)
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			close(t.keys)
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			t.keys <- key
+		}
+	}
+}
+
+// escapeKeys maps the CSI and SS3 sequences terminals send for special keys
+var escapeKeys = map[string]string{
+	"A": keyUp, "B": keyDown, "C": keyRight, "D": keyLeft,
+	"H": keyHome, "F": keyEnd, "1~": keyHome, "7~": keyHome, "4~": keyEnd, "8~": keyEnd,
+	"5~": keyPageUp, "6~": keyPageDown,
+}
+
+// parseKeys splits raw terminal input into key names. Printable characters
+// are their own name; unknown sequences are dropped.
+func parseKeys(data []byte) []string {
+	var keys []string
+	for len(data) > 0 {
+		c := data[0]
+		switch {
+		case c == 0x1b && len(data) > 2 && (data[1] == '[' || data[1] == 'O'):
+			// Parameters and intermediates, up to the final byte
+			i := 2
+			for i < len(data) && (data[i] < 0x40 || data[i] > 0x7e) {
+				i++
+			}
+			if i == len(data) {
+				return keys
+			}
+			if key, ok := escapeKeys[string(data[2:i+1])]; ok {
+				keys = append(keys, key)
+			}
+			data = data[i+1:]
+			continue
+		case c == 0x1b:
+			keys = append(keys, keyEscape)
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyEnter)
+		case c == '\t':
+			keys = append(keys, keyTab)
+		case c == ' ':
+			keys = append(keys, keySpace)
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, keyBackspace)
+		case c == 0x03:
+			keys = append(keys, keyInterrupt)
+		case c < 0x20:
+			// Other control characters have no meaning here
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, string(r))
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+// fit cuts text to width columns, marking the cut with an ellipsis
+func fit(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,248 @@
+// Package tui is a full-screen terminal interface for browsing the sites of
+// a server and running the common operations on them. It drives the same
+// internal packages as the command line and the panel, and audits every
+// change it makes.
+package tui
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/doko89/cliboard/internal/audit"
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+)
+
+// view is a screen of the interface. Views are stacked: Escape returns to
+// the view below.
+type view interface {
+	// title names the view in the header
+	title() string
+	// load reads what the view shows. It is called when the view is
+	// opened, after every operation and on refresh.
+	load() error
+	// lines renders the view in width columns and at most height rows
+	lines(width, height int) []line
+	// help lists the keys of the view for the footer
+	help() string
+	// key handles a key press
+	key(a *app, key string)
+}
+
+// ticker is a view that refreshes itself every second
+type ticker interface {
+	tick()
+}
+
+// result is how an operation ended
+type result struct {
+	done    string
+	err     error
+	warning string
+}
+
+type app struct {
+	term  *terminal
+	views []view
+
+	// status is the message shown above the footer
+	status string
+	failed bool
+
+	// busy names the operation in progress; keys are ignored meanwhile
+	busy     string
+	progress chan string
+	results  chan result
+
+	quit bool
+}
+
+// Run shows the interface until the user quits
+func Run() error {
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	a := &app{
+		term:     t,
+		progress: make(chan string, 64),
+		results:  make(chan result, 1),
+	}
+
+	// Operations report progress on the status line, never on the screen
+	restore := event.Handle(func(message string) {
+		select {
+		case a.progress <- message:
+		default:
+		}
+	})
+	defer restore()
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+
+	a.push(newSitesView())
+	for !a.quit {
+		a.draw()
+		select {
+		case key, ok := <-t.keys:
+			if !ok {
+				return nil
+			}
+			a.key(key)
+		case message := <-a.progress:
+			a.setStatus(message, false)
+		case r := <-a.results:
+			a.finish(r)
+		case <-resize:
+		case <-tick.C:
+			if v, ok := a.top().(ticker); ok && a.busy == "" {
+				v.tick()
+			}
+		}
+	}
+	return nil
+}
+
+func (a *app) top() view {
+	return a.views[len(a.views)-1]
+}
+
+// push opens a view on top of the current one
+func (a *app) push(v view) {
+	a.views = append(a.views, v)
+	if err := v.load(); err != nil {
+		a.setStatus(err.Error(), true)
+	}
+}
+
+// pop returns to the previous view, or quits from the first one
+func (a *app) pop() {
+	if len(a.views) == 1 {
+		a.quit = true
+		return
+	}
+	a.views = a.views[:len(a.views)-1]
+}
+
+// reload reads every open view again
+func (a *app) reload() error {
+	for _, v := range a.views {
+		if err := v.load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *app) setStatus(message string, failed bool) {
+	a.status, a.failed = message, failed
+}
+
+func (a *app) key(key string) {
+	if key == keyInterrupt {
+		a.quit = true
+		return
+	}
+	if a.busy != "" {
+		a.setStatus("Waiting for "+a.busy+" to finish", false)
+		return
+	}
+
+	if key == "?" {
+		if _, ok := a.top().(*helpView); !ok {
+			a.push(&helpView{})
+		}
+		return
+	}
+	a.top().key(a, key)
+}
+
+// confirm asks before running a destructive operation
+func (a *app) confirm(question string, details []string, command, done string, fn func() error) {
+	a.push(&confirmView{question: question, details: details, command: command, done: done, fn: fn})
+}
+
+// run starts an operation in the background. command is recorded in the
+// audit log and the configuration history, done is shown when it succeeds.
+func (a *app) run(command, done string, fn func() error) {
+	a.busy = command
+	a.setStatus("Running "+command+"...", false)
+
+	go func() {
+		var r result
+		var record error
+		r.err, record = audit.Run(audit.SourceTUI, "", command, fn)
+		if record != nil {
+			r.warning = record.Error()
+		}
+		r.done = done
+		a.results <- r
+	}()
+}
+
+// finish shows how an operation ended and reads the views again
+func (a *app) finish(r result) {
+	a.busy = ""
+	switch {
+	case r.err != nil:
+		a.setStatus(r.err.Error(), true)
+	case r.warning != "":
+		a.setStatus(r.done+" (warning: "+r.warning+")", true)
+	default:
+		a.setStatus(r.done, false)
+	}
+
+	if err := a.reload(); err != nil && r.err == nil {
+		a.setStatus(err.Error(), true)
+	}
+}
+
+// draw renders the header, the top view, the status line and the footer
+func (a *app) draw() {
+	width, height := a.term.size()
+	v := a.top()
+
+	header := " CLIBoard · " + v.title()
+	if host, err := os.Hostname(); err == nil {
+		right := host + " "
+		if config.Root != "" {
+			right = host + " · root " + config.Root + " "
+		}
+		if pad := width - len([]rune(header)) - len([]rune(right)); pad > 0 {
+			header += strings.Repeat(" ", pad) + right
+		}
+	}
+
+	lines := []line{{text: header, style: styleInverse}}
+	body := height - 3
+	content := v.lines(width, body)
+	if len(content) > body {
+		content = content[:body]
+	}
+	lines = append(lines, content...)
+	for len(lines) < height-2 {
+		lines = append(lines, line{})
+	}
+
+	status := line{text: " " + a.status}
+	if a.failed {
+		status.style = styleRed
+	}
+	footer := " " + v.help()
+	if a.busy != "" {
+		footer = " Working, please wait..."
+	}
+	lines = append(lines, status, line{text: footer, style: styleInverse})
+
+	a.term.draw(lines)
+}
@@ -0,0 +1,154 @@
+package tui
+
+// list keeps the cursor and scroll position of a list of rows
+type list struct {
+	cursor int
+	offset int
+}
+
+// move handles the navigation keys for a list of n rows shown page rows at
+// a time, and reports whether key was one of them
+func (l *list) move(key string, n, page int) bool {
+	if page < 1 {
+		page = 1
+	}
+	switch key {
+	case keyUp, "k":
+		l.cursor--
+	case keyDown, "j":
+		l.cursor++
+	case keyPageUp:
+		l.cursor -= page
+	case keyPageDown:
+		l.cursor += page
+	case keyHome, "g":
+		l.cursor = 0
+	case keyEnd, "G":
+		l.cursor = n - 1
+	default:
+		return false
+	}
+	l.clamp(n)
+	return true
+}
+
+// clamp keeps the cursor on one of n rows
+func (l *list) clamp(n int) {
+	if l.cursor >= n {
+		l.cursor = n - 1
+	}
+	if l.cursor < 0 {
+		l.cursor = 0
+	}
+}
+
+// window returns the rows of n to show in height rows, scrolled so the
+// cursor is visible
+func (l *list) window(n, height int) (start, end int) {
+	l.clamp(n)
+	if height < 1 {
+		height = 1
+	}
+	if l.cursor < l.offset {
+		l.offset = l.cursor
+	}
+	if l.cursor >= l.offset+height {
+		l.offset = l.cursor - height + 1
+	}
+	if l.offset > n-height {
+		l.offset = n - height
+	}
+	if l.offset < 0 {
+		l.offset = 0
+	}
+
+	end = l.offset + height
+	if end > n {
+		end = n
+	}
+	return l.offset, end
+}
+
+// confirmView asks before a destructive operation runs
+type confirmView struct {
+	question string
+	details  []string
+	command  string
+	done     string
+	fn       func() error
+}
+
+func (v *confirmView) title() string { return "Confirm" }
+func (v *confirmView) load() error   { return nil }
+func (v *confirmView) help() string  { return "y confirm  n/esc cancel" }
+
+func (v *confirmView) lines(width, height int) []line {
+	lines := []line{{}, {text: "  " + v.question, style: styleBold}, {}}
+	for _, d := range v.details {
+		lines = append(lines, line{text: "  " + d})
+	}
+	lines = append(lines, line{},
+		line{text: "  Command: cliboard " + v.command, style: styleDim},
+		line{},
+		line{text: "  Press y to go ahead, n or Esc to cancel.", style: styleYellow})
+	return lines
+}
+
+func (v *confirmView) key(a *app, key string) {
+	switch key {
+	case "y", "Y":
+		a.pop()
+		a.run(v.command, v.done, v.fn)
+	case "n", "N", "q", keyEscape:
+		a.pop()
+		a.setStatus("Cancelled", false)
+	}
+}
+
+// helpView lists the keys of every view
+type helpView struct{}
+
+var helpText = []string{
+	"Sites",
+	"  ↑/↓ j/k     move between sites          PgUp/PgDn g/G  scroll",
+	"  Enter m     toggle the site's modules",
+	"  p           switch or disable PHP",
+	"  b           take a backup now",
+	"  l           follow the access log",
+	"  s           suspend or resume the site",
+	"  r           refresh",
+	"  q           quit",
+	"",
+	"Modules and PHP",
+	"  Enter Space toggle the module / use the PHP version",
+	"  Esc q       back to the sites",
+	"",
+	"Anywhere",
+	"  ?           this help                   Ctrl-C  quit",
+	"",
+	"Removing a module, changing PHP and suspending a site ask for",
+	"confirmation first. Every change is recorded in the audit log.",
+}
+
+func (v *helpView) title() string { return "Help" }
+func (v *helpView) load() error   { return nil }
+func (v *helpView) help() string  { return "esc back" }
+
+func (v *helpView) lines(width, height int) []line {
+	var lines []line
+	for _, text := range helpText {
+		style := styleNormal
+		if text != "" && text[0] != ' ' {
+			style = styleBold
+		}
+		lines = append(lines, line{text: " " + text, style: style})
+	}
+	return lines
+}
+
+func (v *helpView) key(a *app, key string) {
+	switch key {
+	case keyEscape, "q", "?", keyEnter:
+		a.pop()
+	}
+}
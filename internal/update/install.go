@@ -0,0 +1,228 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// previousSuffix names the binary an update replaced, kept next to the
+// current one for Rollback
+const previousSuffix = ".previous"
+
+// Executable returns the path of the running binary, with symlinks resolved
+// so the binary itself is replaced rather than the link
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cliboard binary: %v", err)
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// Previous returns where the binary replaced by the last update is kept
+func Previous(exe string) string {
+	return exe + previousSuffix
+}
+
+// BinaryName is the release file name of the binary for this platform
+func BinaryName() string {
+	return fmt.Sprintf("cliboard-%s-%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// Install downloads a version, verifies its signature and checksum and
+// replaces the binary at exe with it. The replaced binary is kept for
+// Rollback.
+func Install(version, exe string) error {
+	version = strings.TrimPrefix(version, "v")
+	if _, ok := parseVersion(version); !ok {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	key, err := publicKey()
+	if err != nil {
+		return err
+	}
+
+	dir := "v" + version + "/"
+	sums, err := fetch(dir + "SHA256SUMS")
+	if err != nil {
+		return err
+	}
+	signature, err := fetch(dir + "SHA256SUMS.sig")
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(key, sums, signature); err != nil {
+		return fmt.Errorf("release %s: %v", version, err)
+	}
+
+	name := BinaryName()
+	want, err := checksum(sums, name)
+	if err != nil {
+		return fmt.Errorf("release %s: %v", version, err)
+	}
+	binary, err := fetch(dir + name)
+	if err != nil {
+		return err
+	}
+	if got := sha256.Sum256(binary); hex.EncodeToString(got[:]) != want {
+		return fmt.Errorf("release %s: %s does not match its checksum", version, name)
+	}
+
+	// Write next to the binary, so the swap is a rename within a filesystem
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", exe, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".update*")
+	if err != nil {
+		return fmt.Errorf("failed to write the new binary: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the new binary: %v", err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm() | 0111); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the new binary: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write the new binary: %v", err)
+	}
+
+	if err := checkBinary(tmp.Name(), version); err != nil {
+		return err
+	}
+	return swap(exe, tmp.Name())
+}
+
+// Rollback puts back the binary the last update replaced. The current
+// binary is kept in its place, so a second rollback undoes the first.
+func Rollback(exe string) error {
+	previous := Previous(exe)
+	if _, err := os.Stat(previous); err != nil {
+		return fmt.Errorf("no previous binary to roll back to: %v", err)
+	}
+
+	current := exe + ".rollback"
+	os.Remove(current)
+	if err := keep(exe, current); err != nil {
+		return fmt.Errorf("failed to keep the current binary: %v", err)
+	}
+	if err := os.Rename(previous, exe); err != nil {
+		os.Remove(current)
+		return fmt.Errorf("failed to restore %s: %v", previous, err)
+	}
+	if err := os.Rename(current, previous); err != nil {
+		return fmt.Errorf("failed to keep the current binary as %s: %v", previous, err)
+	}
+	return nil
+}
+
+// swap keeps the binary at exe as the previous one and renames replacement
+// over it, so exe always exists
+func swap(exe, replacement string) error {
+	previous := Previous(exe)
+	os.Remove(previous)
+	if err := keep(exe, previous); err != nil {
+		return fmt.Errorf("failed to keep the current binary: %v", err)
+	}
+	if err := os.Rename(replacement, exe); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", exe, err)
+	}
+	return nil
+}
+
+// keep makes a second name for a binary, a hard link if possible
+func keep(path, name string) error {
+	if err := os.Link(path, name); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(name)
+		return err
+	}
+	return dst.Close()
+}
+
+// checkBinary runs the new binary to make sure it works on this machine and
+// is the version it claims to be
+func checkBinary(path, version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("the downloaded binary does not run: %v", err)
+	}
+	if !bytes.Contains(output, []byte("v"+version+"\n")) {
+		return fmt.Errorf("the downloaded binary is not version %s", version)
+	}
+	return nil
+}
+
+// publicKey decodes the key releases must be signed with
+func publicKey() (ed25519.PublicKey, error) {
+	if config.UpdatePublicKey == "" {
+		return nil, fmt.Errorf("no release signing key is configured, set update.public_key")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(config.UpdatePublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update.public_key is not a base64 ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// verifySignature checks the base64 signature of the checksums file
+func verifySignature(key ed25519.PublicKey, sums, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("SHA256SUMS.sig is not a base64 ed25519 signature")
+	}
+	if !ed25519.Verify(key, sums, sig) {
+		return fmt.Errorf("the signature of SHA256SUMS is not valid")
+	}
+	return nil
+}
+
+// checksum finds the SHA-256 of a file in sha256sum output
+func checksum(sums []byte, name string) (string, error) {
+	for _, l := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("SHA256SUMS has no checksum for %s", name)
+}
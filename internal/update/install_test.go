@@ -0,0 +1,57 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+)
+
+const sums = `3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b  cliboard-linux-amd64
+9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08 *cliboard-linux-arm64
+`
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(sums))) + "\n")
+
+	if err := verifySignature(pub, []byte(sums), sig); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := verifySignature(otherPub, []byte(sums), sig); err == nil {
+		t.Error("signature by another key accepted")
+	}
+	if err := verifySignature(pub, []byte(sums+"0000  cliboard-evil\n"), sig); err == nil {
+		t.Error("signature over different checksums accepted")
+	}
+	if err := verifySignature(pub, []byte(sums), []byte("not base64!")); err == nil {
+		t.Error("malformed signature accepted")
+	}
+	if err := verifySignature(pub, []byte(sums), []byte(base64.StdEncoding.EncodeToString([]byte("short")))); err == nil {
+		t.Error("truncated signature accepted")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		name, want string
+		ok         bool
+	}{
+		{"cliboard-linux-amd64", "3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b", true},
+		{"cliboard-linux-arm64", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", true},
+		{"cliboard-linux", "", false},
+		{"cliboard-darwin-arm64", "", false},
+	}
+	for _, tt := range tests {
+		got, err := checksum([]byte(sums), tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("checksum(%s) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package update replaces the running cliboard binary with a release. A
+// release is published under the update URL as
+//
+//	channels/<channel>.json        {"version": "1.4.0", "notes": "..."}
+//	v<version>/SHA256SUMS          sha256sum output for the binaries
+//	v<version>/SHA256SUMS.sig      base64 ed25519 signature of SHA256SUMS
+//	v<version>/cliboard-<os>-<arch>
+//
+// A binary is only installed if SHA256SUMS carries a valid signature by the
+// configured public key and the binary matches its checksum.
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doko89/cliboard/internal/config"
+)
+
+// Channels are the release channels
+var Channels = []string{"stable", "beta"}
+
+// Release is a version published on a channel
+type Release struct {
+	Version   string    `json:"version"`
+	Published time.Time `json:"published,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Latest returns the newest release of a channel
+func Latest(channel string) (Release, error) {
+	if !validChannel(channel) {
+		return Release{}, fmt.Errorf("unknown channel %q, expected one of %s", channel, strings.Join(Channels, ", "))
+	}
+
+	data, err := fetch("channels/" + channel + ".json")
+	if err != nil {
+		return Release{}, err
+	}
+
+	var r Release
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Release{}, fmt.Errorf("invalid release metadata for channel %s: %v", channel, err)
+	}
+	r.Version = strings.TrimPrefix(r.Version, "v")
+	if _, ok := parseVersion(r.Version); !ok {
+		return Release{}, fmt.Errorf("invalid version %q in release metadata for channel %s", r.Version, channel)
+	}
+	return r, nil
+}
+
+// Newer reports whether version is newer than current. Development builds,
+// whose version is not a release number, are older than every release.
+func Newer(version, current string) bool {
+	v, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	return compare(v, c) > 0
+}
+
+// version is a parsed major.minor.patch[-prerelease] version
+type version struct {
+	numbers    [3]int
+	prerelease []string
+}
+
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return v, false
+		}
+		v.prerelease = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.numbers[i] = n
+	}
+	return v, true
+}
+
+// compare orders versions by semantic versioning precedence
+func compare(a, b version) int {
+	for i := range a.numbers {
+		if a.numbers[i] != b.numbers[i] {
+			return sign(a.numbers[i] - b.numbers[i])
+		}
+	}
+
+	// A prerelease comes before the release
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) == 0:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		x, y := a.prerelease[i], b.prerelease[i]
+		if x == y {
+			continue
+		}
+		xn, xerr := strconv.Atoi(x)
+		yn, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil:
+			return sign(xn - yn)
+		case xerr == nil:
+			// Numeric identifiers sort before alphanumeric ones
+			return -1
+		case yerr == nil:
+			return 1
+		}
+		return strings.Compare(x, y)
+	}
+	return sign(len(a.prerelease) - len(b.prerelease))
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+func validChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch downloads a file below the update URL
+func fetch(name string) ([]byte, error) {
+	url := strings.TrimSuffix(config.UpdateURL, "/") + "/" + name
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	return data, nil
+}
@@ -4,17 +4,29 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/user"
+	"strconv"
 	"strings"
+
+	"golang.org/x/term"
 )
 
+// stdin is shared by the prompts, so input read ahead by one prompt is not
+// lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// Interactive reports whether stdin and stdout are a terminal, so the user
+// can be prompted
+func Interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
 // AskForConfirmation asks the user for confirmation
 func AskForConfirmation(s string) bool {
-	reader := bufio.NewReader(os.Stdin)
-
 	for {
 		fmt.Printf("%s [y/n]: ", s)
 
-		response, err := reader.ReadString('\n')
+		response, err := stdin.ReadString('\n')
 		if err != nil {
 			return false
 		}
@@ -29,6 +41,125 @@ func AskForConfirmation(s string) bool {
 	}
 }
 
+// AskForInput asks for a line of text. An empty answer picks def, and the
+// question is repeated until validate, if set, accepts the answer.
+func AskForInput(s, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", s, def)
+		} else {
+			fmt.Printf("%s: ", s)
+		}
+
+		response, err := stdin.ReadString('\n')
+		if err != nil && response == "" {
+			return "", fmt.Errorf("no answer to %q: %v", s, err)
+		}
+
+		response = strings.TrimSpace(response)
+		if response == "" {
+			response = def
+		}
+		if validate != nil {
+			if err := validate(response); err != nil {
+				fmt.Printf("  %v\n", err)
+				continue
+			}
+		}
+		return response, nil
+	}
+}
+
+// AskForSelection asks the user to pick one of options, by number or by
+// name, and returns its index. An empty answer picks def.
+func AskForSelection(s string, options []string, def int) (int, error) {
+	printOptions(s, options)
+	var choice int
+	_, err := AskForInput("Choose", strconv.Itoa(def+1), func(answer string) error {
+		i, err := pickOption(options, answer)
+		choice = i
+		return err
+	})
+	return choice, err
+}
+
+// AskForSelections asks the user to pick any number of options, as a comma
+// separated list of numbers or names, and returns their indexes. An empty
+// answer picks defs; "none" picks nothing.
+func AskForSelections(s string, options []string, defs []int) ([]int, error) {
+	printOptions(s, options)
+
+	var def []string
+	for _, i := range defs {
+		def = append(def, strconv.Itoa(i+1))
+	}
+	if len(def) == 0 {
+		def = []string{"none"}
+	}
+
+	var choices []int
+	_, err := AskForInput("Choose, separated by commas", strings.Join(def, ","), func(answer string) error {
+		choices = nil
+		if answer == "none" {
+			return nil
+		}
+		for _, part := range strings.Split(answer, ",") {
+			i, err := pickOption(options, strings.TrimSpace(part))
+			if err != nil {
+				return err
+			}
+			choices = append(choices, i)
+		}
+		return nil
+	})
+	return choices, err
+}
+
+func printOptions(s string, options []string) {
+	fmt.Printf("%s\n", s)
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option)
+	}
+}
+
+// pickOption finds an answer among options by number or by the option's
+// first word
+func pickOption(options []string, answer string) (int, error) {
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n < 1 || n > len(options) {
+			return 0, fmt.Errorf("pick a number from 1 to %d", len(options))
+		}
+		return n - 1, nil
+	}
+	for i, option := range options {
+		if fields := strings.Fields(option); len(fields) > 0 && fields[0] == answer {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not one of the options", answer)
+}
+
+// ReadPassword prompts for a secret without echoing it. When stdin is not a
+// terminal the first line of stdin is read instead.
+func ReadPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+	return string(password), nil
+}
+
 // FileExists checks if a file exists
 func FileExists(filename string) bool {
 	info, err := os.Stat(filename)
@@ -46,3 +177,18 @@ func DirectoryExists(dirname string) bool {
 	}
 	return info.IsDir()
 }
+
+// InvokingUser returns the name of the user running cliboard, looking
+// through sudo to the user who invoked it
+func InvokingUser() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
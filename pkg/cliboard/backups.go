@@ -0,0 +1,116 @@
+package cliboard
+
+import (
+	"context"
+	"time"
+
+	"github.com/doko89/cliboard/internal/backup"
+)
+
+// Backup is a copy of a site's files taken by the daily or weekly job, or
+// by RunBackup
+type Backup struct {
+	Kind string    `json:"kind"` // "daily" or "weekly"
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+}
+
+// Backups returns the backups of a site, newest first
+func (c *Client) Backups(ctx context.Context, domain string) ([]Backup, error) {
+	var backups []Backup
+	err := c.read(ctx, func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		snapshots, err := backup.Snapshots(domain)
+		for _, s := range snapshots {
+			backups = append(backups, Backup(s))
+		}
+		return err
+	})
+	return backups, err
+}
+
+// RunBackup takes a daily backup of a site now
+func (c *Client) RunBackup(ctx context.Context, domain string) (Backup, error) {
+	var b Backup
+	err := c.do(ctx, "backup-run", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		s, err := backup.RunSite(domain)
+		b = Backup(s)
+		return err
+	})
+	if err != nil {
+		return b, err
+	}
+	c.done("backup-run", "Backup of site %s written to %s", domain, b.Path)
+	return b, nil
+}
+
+// RestoreBackup replaces the files of a site with those of a backup
+func (c *Client) RestoreBackup(ctx context.Context, domain, kind, name string) error {
+	err := c.do(ctx, "backup-restore", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		return backup.RestoreSite(domain, kind, name)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("backup-restore", "Site %s restored from %s backup %s", domain, kind, name)
+	return nil
+}
+
+// EnableBackup schedules daily and weekly backups of a site
+func (c *Client) EnableBackup(ctx context.Context, domain string) error {
+	err := c.do(ctx, "enable-backup", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		return backup.EnableSite(domain)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("enable-backup", "Automatic backups enabled for site %s", domain)
+	return nil
+}
+
+// DisableBackup stops scheduled backups of a site
+func (c *Client) DisableBackup(ctx context.Context, domain string) error {
+	err := c.do(ctx, "disable-backup", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		return backup.DisableSite(domain)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("disable-backup", "Automatic backups disabled for site %s", domain)
+	return nil
+}
+
+// EnableDatabaseBackup schedules daily and weekly dumps of all databases
+func (c *Client) EnableDatabaseBackup(ctx context.Context) error {
+	err := c.do(ctx, "enable-dbbackup", backup.EnableDatabase)
+	if err != nil {
+		return err
+	}
+	c.done("enable-dbbackup", "Automatic database backups enabled")
+	return nil
+}
+
+// DisableDatabaseBackup stops scheduled database dumps
+func (c *Client) DisableDatabaseBackup(ctx context.Context) error {
+	err := c.do(ctx, "disable-dbbackup", backup.DisableDatabase)
+	if err != nil {
+		return err
+	}
+	c.done("disable-dbbackup", "Automatic database backups disabled")
+	return nil
+}
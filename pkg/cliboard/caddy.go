@@ -0,0 +1,57 @@
+package cliboard
+
+import (
+	"context"
+
+	"github.com/doko89/cliboard/internal/caddy"
+)
+
+// InstallCaddy installs Caddy with cliboard's configuration layout. It does
+// nothing when Caddy is already installed.
+func (c *Client) InstallCaddy(ctx context.Context) error {
+	installed := false
+	err := c.do(ctx, "caddy-install", func() error {
+		if caddy.Installed() {
+			return nil
+		}
+		installed = true
+		return caddy.Install()
+	})
+	if err != nil {
+		return err
+	}
+
+	if installed {
+		c.done("caddy-install", "Caddy installed successfully")
+	} else {
+		c.done("caddy-install", "Caddy is already installed")
+	}
+	return nil
+}
+
+// BuildCaddy rebuilds Caddy with the recorded plugins plus with and minus
+// without, and returns the path the previous binary was kept at
+func (c *Client) BuildCaddy(ctx context.Context, with, without []string) (string, error) {
+	var previous string
+	err := c.do(ctx, "caddy-build", func() error {
+		var err error
+		previous, err = caddy.Build(with, without)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	c.done("caddy-build", "Caddy rebuilt successfully, previous binary kept at %s", previous)
+	return previous, nil
+}
+
+// CaddyPlugins returns the plugin packages built into Caddy by BuildCaddy
+func (c *Client) CaddyPlugins(ctx context.Context) ([]string, error) {
+	var plugins []string
+	err := c.read(ctx, func() error {
+		var err error
+		plugins, err = caddy.Plugins()
+		return err
+	})
+	return plugins, err
+}
@@ -0,0 +1,115 @@
+// Package cliboard is the public Go API of cliboard. It manages sites, Caddy
+// modules, PHP and backups on the local machine, the same way the cliboard
+// command does, and returns structured results instead of printing.
+//
+//	client, err := cliboard.New(cliboard.Options{
+//		OnEvent: func(e cliboard.Event) { log.Println(e.Message) },
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	site, err := client.CreateSite(ctx, "example.com")
+//
+// cliboard's settings are process wide, so a process should only use one
+// configuration at a time. Operations of a Client run one at a time.
+package cliboard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/event"
+)
+
+// Options configure a Client
+type Options struct {
+	// ConfigFile is the configuration file to read. Empty means
+	// $CLIBOARD_CONFIG or /etc/cliboard/config.yaml.
+	ConfigFile string
+
+	// Root relocates all filesystem paths under a directory, see the
+	// --root flag. Empty means $CLIBOARD_ROOT, if set.
+	Root string
+
+	// Set overrides settings, as key=value pairs such as
+	// "paths.sites_root=/srv/sites".
+	Set []string
+
+	// OnEvent receives progress and completion events. It may be nil.
+	OnEvent func(Event)
+}
+
+// EventKind tells progress and completion events apart
+type EventKind string
+
+const (
+	// EventProgress reports a step of an operation in progress
+	EventProgress EventKind = "progress"
+	// EventDone reports that an operation completed successfully
+	EventDone EventKind = "done"
+)
+
+// Event describes what an operation is doing
+type Event struct {
+	Kind      EventKind `json:"kind"`
+	Operation string    `json:"operation"`
+	Message   string    `json:"message"`
+}
+
+// Client manages the local cliboard installation
+type Client struct {
+	onEvent func(Event)
+
+	// mu serializes operations, as the internal packages share state
+	mu sync.Mutex
+}
+
+// New loads the configuration and returns a client
+func New(opts Options) (*Client, error) {
+	if err := config.Load(config.Options{File: opts.ConfigFile, Root: opts.Root, Set: opts.Set}); err != nil {
+		return nil, err
+	}
+	return &Client{onEvent: opts.OnEvent}, nil
+}
+
+// do runs an operation, passing its progress on as events. The context is
+// checked before the operation starts; a started operation runs to the end.
+func (c *Client) do(ctx context.Context, op string, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	restore := event.Handle(func(message string) {
+		c.emit(Event{Kind: EventProgress, Operation: op, Message: message})
+	})
+	defer restore()
+
+	return fn()
+}
+
+// read runs a query that does not change anything
+func (c *Client) read(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fn()
+}
+
+// done reports that an operation completed
+func (c *Client) done(op, format string, args ...interface{}) {
+	c.emit(Event{Kind: EventDone, Operation: op, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *Client) emit(e Event) {
+	if c.onEvent != nil {
+		c.onEvent(e)
+	}
+}
@@ -0,0 +1,190 @@
+package cliboard
+
+import (
+	"context"
+
+	"github.com/doko89/cliboard/internal/php"
+	"github.com/doko89/cliboard/internal/site"
+)
+
+// PHPVersion is an installed PHP version
+type PHPVersion struct {
+	Version string `json:"version"`
+	Binary  string `json:"binary"`
+	Service string `json:"service"`
+}
+
+// PHPExtension is an extension that can be installed for a PHP version
+type PHPExtension struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// PHPVersions returns the installed PHP versions
+func (c *Client) PHPVersions(ctx context.Context) ([]PHPVersion, error) {
+	var versions []PHPVersion
+	err := c.read(ctx, func() error {
+		for _, v := range php.InstalledVersions() {
+			versions = append(versions, phpVersion(v))
+		}
+		return nil
+	})
+	return versions, err
+}
+
+// InstallPHP installs a PHP version with PHP-FPM and its Caddy snippet
+func (c *Client) InstallPHP(ctx context.Context, version string) (PHPVersion, error) {
+	err := c.do(ctx, "php-install", func() error {
+		if err := php.ValidVersion(version); err != nil {
+			return err
+		}
+		return php.Install(version)
+	})
+	if err != nil {
+		return PHPVersion{}, err
+	}
+	c.done("php-install", "PHP %s installed successfully", version)
+	return phpVersion(version), nil
+}
+
+// UninstallPHP removes a PHP version
+func (c *Client) UninstallPHP(ctx context.Context, version string) error {
+	err := c.do(ctx, "php-uninstall", func() error {
+		if err := php.ValidVersion(version); err != nil {
+			return err
+		}
+		return php.Uninstall(version)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("php-uninstall", "PHP %s uninstalled successfully", version)
+	return nil
+}
+
+// EnablePHP serves a site with a PHP version, replacing any enabled version
+func (c *Client) EnablePHP(ctx context.Context, domain, version string) (Site, error) {
+	var s Site
+	var previous string
+	err := c.do(ctx, "enable-php", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := php.ValidVersion(version); err != nil {
+			return err
+		}
+		if info, err := site.Get(domain); err == nil {
+			previous = info.PHPVersion
+		}
+		if err := php.Enable(domain, version); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+
+	if previous != "" {
+		c.done("enable-php", "PHP version updated to %s for site %s", version, domain)
+	} else {
+		c.done("enable-php", "PHP version %s enabled for site %s", version, domain)
+	}
+	return s, nil
+}
+
+// DisablePHP stops serving a site with PHP
+func (c *Client) DisablePHP(ctx context.Context, domain string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "disable-php", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := php.Disable(domain); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("disable-php", "PHP disabled for site %s", domain)
+	return s, nil
+}
+
+// UpdatePHP rewrites the PHP configuration of a site's current version
+func (c *Client) UpdatePHP(ctx context.Context, domain string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "update-php", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := php.Update(domain); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("update-php", "PHP configuration updated for site %s", domain)
+	return s, nil
+}
+
+// PHPExtensions returns the extensions available for a PHP version
+func (c *Client) PHPExtensions(ctx context.Context, version string) ([]PHPExtension, error) {
+	var extensions []PHPExtension
+	err := c.read(ctx, func() error {
+		if err := php.ValidVersion(version); err != nil {
+			return err
+		}
+		packages, err := php.AvailableModules(version)
+		for _, p := range packages {
+			extensions = append(extensions, PHPExtension{Name: p.Name, Version: p.Version, Description: p.Description})
+		}
+		return err
+	})
+	return extensions, err
+}
+
+// AddPHPExtension installs an extension for a PHP version
+func (c *Client) AddPHPExtension(ctx context.Context, version, name string) error {
+	err := c.do(ctx, "php-module-add", func() error {
+		if err := php.ValidVersion(version); err != nil {
+			return err
+		}
+		return php.AddModule(version, name)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("php-module-add", "PHP module %s installed successfully for PHP %s", name, version)
+	return nil
+}
+
+// RemovePHPExtension removes an extension from a PHP version
+func (c *Client) RemovePHPExtension(ctx context.Context, version, name string) error {
+	err := c.do(ctx, "php-module-remove", func() error {
+		if err := php.ValidVersion(version); err != nil {
+			return err
+		}
+		return php.RemoveModule(version, name)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("php-module-remove", "PHP module %s removed successfully from PHP %s", name, version)
+	return nil
+}
+
+func phpVersion(version string) PHPVersion {
+	names := php.Names(version)
+	return PHPVersion{Version: version, Binary: names.Binary, Service: names.Service}
+}
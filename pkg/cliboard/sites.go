@@ -0,0 +1,418 @@
+package cliboard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/doko89/cliboard/internal/config"
+	"github.com/doko89/cliboard/internal/database"
+	"github.com/doko89/cliboard/internal/event"
+	"github.com/doko89/cliboard/internal/module"
+	"github.com/doko89/cliboard/internal/site"
+	"github.com/doko89/cliboard/internal/state"
+)
+
+// Site is a site served by Caddy
+type Site struct {
+	Domain     string   `json:"domain"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Template   string   `json:"template"`
+	Directory  string   `json:"directory"`
+	Webroot    string   `json:"webroot"`
+	PHPVersion string   `json:"php_version,omitempty"`
+	Modules    []string `json:"modules"`
+	Backup     bool     `json:"backup"`
+	Suspended  bool     `json:"suspended,omitempty"`
+}
+
+// Module is a Caddy configuration snippet that sites can import
+type Module struct {
+	Name string `json:"name"`
+	// MissingPlugins lists what the installed Caddy lacks to use the module
+	MissingPlugins []Plugin `json:"missing_plugins,omitempty"`
+	// Content, Sites and ImportedBy are only filled in by Module
+	Content    string   `json:"content,omitempty"`
+	Sites      []string `json:"sites,omitempty"`
+	ImportedBy []string `json:"imported_by,omitempty"`
+}
+
+// Plugin is a Caddy module and the plugin package providing it
+type Plugin struct {
+	Module  string `json:"module"`
+	Package string `json:"package,omitempty"`
+}
+
+// Sites returns all sites, sorted by domain
+func (c *Client) Sites(ctx context.Context) ([]Site, error) {
+	var sites []Site
+	err := c.read(ctx, func() error {
+		infos, err := site.List()
+		for _, info := range infos {
+			sites = append(sites, siteFromInfo(info))
+		}
+		return err
+	})
+	return sites, err
+}
+
+// Site returns a single site
+func (c *Client) Site(ctx context.Context, domain string) (Site, error) {
+	var s Site
+	err := c.read(ctx, func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	return s, err
+}
+
+// CreateSite creates a site with a sample index page
+func (c *Client) CreateSite(ctx context.Context, domain string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "create-site", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := site.Create(domain); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("create-site", "Site %s created successfully", domain)
+	return s, nil
+}
+
+// SiteSettings are everything CreateSiteWith sets up for a new site
+type SiteSettings struct {
+	Domain   string
+	Template string
+	// Webroot is relative to the site directory
+	Webroot string
+	// PHP is the version the site runs on, installed first if necessary
+	PHP     string
+	Modules []string
+	Backup  bool
+	// Database creates a MariaDB/MySQL database and user for the site
+	Database bool
+	// AuthUser and AuthPassword require basic auth for the site when set
+	AuthUser     string
+	AuthPassword string
+}
+
+// CreateSiteWith creates a site with all its settings as one change. Caddy
+// is reloaded once, after the configuration has been validated; if any step
+// fails, the site, its backup jobs and its database are removed again. PHP
+// versions installed on the way are kept. The credentials of a new database
+// are only saved to a file readable by root, whose path is reported.
+func (c *Client) CreateSiteWith(ctx context.Context, settings SiteSettings) (Site, error) {
+	var s Site
+	domain := settings.Domain
+	err := c.do(ctx, "create-site", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if _, err := site.Get(domain); err == nil {
+			return fmt.Errorf("site %s already exists", domain)
+		}
+
+		desired := state.Site{
+			Domain:   domain,
+			Template: settings.Template,
+			Webroot:  settings.Webroot,
+			Modules:  settings.Modules,
+			Backup:   settings.Backup,
+		}
+		if settings.PHP != "" {
+			desired.PHP = &state.PHP{Version: settings.PHP}
+		}
+		plan, err := state.NewPlan(state.File{Sites: []state.Site{desired}}, false)
+		if err != nil {
+			return err
+		}
+
+		// Basic auth goes away with the site if a later step fails
+		if settings.AuthUser != "" {
+			plan.Add(domain, state.Add, "require basic auth for user "+settings.AuthUser, func() error {
+				return site.SetBasicAuth(domain, settings.AuthUser, settings.AuthPassword)
+			}, nil)
+		}
+		var credentials *database.Credentials
+		if settings.Database {
+			plan.Add(domain, state.Add, "create database "+database.NameFor(domain), func() error {
+				c, err := database.Create(domain)
+				if err == nil {
+					credentials = &c
+				}
+				return err
+			}, func() error {
+				return database.Drop(domain, *credentials)
+			})
+		}
+
+		// Apply undoes every step, the site itself included, if one fails
+		err = plan.Apply(func(change state.Change) {
+			event.Progress("%s: %s", change.Site, change.Description)
+		})
+		if err != nil {
+			return fmt.Errorf("site %s was not created: %v", domain, err)
+		}
+		if credentials != nil {
+			event.Progress("Database %s created, its credentials are saved in %s", credentials.Name, config.Target(database.CredentialsPath(domain)))
+		}
+
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("create-site", "Site %s created successfully", domain)
+	return s, nil
+}
+
+// DeleteSite deletes a site, its files and its configuration
+func (c *Client) DeleteSite(ctx context.Context, domain string) error {
+	err := c.do(ctx, "delete-site", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		return site.Remove(domain)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("delete-site", "Site %s deleted successfully", domain)
+	return nil
+}
+
+// SuspendSite makes a site answer every request with 503 Service
+// Unavailable until it is resumed
+func (c *Client) SuspendSite(ctx context.Context, domain string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "suspend-site", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := site.Suspend(domain); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("suspend-site", "Site %s suspended", domain)
+	return s, nil
+}
+
+// ResumeSite serves a suspended site again
+func (c *Client) ResumeSite(ctx context.Context, domain string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "resume-site", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := site.Resume(domain); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("resume-site", "Site %s resumed", domain)
+	return s, nil
+}
+
+// UpdateWebroot serves a site from a directory below its site directory
+func (c *Client) UpdateWebroot(ctx context.Context, domain, path string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "update-webroot", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if strings.Contains(path, "..") {
+			return fmt.Errorf("webroot must be inside the site directory")
+		}
+		if err := site.UpdateWebroot(domain, path); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("update-webroot", "Webroot for site %s updated to %s successfully", domain, s.Webroot)
+	return s, nil
+}
+
+// Modules returns the available Caddy modules
+func (c *Client) Modules(ctx context.Context) ([]Module, error) {
+	var modules []Module
+	err := c.read(ctx, func() error {
+		names, err := module.Available()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			m := Module{Name: name}
+			missing, err := module.MissingPlugins(name)
+			if err == nil {
+				for _, req := range missing {
+					m.MissingPlugins = append(m.MissingPlugins, Plugin{Module: req.Module, Package: req.Plugin})
+				}
+			}
+			modules = append(modules, m)
+		}
+		return nil
+	})
+	return modules, err
+}
+
+// Module returns a Caddy module with its contents and the sites importing it
+func (c *Client) Module(ctx context.Context, name string) (Module, error) {
+	m := Module{Name: name}
+	err := c.read(ctx, func() error {
+		content, err := module.Show(name)
+		if err != nil {
+			return err
+		}
+		m.Content = string(content)
+		if missing, err := module.MissingPlugins(name); err == nil {
+			for _, req := range missing {
+				m.MissingPlugins = append(m.MissingPlugins, Plugin{Module: req.Module, Package: req.Plugin})
+			}
+		}
+		if m.ImportedBy, err = module.ImportedBy(name); err != nil {
+			return err
+		}
+		m.Sites, err = module.Users(name)
+		return err
+	})
+	return m, err
+}
+
+// CreateModule adds a Caddy module to modules.d. The content must be a
+// single snippet named after the module that Caddy accepts.
+func (c *Client) CreateModule(ctx context.Context, name string, content []byte) error {
+	err := c.do(ctx, "create-module", func() error {
+		return module.Create(name, content)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("create-module", "Module %s created successfully", name)
+	return nil
+}
+
+// UpdateModule replaces the contents of a Caddy module
+func (c *Client) UpdateModule(ctx context.Context, name string, content []byte) error {
+	err := c.do(ctx, "edit-module", func() error {
+		return module.Update(name, content)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("edit-module", "Module %s updated successfully", name)
+	return nil
+}
+
+// DeleteModule removes a Caddy module that no site imports
+func (c *Client) DeleteModule(ctx context.Context, name string) error {
+	err := c.do(ctx, "delete-module", func() error {
+		return module.Delete(name)
+	})
+	if err != nil {
+		return err
+	}
+	c.done("delete-module", "Module %s deleted successfully", name)
+	return nil
+}
+
+// EnableModule imports a Caddy module into a site
+func (c *Client) EnableModule(ctx context.Context, domain, name string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "add-module", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := module.Add(domain, name); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("add-module", "Module %s added to site %s successfully", name, domain)
+	return s, nil
+}
+
+// DisableModule removes a Caddy module from a site
+func (c *Client) DisableModule(ctx context.Context, domain, name string) (Site, error) {
+	var s Site
+	err := c.do(ctx, "remove-module", func() error {
+		if err := validDomain(domain); err != nil {
+			return err
+		}
+		if err := module.Remove(domain, name); err != nil {
+			return err
+		}
+		var err error
+		s, err = getSite(domain)
+		return err
+	})
+	if err != nil {
+		return s, err
+	}
+	c.done("remove-module", "Module %s removed from site %s successfully", name, domain)
+	return s, nil
+}
+
+// validDomain checks that domain is safe to use as a site name before it
+// reaches the file system
+func validDomain(domain string) error {
+	if !site.ValidDomain(domain) {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	return nil
+}
+
+func getSite(domain string) (Site, error) {
+	info, err := site.Get(domain)
+	if err != nil {
+		return Site{}, err
+	}
+	return siteFromInfo(info), nil
+}
+
+func siteFromInfo(info site.Info) Site {
+	return Site{
+		Domain:     info.Domain,
+		Aliases:    info.Aliases,
+		Template:   info.Template,
+		Directory:  info.Directory,
+		Webroot:    info.Webroot,
+		PHPVersion: info.PHPVersion,
+		Modules:    info.Modules,
+		Backup:     info.Backup,
+		Suspended:  info.Suspended,
+	}
+}